@@ -1,12 +1,18 @@
 package api
 
 import (
+	"bytes"
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mbenaiss/whatsapp-mcp/models"
+	"github.com/mbenaiss/whatsapp-mcp/services"
 )
 
 func (s *Server) handleQR(c *gin.Context) {
@@ -78,18 +84,200 @@ func (s *Server) handleSendMessage(c *gin.Context) {
 		return
 	}
 
-	err := s.service.SendMessage(c.Request.Context(), recipient, req.Message)
+	result, err := s.service.SendMessage(c.Request.Context(), recipient, req.Message, models.SendOptions{
+		WithPreview:         req.WithPreview,
+		QuotedMessageID:     req.QuotedMessageID,
+		ExpireSeconds:       req.ExpireSeconds,
+		RequireKnownContact: req.RequireKnownContact,
+		MessageID:           req.MessageID,
+	})
+	if errors.Is(err, services.ErrInvalidExpiration) || errors.Is(err, services.ErrInvalidMessageID) {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, services.ErrUnknownRecipient) {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Message: fmt.Sprintf("Recipient %s is not a known contact or chat, check the number", recipient),
+		})
+		return
+	}
+	if errors.Is(err, services.ErrDuplicateMessageID) {
+		c.JSON(http.StatusConflict, Response{
+			Success: false,
+			Message: fmt.Sprintf("Message ID %s was already used", req.MessageID),
+		})
+		return
+	}
+	if errors.Is(err, services.ErrMessageNotFound) {
+		c.JSON(http.StatusNotFound, Response{
+			Success: false,
+			Message: fmt.Sprintf("Quoted message %s not found", req.QuotedMessageID),
+		})
+		return
+	}
+	if errors.Is(err, services.ErrGroupSendForbidden) {
+		c.JSON(http.StatusForbidden, Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to send message: %v", err),
+			Data:    result,
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Success: false,
 			Message: fmt.Sprintf("Failed to send message: %v", err),
+			Data:    result,
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, Response{
 		Success: true,
-		Message: "Message sent successfully",
+		Message: sendResultMessage("Message", result),
+		Data:    result,
+	})
+}
+
+// sendResultMessage describes a successful send of the given kind of message
+func sendResultMessage(kind string, result models.SendResult) string {
+	message := kind + " sent successfully"
+	if result.Reconnected {
+		message = kind + " sent successfully after reconnecting"
+	}
+	if result.Delivery != nil {
+		message = fmt.Sprintf("%s sent, but it could not be delivered to %d devices", kind, len(result.Delivery.FailedDevices))
+		if len(result.Delivery.FailedDevices) == 0 {
+			message = kind + " sent, but the group changed while sending so some devices may have missed it"
+		}
+	}
+	return message
+}
+
+func (s *Server) handleSendVideo(c *gin.Context) {
+	var req SendVideoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	if req.Recipient == "" || req.MediaPath == "" {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Message: "Recipient and media path are required",
+		})
+		return
+	}
+
+	result, err := s.service.SendVideo(c.Request.Context(), req.Recipient, req.MediaPath, req.Caption, req.AsGIF)
+	if errors.Is(err, services.ErrNotMP4) || errors.Is(err, services.ErrPathNotAllowed) {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		c.JSON(http.StatusNotFound, Response{
+			Success: false,
+			Message: fmt.Sprintf("Video %s not found", req.MediaPath),
+		})
+		return
+	}
+	if errors.Is(err, services.ErrGroupSendForbidden) {
+		c.JSON(http.StatusForbidden, Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to send video: %v", err),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to send video: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Message: sendResultMessage("Video", result),
+		Data:    result,
+	})
+}
+
+func (s *Server) handleSendBroadcast(c *gin.Context) {
+	var req SendBroadcastRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	if len(req.Recipients) == 0 || req.Message == "" {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Message: "Recipients and message are required",
+		})
+		return
+	}
+
+	result, err := s.service.SendBroadcast(c.Request.Context(), req.Recipients, req.Message)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to send broadcast: %v", err),
+			Data:    result,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: result.Sent > 0,
+		Message: fmt.Sprintf("Broadcast sent to %d of %d recipients", result.Sent, len(result.Recipients)),
+		Data:    result,
+	})
+}
+
+func (s *Server) handleSendReaction(c *gin.Context) {
+	var req SendReactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	if req.ChatJID == "" || req.MessageID == "" || req.Emoji == "" {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Message: "Chat JID, message ID and emoji are required",
+		})
+		return
+	}
+
+	err := s.service.SendReaction(c.Request.Context(), req.ChatJID, req.MessageID, req.Emoji)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to send reaction: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Message: "Reaction sent successfully",
 	})
 }
 
@@ -109,6 +297,53 @@ func (s *Server) handleGetChats(c *gin.Context) {
 	})
 }
 
+func (s *Server) handleExportContacts(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Message: "Format must be either json or csv",
+		})
+		return
+	}
+
+	contacts, err := s.service.GetContacts(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to get contacts: %v", err),
+		})
+		return
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, Response{
+			Success: true,
+			Data:    contacts,
+		})
+		return
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"name", "phone_number", "jid", "is_business"})
+	for _, contact := range contacts {
+		w.Write([]string{contact.Name, contact.PhoneNumber, contact.JID, strconv.FormatBool(contact.IsBusiness)})
+	}
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to write CSV: %v", err),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="contacts.csv"`)
+	c.Data(http.StatusOK, "text/csv", buf.Bytes())
+}
+
 func (s *Server) handleGetMessages(c *gin.Context) {
 	chatJID := c.Query("chat")
 	if chatJID == "" {
@@ -141,6 +376,280 @@ func (s *Server) handleGetMessages(c *gin.Context) {
 	})
 }
 
+func (s *Server) handleRetryMediaDownload(c *gin.Context) {
+	var req RetryMediaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+
+	results, err := s.service.RetryMediaDownload(c.Request.Context(), req.ChatJID, req.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to retry media download: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    results,
+	})
+}
+
+func (s *Server) handleDeleteMedia(c *gin.Context) {
+	var req DeleteMediaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	if req.ChatJID == "" || req.MessageID == "" {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Message: "Chat JID and message ID are required",
+		})
+		return
+	}
+
+	result, err := s.service.DeleteMedia(c.Request.Context(), req.ChatJID, req.MessageID)
+	if errors.Is(err, services.ErrMessageNotFound) {
+		c.JSON(http.StatusNotFound, Response{
+			Success: false,
+			Message: fmt.Sprintf("Message %s not found in chat %s", req.MessageID, req.ChatJID),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to delete media: %v", err),
+		})
+		return
+	}
+
+	message := "Media deleted successfully"
+	if !result.Deleted {
+		message = "No media file to delete"
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Message: message,
+		Data:    result,
+	})
+}
+
+func (s *Server) handleGetStorageUsage(c *gin.Context) {
+	usage, err := s.service.GetStorageUsage(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to get storage usage: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    usage,
+	})
+}
+
+func (s *Server) handleGetCounts(c *gin.Context) {
+	counts, err := s.service.GetCounts(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to get counts: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    counts,
+	})
+}
+
+func (s *Server) handleGetHistorySyncStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    s.service.GetHistorySyncStatus(),
+	})
+}
+
+func (s *Server) handleRequestHistorySync(c *gin.Context) {
+	err := s.service.RequestHistorySync(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to request history sync: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Message: "History sync requested",
+	})
+}
+
+func (s *Server) handleResyncChat(c *gin.Context) {
+	chat, err := s.service.ResyncChat(c.Request.Context(), c.Param("jid"))
+	if errors.Is(err, services.ErrChatNotFound) {
+		c.JSON(http.StatusNotFound, Response{
+			Success: false,
+			Message: fmt.Sprintf("Chat %s not found", c.Param("jid")),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to resync chat: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Message: "Chat resynced successfully",
+		Data:    chat,
+	})
+}
+
+func (s *Server) handleMarkChatRead(c *gin.Context) {
+	result, err := s.service.MarkChatRead(c.Request.Context(), c.Param("jid"))
+	if errors.Is(err, services.ErrChatNotFound) {
+		c.JSON(http.StatusNotFound, Response{
+			Success: false,
+			Message: fmt.Sprintf("Chat %s not found", c.Param("jid")),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to mark chat read: %v", err),
+			Data:    result,
+		})
+		return
+	}
+
+	message := fmt.Sprintf("Marked %d messages read", result.Marked)
+	if result.Marked == 0 {
+		message = "No unread messages"
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Message: message,
+		Data:    result,
+	})
+}
+
+func (s *Server) handleGetLabels(c *gin.Context) {
+	labels, err := s.service.GetLabels(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to get labels: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    labels,
+	})
+}
+
+func (s *Server) handleCreateLabel(c *gin.Context) {
+	var req CreateLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Message: "Label name is required",
+		})
+		return
+	}
+
+	label, err := s.service.CreateLabel(c.Request.Context(), req.Name, req.Color)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to create label: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Message: "Label created successfully",
+		Data:    label,
+	})
+}
+
+func (s *Server) handleAssignLabel(c *gin.Context) {
+	var req AssignLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Message: "Invalid request body",
+		})
+		return
+	}
+
+	if req.ChatJID == "" || req.LabelID == "" {
+		c.JSON(http.StatusBadRequest, Response{
+			Success: false,
+			Message: "Chat JID and label ID are required",
+		})
+		return
+	}
+
+	assigned := req.Assigned == nil || *req.Assigned
+
+	err := s.service.AssignLabel(c.Request.Context(), req.ChatJID, req.LabelID, assigned)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to assign label: %v", err),
+		})
+		return
+	}
+
+	message := "Label assigned successfully"
+	if !assigned {
+		message = "Label removed successfully"
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Message: message,
+	})
+}
+
 func (s *Server) handleLogin(c *gin.Context) {
 	err := s.service.Login(c.Request.Context())
 	if err != nil {
@@ -156,3 +665,42 @@ func (s *Server) handleLogin(c *gin.Context) {
 		Message: "Login successful",
 	})
 }
+
+func (s *Server) handlePauseIngestion(c *gin.Context) {
+	state, err := s.service.PauseIngestion()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to pause ingestion: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Message: "Ingestion paused, received messages are buffered until resumed",
+		Data:    state,
+	})
+}
+
+func (s *Server) handleResumeIngestion(c *gin.Context) {
+	state, err := s.service.ResumeIngestion()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to resume ingestion: %v", err),
+		})
+		return
+	}
+
+	message := fmt.Sprintf("Ingestion resumed, stored %d buffered messages", state.Flushed)
+	if state.AutoResumed {
+		message = "Ingestion had already resumed because the buffer was full"
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Message: message,
+		Data:    state,
+	})
+}
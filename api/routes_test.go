@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mbenaiss/whatsapp-mcp/models"
+	"github.com/mbenaiss/whatsapp-mcp/services"
+)
+
+// fakeService serves fixed contacts; the other methods are not used by these tests
+type fakeService struct {
+	services.Service
+	contacts []models.Contact
+}
+
+func (f *fakeService) GetContacts(ctx context.Context) ([]models.Contact, error) {
+	return f.contacts, nil
+}
+
+// newTestRouter registers the API routes of a server backed by service
+func newTestRouter(service services.Service) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	(&Server{service: service}).registerRoutes(router)
+	return router
+}
+
+func TestExportContactsRoundTrip(t *testing.T) {
+	contacts := []models.Contact{
+		{Name: "Alice", PhoneNumber: "15550002222", JID: "15550002222@s.whatsapp.net"},
+		{Name: `Bob "the plumber", Ltd`, PhoneNumber: "15550003333", JID: "15550003333@s.whatsapp.net", IsBusiness: true},
+		{Name: "Zoë\nSecond line", PhoneNumber: "15550004444", JID: "15550004444@s.whatsapp.net"},
+	}
+	router := newTestRouter(&fakeService{contacts: contacts})
+
+	export := func(t *testing.T, format string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/contacts/export?format="+format, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("export %s: status %d: %s", format, rec.Code, rec.Body)
+		}
+		return rec
+	}
+
+	t.Run("json", func(t *testing.T) {
+		var resp struct {
+			Success bool             `json:"success"`
+			Data    []models.Contact `json:"data"`
+		}
+		if err := json.Unmarshal(export(t, "json").Body.Bytes(), &resp); err != nil {
+			t.Fatalf("import: %v", err)
+		}
+		if !resp.Success || !slices.Equal(resp.Data, contacts) {
+			t.Errorf("imported %+v, want %+v", resp.Data, contacts)
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		rec := export(t, "csv")
+		if got := rec.Header().Get("Content-Type"); got != "text/csv" {
+			t.Errorf("content type = %q, want text/csv", got)
+		}
+
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("import: %v", err)
+		}
+		if len(records) == 0 || !slices.Equal(records[0], []string{"name", "phone_number", "jid", "is_business"}) {
+			t.Fatalf("header = %v", records)
+		}
+
+		var imported []models.Contact
+		for _, record := range records[1:] {
+			isBusiness, err := strconv.ParseBool(record[3])
+			if err != nil {
+				t.Fatalf("is_business %q: %v", record[3], err)
+			}
+			imported = append(imported, models.Contact{Name: record[0], PhoneNumber: record[1], JID: record[2], IsBusiness: isBusiness})
+		}
+		if !slices.Equal(imported, contacts) {
+			t.Errorf("imported %+v, want %+v", imported, contacts)
+		}
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/contacts/export?format=vcf", nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+}
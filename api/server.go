@@ -31,8 +31,62 @@ func NewServer(service services.Service, port string) *Server {
 
 // SendMessageRequest represents the request body for sending messages
 type SendMessageRequest struct {
+	Recipient       string `json:"recipient"`
+	Message         string `json:"message"`
+	WithPreview     bool   `json:"with_preview"`
+	QuotedMessageID string `json:"quoted_message_id"`
+	ExpireSeconds   uint32 `json:"expire_seconds"`
+	// RequireKnownContact refuses to send to recipients that are neither a contact nor an existing chat
+	RequireKnownContact bool `json:"require_known_contact"`
+	// MessageID is used as the ID of the sent message instead of a generated one
+	MessageID string `json:"message_id"`
+}
+
+// SendVideoRequest represents the request body for sending a video
+type SendVideoRequest struct {
 	Recipient string `json:"recipient"`
-	Message   string `json:"message"`
+	// MediaPath is relative to the send media directory, or an absolute path inside it
+	MediaPath string `json:"media_path"`
+	Caption   string `json:"caption"`
+	AsGIF     bool   `json:"as_gif"`
+}
+
+// SendBroadcastRequest represents the request body for sending a message to multiple recipients
+type SendBroadcastRequest struct {
+	Recipients []string `json:"recipients"`
+	Message    string   `json:"message"`
+}
+
+// SendReactionRequest represents the request body for reacting to a message
+type SendReactionRequest struct {
+	ChatJID   string `json:"chat_jid"`
+	MessageID string `json:"message_id"`
+	Emoji     string `json:"emoji"`
+}
+
+// RetryMediaRequest represents the request body for retrying media downloads
+type RetryMediaRequest struct {
+	ChatJID string `json:"chat_jid"`
+	Limit   int    `json:"limit"`
+}
+
+// DeleteMediaRequest represents the request body for deleting the media file of a message
+type DeleteMediaRequest struct {
+	ChatJID   string `json:"chat_jid"`
+	MessageID string `json:"message_id"`
+}
+
+// CreateLabelRequest represents the request body for creating a label
+type CreateLabelRequest struct {
+	Name  string `json:"name"`
+	Color int32  `json:"color"`
+}
+
+// AssignLabelRequest represents the request body for assigning a label to a chat
+type AssignLabelRequest struct {
+	ChatJID  string `json:"chat_jid"`
+	LabelID  string `json:"label_id"`
+	Assigned *bool  `json:"assigned"`
 }
 
 // Response represents a generic API response
@@ -50,8 +104,25 @@ func (s *Server) registerRoutes(router *gin.Engine) {
 		api.GET("/qr", s.handleQR)
 		api.GET("/status", s.handleStatus)
 		api.POST("/send", s.handleSendMessage)
+		api.POST("/send/video", s.handleSendVideo)
+		api.POST("/broadcast", s.handleSendBroadcast)
+		api.POST("/react", s.handleSendReaction)
 		api.GET("/chats", s.handleGetChats)
+		api.POST("/chats/:jid/resync", s.handleResyncChat)
+		api.POST("/chats/:jid/read", s.handleMarkChatRead)
+		api.GET("/contacts/export", s.handleExportContacts)
 		api.GET("/messages", s.handleGetMessages)
+		api.POST("/media/retry", s.handleRetryMediaDownload)
+		api.POST("/media/delete", s.handleDeleteMedia)
+		api.GET("/storage", s.handleGetStorageUsage)
+		api.GET("/counts", s.handleGetCounts)
+		api.GET("/history-sync/status", s.handleGetHistorySyncStatus)
+		api.POST("/history-sync", s.handleRequestHistorySync)
+		api.GET("/labels", s.handleGetLabels)
+		api.POST("/labels", s.handleCreateLabel)
+		api.POST("/labels/assign", s.handleAssignLabel)
+		api.POST("/ingestion/pause", s.handlePauseIngestion)
+		api.POST("/ingestion/resume", s.handleResumeIngestion)
 	}
 }
 
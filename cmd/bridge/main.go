@@ -28,25 +28,46 @@ func main() {
 		log.Fatalf("Failed to create store directory: %v", err)
 	}
 
-	messageStore, err := db.NewDB(ctx, cfg.StoreDir)
+	messageStore, err := db.NewDB(ctx, cfg.StoreDir, db.Options{
+		Pragmas: cfg.DBPragmas,
+	})
 	if err != nil {
 		log.Fatalf("Failed to initialize message store: %v", err)
 	}
 	defer messageStore.Close()
 
-	whatsappClient, err := whatsapp.NewWhatsapp(cfg.StoreDir)
+	whatsappClient, err := whatsapp.NewWhatsapp(cfg.StoreDir, whatsapp.Options{
+		StoreUnknownTypes: cfg.StoreUnknownTypes,
+		StoreRaw:          cfg.DebugStoreRaw,
+		SendMediaDir:      cfg.SendMediaDir,
+	})
 	if err != nil {
 		log.Fatalf("Failed to initialize WhatsApp client: %v", err)
 	}
 
-	service := services.NewService(whatsappClient, messageStore)
+	service := services.NewService(whatsappClient, messageStore, services.Options{
+		BatchSize:             cfg.BatchSize,
+		BatchInterval:         time.Duration(cfg.BatchIntervalMS) * time.Millisecond,
+		StoreDir:              cfg.StoreDir,
+		StripZeroWidth:        cfg.StripZeroWidth,
+		NormalizeUnicode:      cfg.NormalizeUnicode,
+		TrimWhitespace:        cfg.TrimWhitespace,
+		QRTerminalOutput:      cfg.QRTerminalOutput,
+		ForwardAlertThreshold: cfg.ForwardAlertThreshold,
+		ForwardAlertWebhook:   cfg.ForwardAlertWebhook,
+		SyncOnLogin:           cfg.SyncOnLogin,
+		IngestionBufferLimit:  cfg.IngestionBufferLimit,
+	})
 
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
 	apiServer := api.NewServer(service, cfg.Port)
+	stopped := make(chan struct{})
 
 	go func() {
+		defer close(stopped)
+
 		<-c
 		log.Println("shutting down...")
 
@@ -58,6 +79,11 @@ func main() {
 		}
 
 		whatsappClient.Disconnect()
+
+		if err := service.Close(); err != nil {
+			log.Printf("Service shutdown error: %v", err)
+		}
+
 		log.Println("Server gracefully stopped")
 	}()
 
@@ -65,4 +91,6 @@ func main() {
 	if err := apiServer.Start(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("HTTP server error: %v", err)
 	}
+
+	<-stopped
 }
@@ -10,8 +10,22 @@ import (
 
 // Config struct to hold the configuration
 type Config struct {
-	Port     string `envconfig:"PORT" default:"8080"`
-	StoreDir string `envconfig:"STORE_DIR" default:"./store"`
+	Port                  string   `envconfig:"PORT" default:"8080"`
+	StoreDir              string   `envconfig:"STORE_DIR" default:"./store"`
+	StoreUnknownTypes     bool     `envconfig:"STORE_UNKNOWN_TYPES" default:"false"`
+	BatchSize             int      `envconfig:"BATCH_SIZE" default:"1"`
+	BatchIntervalMS       int      `envconfig:"BATCH_INTERVAL_MS" default:"500"`
+	StripZeroWidth        bool     `envconfig:"STRIP_ZERO_WIDTH" default:"false"`
+	NormalizeUnicode      bool     `envconfig:"NORMALIZE_UNICODE" default:"false"`
+	TrimWhitespace        bool     `envconfig:"TRIM_WHITESPACE" default:"false"`
+	QRTerminalOutput      bool     `envconfig:"QR_TERMINAL_OUTPUT" default:"true"`
+	ForwardAlertThreshold uint32   `envconfig:"FORWARD_ALERT_THRESHOLD" default:"0"`
+	ForwardAlertWebhook   string   `envconfig:"FORWARD_ALERT_WEBHOOK"`
+	DebugStoreRaw         bool     `envconfig:"DEBUG_STORE_RAW" default:"false"`
+	DBPragmas             []string `envconfig:"DB_PRAGMAS"`
+	SyncOnLogin           bool     `envconfig:"SYNC_ON_LOGIN" default:"false"`
+	IngestionBufferLimit  int      `envconfig:"INGESTION_BUFFER_LIMIT" default:"10000"`
+	SendMediaDir          string   `envconfig:"SEND_MEDIA_DIR"`
 }
 
 // Load function to load the configuration from the environment variables
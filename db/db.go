@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/mbenaiss/whatsapp-mcp/models"
@@ -14,9 +16,29 @@ import (
 type DB interface {
 	StoreChat(ctx context.Context, chat models.Chat) error
 	StoreMessage(ctx context.Context, msg models.Message) error
+	StoreBatch(ctx context.Context, chats []models.Chat) error
 	GetMessages(ctx context.Context, chatJID string, limit int) ([]models.Message, error)
 	GetChats(ctx context.Context) ([]models.Chat, error)
 	GetChat(ctx context.Context, jid string) (*models.Chat, error)
+	GetMessage(ctx context.Context, chatJID string, id string) (*models.Message, error)
+	StoreReaction(ctx context.Context, reaction models.Reaction) error
+	StoreReceipt(ctx context.Context, receipt models.Receipt) error
+	CountMessages(ctx context.Context) (total int, media int, downloaded int, err error)
+	CountChatsAndMessages(ctx context.Context) (models.Counts, error)
+	GetMessagesMissingMedia(ctx context.Context, chatJID string, limit int) ([]models.Message, error)
+	UpdateMediaPath(ctx context.Context, chatJID string, id string, mediaPath string) error
+	GetUnreadMessages(ctx context.Context, chatJID string) ([]models.Message, error)
+	MarkMessagesRead(ctx context.Context, chatJID string, ids []string) error
+	GetLabels(ctx context.Context) ([]models.Label, error)
+	NextLabelID(ctx context.Context) (string, error)
+	StoreLabel(ctx context.Context, label models.Label) error
+	DeleteLabel(ctx context.Context, id string) error
+	SetChatLabel(ctx context.Context, chatJID string, labelID string, assigned bool) error
+	StoreRawMessage(ctx context.Context, raw models.RawMessage) error
+	SetGroupParticipants(ctx context.Context, groupJID string, participants []string) error
+	UpdateGroupParticipants(ctx context.Context, groupJID string, joined []string, left []string) error
+	GetInitialSync(ctx context.Context) (*time.Time, error)
+	SetInitialSync(ctx context.Context, at time.Time) error
 	Close() error
 }
 
@@ -24,19 +46,59 @@ type db struct {
 	db *sql.DB
 }
 
+// messageColumns lists the message columns read by scanMessage
+const messageColumns = `id, chat_jid, sender, content, timestamp, is_from_me, mentions_me,
+	COALESCE(message_type, 'text'), COALESCE(raw_type, ''),
+	COALESCE(media_type, ''), COALESCE(mime_type, ''), COALESCE(media_path, ''), COALESCE(file_length, 0),
+	COALESCE(url, ''), COALESCE(direct_path, ''), media_key, file_sha256, file_enc_sha256,
+	COALESCE(forwarding_score, 0), edited_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanMessage(row scanner) (models.Message, error) {
+	msg := models.Message{}
+	var editedAt sql.NullTime
+	err := row.Scan(
+		&msg.ID, &msg.ChatJID, &msg.Sender, &msg.Content, &msg.Timestamp, &msg.IsFromMe, &msg.MentionsMe,
+		&msg.Type, &msg.RawType,
+		&msg.MediaType, &msg.MimeType, &msg.MediaPath, &msg.FileLength,
+		&msg.URL, &msg.DirectPath, &msg.MediaKey, &msg.FileSHA256, &msg.FileEncSHA256,
+		&msg.ForwardingScore, &editedAt,
+	)
+	if editedAt.Valid {
+		msg.EditedAt = &editedAt.Time
+	}
+	return msg, err
+}
+
+// Options configures optional behavior of the database
+type Options struct {
+	// Pragmas are extra "name=value" SQLite pragmas applied to every connection, after
+	// the defaults (foreign_keys = ON, journal_mode = WAL). Only performance pragmas are
+	// accepted: cache_size, mmap_size, synchronous, temp_store, busy_timeout,
+	// wal_autocheckpoint and journal_size_limit.
+	Pragmas []string
+}
+
 // NewDB creates a new database
-func NewDB(ctx context.Context, dbPath string) (DB, error) {
+func NewDB(ctx context.Context, dbPath string, opts Options) (DB, error) {
 	if err := os.MkdirAll(dbPath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create store directory: %v", err)
 	}
 
-	conn, err := sql.Open("sqlite3", fmt.Sprintf("file:%s/messages.db?_foreign_keys=on", dbPath))
+	pragmas, err := pragmaStatements(opts.Pragmas)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open message database: %v", err)
+		return nil, fmt.Errorf("invalid custom pragmas: %v", err)
 	}
 
+	dsn := fmt.Sprintf("file:%s/messages.db?_foreign_keys=on", dbPath)
+	conn := sql.OpenDB(newPragmaConnector(dsn, pragmas))
+
 	db := &db{conn}
 	if err := db.initDB(ctx); err != nil {
+		conn.Close()
 		return nil, fmt.Errorf("failed to initialize database: %v", err)
 	}
 
@@ -83,6 +145,25 @@ func (s *db) initDB(ctx context.Context) error {
 		return fmt.Errorf("failed to create messages table: %v", err)
 	}
 
+	_, err = s.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS reactions (
+			message_id TEXT,
+			chat_jid TEXT,
+			sender TEXT,
+			emoji TEXT,
+			timestamp TIMESTAMP,
+			PRIMARY KEY (message_id, chat_jid, sender)
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create reactions table: %v", err)
+	}
+
+	// Bring databases created by earlier versions up to date before relying on new columns
+	if err := s.migrate(ctx); err != nil {
+		return fmt.Errorf("failed to migrate database schema: %v", err)
+	}
+
 	// Create indexes separately and concurrently for better performance
 	_, err = s.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_messages_timestamp ON messages(timestamp);`)
 	if err != nil {
@@ -94,6 +175,11 @@ func (s *db) initDB(ctx context.Context) error {
 		return fmt.Errorf("failed to create chat_timestamp index: %v", err)
 	}
 
+	_, err = s.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_messages_mentions_me ON messages(mentions_me, timestamp);`)
+	if err != nil {
+		return fmt.Errorf("failed to create mentions_me index: %v", err)
+	}
+
 	return nil
 }
 
@@ -101,26 +187,109 @@ func (s *db) Close() error {
 	return s.db.Close()
 }
 
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
 // StoreChat stores a chat in the database
 func (s *db) StoreChat(ctx context.Context, chat models.Chat) error {
-	_, err := s.db.ExecContext(ctx,
-		"INSERT OR REPLACE INTO chats (jid, name, last_message_time) VALUES (?, ?, ?)",
+	return storeChat(ctx, s.db, chat)
+}
+
+// StoreMessage stores a message in the database
+func (s *db) StoreMessage(ctx context.Context, msg models.Message) error {
+	return storeMessage(ctx, s.db, msg)
+}
+
+// StoreBatch stores chats and their messages in a single transaction
+func (s *db) StoreBatch(ctx context.Context, chats []models.Chat) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, chat := range chats {
+		if err := storeChat(ctx, tx, chat); err != nil {
+			return fmt.Errorf("error storing chat: %v", err)
+		}
+
+		for _, msg := range chat.Messages {
+			if err := storeMessage(ctx, tx, msg); err != nil {
+				return fmt.Errorf("error storing message: %v", err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func storeChat(ctx context.Context, exec execer, chat models.Chat) error {
+	_, err := exec.ExecContext(ctx,
+		`INSERT INTO chats (jid, name, last_message_time) VALUES (?, ?, ?)
+		ON CONFLICT (jid) DO UPDATE SET
+			name = COALESCE(NULLIF(excluded.name, ''), chats.name),
+			last_message_time = excluded.last_message_time`,
 		chat.JID, chat.Name, chat.LastMessageTime,
 	)
 	return err
 }
 
-// StoreMessage stores a message in the database
-func (s *db) StoreMessage(ctx context.Context, msg models.Message) error {
-	if msg.Content == "" {
+func storeMessage(ctx context.Context, exec execer, msg models.Message) error {
+	if msg.Content == "" && msg.MediaType == "" {
 		return nil
 	}
 
-	_, err := s.db.ExecContext(ctx,
-		`INSERT OR REPLACE INTO messages
-		(id, chat_jid, sender, content, timestamp, is_from_me)
-		VALUES (?, ?, ?, ?, ?, ?)`,
-		msg.ID, msg.ChatJID, msg.Sender, msg.Content, msg.Timestamp, msg.IsFromMe,
+	if msg.EditedAt != nil {
+		// Keep the original's timestamp and media, only its content changes
+		result, err := exec.ExecContext(ctx,
+			"UPDATE messages SET content = ?, edited_at = ? WHERE chat_jid = ? AND id = ?",
+			msg.Content, *msg.EditedAt, msg.ChatJID, msg.ID,
+		)
+		if err != nil {
+			return err
+		}
+		if updated, err := result.RowsAffected(); err != nil || updated > 0 {
+			return err
+		}
+	}
+
+	// Messages are delivered again by history syncs and retries, so update the columns
+	// received from WhatsApp in place and keep those only set locally, like is_read and
+	// the path of downloaded media.
+	// The original of an edited message must not bring back its content from before the edit.
+	_, err := exec.ExecContext(ctx,
+		`INSERT INTO messages
+		(id, chat_jid, sender, content, timestamp, is_from_me, mentions_me, message_type, raw_type,
+		media_type, mime_type, media_path, file_length, url, direct_path, media_key, file_sha256, file_enc_sha256,
+		forwarding_score, edited_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id, chat_jid) DO UPDATE SET
+			sender = excluded.sender,
+			content = CASE WHEN excluded.edited_at IS NULL AND messages.edited_at IS NOT NULL
+				THEN messages.content ELSE excluded.content END,
+			timestamp = excluded.timestamp,
+			is_from_me = excluded.is_from_me,
+			mentions_me = excluded.mentions_me,
+			message_type = excluded.message_type,
+			raw_type = excluded.raw_type,
+			media_type = excluded.media_type,
+			mime_type = excluded.mime_type,
+			media_path = COALESCE(excluded.media_path, messages.media_path),
+			file_length = excluded.file_length,
+			url = excluded.url,
+			direct_path = excluded.direct_path,
+			media_key = excluded.media_key,
+			file_sha256 = excluded.file_sha256,
+			file_enc_sha256 = excluded.file_enc_sha256,
+			forwarding_score = excluded.forwarding_score,
+			edited_at = COALESCE(excluded.edited_at, messages.edited_at)`,
+		msg.ID, msg.ChatJID, msg.Sender, msg.Content, msg.Timestamp, msg.IsFromMe, msg.MentionsMe,
+		nullString(msg.Type), nullString(msg.RawType),
+		nullString(msg.MediaType), nullString(msg.MimeType), nullString(msg.MediaPath), msg.FileLength,
+		nullString(msg.URL), nullString(msg.DirectPath), msg.MediaKey, msg.FileSHA256, msg.FileEncSHA256,
+		msg.ForwardingScore, msg.EditedAt,
 	)
 	return err
 }
@@ -128,7 +297,7 @@ func (s *db) StoreMessage(ctx context.Context, msg models.Message) error {
 // GetMessages retrieves messages from a chat
 func (s *db) GetMessages(ctx context.Context, chatJID string, limit int) ([]models.Message, error) {
 	rows, err := s.db.QueryContext(ctx,
-		"SELECT id, chat_jid, sender, content, timestamp, is_from_me FROM messages WHERE chat_jid = ? ORDER BY timestamp DESC LIMIT ?",
+		"SELECT "+messageColumns+" FROM messages WHERE chat_jid = ? ORDER BY timestamp DESC LIMIT ?",
 		chatJID, limit,
 	)
 	if err != nil {
@@ -138,8 +307,7 @@ func (s *db) GetMessages(ctx context.Context, chatJID string, limit int) ([]mode
 
 	var messages []models.Message
 	for rows.Next() {
-		msg := models.Message{}
-		err := rows.Scan(&msg.ID, &msg.ChatJID, &msg.Sender, &msg.Content, &msg.Timestamp, &msg.IsFromMe)
+		msg, err := scanMessage(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -185,3 +353,341 @@ func (s *db) GetChat(ctx context.Context, jid string) (*models.Chat, error) {
 	}
 	return chat, nil
 }
+
+// GetMessage retrieves a specific message from a chat
+func (s *db) GetMessage(ctx context.Context, chatJID string, id string) (*models.Message, error) {
+	msg, err := scanMessage(s.db.QueryRowContext(ctx,
+		"SELECT "+messageColumns+" FROM messages WHERE chat_jid = ? AND id = ?",
+		chatJID, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// StoreReaction stores a reaction in the database, replacing any previous reaction from the same sender.
+// A reaction with an empty emoji is a removal and deletes the sender's reaction instead.
+func (s *db) StoreReaction(ctx context.Context, reaction models.Reaction) error {
+	if reaction.Emoji == "" {
+		_, err := s.db.ExecContext(ctx,
+			"DELETE FROM reactions WHERE message_id = ? AND chat_jid = ? AND sender = ?",
+			reaction.MessageID, reaction.ChatJID, reaction.Sender,
+		)
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO reactions
+		(message_id, chat_jid, sender, emoji, timestamp)
+		VALUES (?, ?, ?, ?, ?)`,
+		reaction.MessageID, reaction.ChatJID, reaction.Sender, reaction.Emoji, reaction.Timestamp,
+	)
+	return err
+}
+
+// StoreReceipt records a receipt, keeping the first delivery and read times per participant
+func (s *db) StoreReceipt(ctx context.Context, receipt models.Receipt) error {
+	var deliveredAt, readAt any
+	if receipt.Read {
+		readAt = receipt.Timestamp
+	} else {
+		deliveredAt = receipt.Timestamp
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO receipts (message_id, chat_jid, participant, delivered_at, read_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (message_id, chat_jid, participant) DO UPDATE SET
+			delivered_at = COALESCE(receipts.delivered_at, excluded.delivered_at),
+			read_at = COALESCE(receipts.read_at, excluded.read_at)`,
+		receipt.MessageID, receipt.ChatJID, receipt.Participant, deliveredAt, readAt,
+	)
+	return err
+}
+
+// CountMessages counts all messages, media messages and media messages with a downloaded file
+func (s *db) CountMessages(ctx context.Context) (total int, media int, downloaded int, err error) {
+	err = s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*), COUNT(media_type), COUNT(media_path) FROM messages`,
+	).Scan(&total, &media, &downloaded)
+	return total, media, downloaded, err
+}
+
+// Conditions on the chats table selecting chats by type, shared by the counts and by
+// filters on the chat type so they agree. Status updates, newsletters and broadcast
+// pseudo-chats are neither direct nor group chats.
+const (
+	DirectChatCondition = "chats.jid LIKE '%@s.whatsapp.net'"
+	GroupChatCondition  = "chats.jid LIKE '%@g.us'"
+)
+
+// CountChatsAndMessages counts direct chats, group chats and messages, leaving Contacts unset.
+// Each figure is a separate COUNT so none of them needs to read message rows.
+func (s *db) CountChatsAndMessages(ctx context.Context) (models.Counts, error) {
+	var counts models.Counts
+
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM chats WHERE "+DirectChatCondition).Scan(&counts.DirectChats)
+	if err != nil {
+		return counts, err
+	}
+
+	err = s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM chats WHERE "+GroupChatCondition).Scan(&counts.GroupChats)
+	if err != nil {
+		return counts, err
+	}
+
+	err = s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM messages`).Scan(&counts.Messages)
+	return counts, err
+}
+
+// GetMessagesMissingMedia retrieves media messages that have download metadata but no downloaded file.
+// An empty chatJID searches all chats.
+func (s *db) GetMessagesMissingMedia(ctx context.Context, chatJID string, limit int) ([]models.Message, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+messageColumns+` FROM messages
+		WHERE media_type IS NOT NULL AND media_path IS NULL
+		AND (direct_path IS NOT NULL OR url IS NOT NULL)
+		AND (? = '' OR chat_jid = ?)
+		ORDER BY timestamp DESC
+		LIMIT ?`,
+		chatJID, chatJID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []models.Message
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+
+	return messages, rows.Err()
+}
+
+// UpdateMediaPath sets the local media file path of a message
+func (s *db) UpdateMediaPath(ctx context.Context, chatJID string, id string, mediaPath string) error {
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE messages SET media_path = ? WHERE chat_jid = ? AND id = ?",
+		nullString(mediaPath), chatJID, id,
+	)
+	return err
+}
+
+// GetUnreadMessages retrieves the received messages of a chat not yet marked read, oldest first
+func (s *db) GetUnreadMessages(ctx context.Context, chatJID string) ([]models.Message, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+messageColumns+` FROM messages
+		WHERE chat_jid = ? AND is_from_me = 0 AND COALESCE(is_read, 0) = 0
+		ORDER BY timestamp`,
+		chatJID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []models.Message
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+
+	return messages, rows.Err()
+}
+
+// MarkMessagesRead flags messages of a chat as read
+func (s *db) MarkMessagesRead(ctx context.Context, chatJID string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	params := make([]any, 0, len(ids)+1)
+	params = append(params, chatJID)
+	for _, id := range ids {
+		params = append(params, id)
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE messages SET is_read = 1 WHERE chat_jid = ? AND id IN (?"+strings.Repeat(", ?", len(ids)-1)+")",
+		params...,
+	)
+	return err
+}
+
+// GetLabels retrieves all labels
+func (s *db) GetLabels(ctx context.Context) ([]models.Label, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, name, color, synced FROM labels ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var labels []models.Label
+	for rows.Next() {
+		label := models.Label{}
+		err := rows.Scan(&label.ID, &label.Name, &label.Color, &label.Synced)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, label)
+	}
+
+	return labels, rows.Err()
+}
+
+// NextLabelID returns an unused label ID. WhatsApp label IDs are numeric strings.
+func (s *db) NextLabelID(ctx context.Context) (string, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(CAST(id AS INTEGER)), 0) + 1 FROM labels").Scan(&id)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprint(id), nil
+}
+
+// StoreLabel stores a label in the database
+func (s *db) StoreLabel(ctx context.Context, label models.Label) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT OR REPLACE INTO labels (id, name, color, synced) VALUES (?, ?, ?, ?)",
+		label.ID, label.Name, label.Color, label.Synced,
+	)
+	return err
+}
+
+// DeleteLabel deletes a label and removes it from all chats
+func (s *db) DeleteLabel(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM chat_labels WHERE label_id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, "DELETE FROM labels WHERE id = ?", id)
+	return err
+}
+
+// SetChatLabel assigns a label to a chat or removes it
+func (s *db) SetChatLabel(ctx context.Context, chatJID string, labelID string, assigned bool) error {
+	if !assigned {
+		_, err := s.db.ExecContext(ctx, "DELETE FROM chat_labels WHERE chat_jid = ? AND label_id = ?", chatJID, labelID)
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		"INSERT OR IGNORE INTO chat_labels (chat_jid, label_id) VALUES (?, ?)",
+		chatJID, labelID,
+	)
+	return err
+}
+
+// StoreRawMessage stores the raw proto of a message, replacing any earlier copy
+func (s *db) StoreRawMessage(ctx context.Context, raw models.RawMessage) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO raw_messages (message_id, chat_jid, raw, size, truncated, received_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		raw.MessageID, raw.ChatJID, raw.Raw, raw.Size, raw.Truncated, raw.ReceivedAt,
+	)
+	return err
+}
+
+// initialSyncState names the sync_state row recording the history sync done on first login
+const initialSyncState = "initial"
+
+// GetInitialSync returns when the first login history sync was done, or nil if it was not
+func (s *db) GetInitialSync(ctx context.Context) (*time.Time, error) {
+	var completedAt time.Time
+	err := s.db.QueryRowContext(ctx,
+		"SELECT completed_at FROM sync_state WHERE name = ?",
+		initialSyncState,
+	).Scan(&completedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &completedAt, nil
+}
+
+// SetInitialSync records that the first login history sync was done at the given time
+func (s *db) SetInitialSync(ctx context.Context, at time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		"INSERT OR REPLACE INTO sync_state (name, completed_at) VALUES (?, ?)",
+		initialSyncState, at,
+	)
+	return err
+}
+
+// SetGroupParticipants replaces the stored members of a group
+func (s *db) SetGroupParticipants(ctx context.Context, groupJID string, participants []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM group_participants WHERE group_jid = ?", groupJID)
+	if err != nil {
+		return err
+	}
+
+	if err := addGroupParticipants(ctx, tx, groupJID, participants); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateGroupParticipants adds the members who joined a group and removes those who left
+func (s *db) UpdateGroupParticipants(ctx context.Context, groupJID string, joined []string, left []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := addGroupParticipants(ctx, tx, groupJID, joined); err != nil {
+		return err
+	}
+
+	for _, participant := range left {
+		_, err := tx.ExecContext(ctx,
+			"DELETE FROM group_participants WHERE group_jid = ? AND participant_jid = ?",
+			groupJID, participant,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func addGroupParticipants(ctx context.Context, exec execer, groupJID string, participants []string) error {
+	for _, participant := range participants {
+		_, err := exec.ExecContext(ctx,
+			"INSERT OR IGNORE INTO group_participants (group_jid, participant_jid) VALUES (?, ?)",
+			groupJID, participant,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nullString maps empty strings to NULL so optional columns stay unset
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
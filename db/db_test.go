@@ -0,0 +1,306 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+)
+
+const testChatJID = "15550002222@s.whatsapp.net"
+
+// newTestDB opens a fresh database in a temporary directory
+func newTestDB(t *testing.T) DB {
+	t.Helper()
+
+	d, err := NewDB(context.Background(), t.TempDir(), Options{})
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	if err := d.StoreChat(context.Background(), models.Chat{JID: testChatJID, Name: "Alice", LastMessageTime: time.Now()}); err != nil {
+		t.Fatalf("StoreChat: %v", err)
+	}
+	return d
+}
+
+func TestStoreMessageRedelivered(t *testing.T) {
+	ctx := context.Background()
+	received := models.Message{
+		ID:        "3EB0A1",
+		ChatJID:   testChatJID,
+		Sender:    testChatJID,
+		Content:   "see you at 5",
+		Timestamp: time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC),
+		Type:      "text",
+	}
+
+	tests := []struct {
+		name string
+		// local changes the stored message the way the bridge does after receiving it
+		local func(t *testing.T, d DB)
+		check func(t *testing.T, d DB)
+	}{
+		{
+			name: "stays read",
+			local: func(t *testing.T, d DB) {
+				if err := d.MarkMessagesRead(ctx, testChatJID, []string{received.ID}); err != nil {
+					t.Fatalf("MarkMessagesRead: %v", err)
+				}
+			},
+			check: func(t *testing.T, d DB) {
+				unread, err := d.GetUnreadMessages(ctx, testChatJID)
+				if err != nil {
+					t.Fatalf("GetUnreadMessages: %v", err)
+				}
+				if len(unread) != 0 {
+					t.Errorf("redelivered message is unread again")
+				}
+			},
+		},
+		{
+			name: "stays edited",
+			local: func(t *testing.T, d DB) {
+				editedAt := received.Timestamp.Add(time.Minute)
+				edit := received
+				edit.Content = "see you at 6"
+				edit.Timestamp = editedAt
+				edit.EditedAt = &editedAt
+				if err := d.StoreMessage(ctx, edit); err != nil {
+					t.Fatalf("StoreMessage edit: %v", err)
+				}
+			},
+			check: func(t *testing.T, d DB) {
+				msg, err := d.GetMessage(ctx, testChatJID, received.ID)
+				if err != nil {
+					t.Fatalf("GetMessage: %v", err)
+				}
+				if msg.Content != "see you at 6" {
+					t.Errorf("content = %q, want the edited content", msg.Content)
+				}
+				if msg.EditedAt == nil || !msg.EditedAt.Equal(received.Timestamp.Add(time.Minute)) {
+					t.Errorf("edited at = %v, want %v", msg.EditedAt, received.Timestamp.Add(time.Minute))
+				}
+				// The edit changes the content, not when the message was sent
+				if !msg.Timestamp.Equal(received.Timestamp) {
+					t.Errorf("timestamp = %v, want %v", msg.Timestamp, received.Timestamp)
+				}
+			},
+		},
+		{
+			name: "keeps downloaded media",
+			local: func(t *testing.T, d DB) {
+				if err := d.UpdateMediaPath(ctx, testChatJID, received.ID, "/media/3EB0A1.jpg"); err != nil {
+					t.Fatalf("UpdateMediaPath: %v", err)
+				}
+			},
+			check: func(t *testing.T, d DB) {
+				msg, err := d.GetMessage(ctx, testChatJID, received.ID)
+				if err != nil {
+					t.Fatalf("GetMessage: %v", err)
+				}
+				if msg.MediaPath != "/media/3EB0A1.jpg" {
+					t.Errorf("media path = %q, want it kept", msg.MediaPath)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDB(t)
+
+			if err := d.StoreMessage(ctx, received); err != nil {
+				t.Fatalf("StoreMessage: %v", err)
+			}
+			tt.local(t, d)
+
+			// A history sync or retry delivers the original message again
+			if err := d.StoreMessage(ctx, received); err != nil {
+				t.Fatalf("StoreMessage again: %v", err)
+			}
+			tt.check(t, d)
+		})
+	}
+}
+
+func TestGetMessagesMissingMedia(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDB(t)
+
+	const otherChatJID = "15550003333@s.whatsapp.net"
+	if err := d.StoreChat(ctx, models.Chat{JID: otherChatJID, Name: "Bob", LastMessageTime: time.Now()}); err != nil {
+		t.Fatalf("StoreChat: %v", err)
+	}
+
+	start := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	media := func(id, chatJID string, offset time.Duration) models.Message {
+		return models.Message{
+			ID:         id,
+			ChatJID:    chatJID,
+			Sender:     chatJID,
+			Timestamp:  start.Add(offset),
+			Type:       "image",
+			MediaType:  "image",
+			MimeType:   "image/jpeg",
+			DirectPath: "/v/t62.7118-24/" + id,
+			MediaKey:   []byte("key"),
+		}
+	}
+
+	downloaded := media("M2", testChatJID, 2*time.Minute)
+	downloaded.MediaPath = "/media/M2.jpg"
+	noMetadata := media("M3", testChatJID, 3*time.Minute)
+	noMetadata.DirectPath = ""
+	urlOnly := media("M4", testChatJID, 4*time.Minute)
+	urlOnly.DirectPath = ""
+	urlOnly.URL = "https://mmg.whatsapp.net/M4"
+
+	messages := []models.Message{
+		media("M1", testChatJID, time.Minute),
+		downloaded,
+		noMetadata,
+		urlOnly,
+		{ID: "M5", ChatJID: testChatJID, Sender: testChatJID, Content: "text only", Timestamp: start.Add(5 * time.Minute), Type: "text"},
+		media("M6", otherChatJID, 6*time.Minute),
+	}
+	for _, msg := range messages {
+		if err := d.StoreMessage(ctx, msg); err != nil {
+			t.Fatalf("StoreMessage %s: %v", msg.ID, err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		chatJID string
+		limit   int
+		want    []string
+	}{
+		{name: "all chats, newest first", limit: 10, want: []string{"M6", "M4", "M1"}},
+		{name: "one chat", chatJID: testChatJID, limit: 10, want: []string{"M4", "M1"}},
+		{name: "limit", limit: 2, want: []string{"M6", "M4"}},
+		{name: "chat without media", chatJID: "15550009999@s.whatsapp.net", limit: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missing, err := d.GetMessagesMissingMedia(ctx, tt.chatJID, tt.limit)
+			if err != nil {
+				t.Fatalf("GetMessagesMissingMedia: %v", err)
+			}
+
+			var got []string
+			for _, msg := range missing {
+				got = append(got, msg.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("missing media = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountChatsAndMessages(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDB(t)
+
+	// newTestDB already stored the direct chat testChatJID
+	chats := []models.Chat{
+		{JID: "15550003333@s.whatsapp.net", Name: "Bob"},
+		{JID: "120363000000000001@g.us", Name: "Team"},
+		{JID: "120363000000000002@g.us", Name: "Family"},
+		{JID: "120363000000000003@g.us", Name: "Book club"},
+		{JID: "status@broadcast"},
+		{JID: "1741597200000@broadcast", Name: "Broadcast to 2 recipients"},
+		{JID: "120363000000000004@newsletter", Name: "News"},
+	}
+	for i := range chats {
+		chats[i].LastMessageTime = time.Now()
+		chats[i].Messages = []models.Message{{
+			ID:        fmt.Sprintf("M%d", i),
+			ChatJID:   chats[i].JID,
+			Sender:    chats[i].JID,
+			Content:   "hello",
+			Timestamp: time.Now(),
+		}}
+	}
+	if err := d.StoreBatch(ctx, chats); err != nil {
+		t.Fatalf("StoreBatch: %v", err)
+	}
+
+	counts, err := d.CountChatsAndMessages(ctx)
+	if err != nil {
+		t.Fatalf("CountChatsAndMessages: %v", err)
+	}
+
+	want := models.Counts{DirectChats: 2, GroupChats: 3, Messages: len(chats)}
+	if counts != want {
+		t.Errorf("counts = %+v, want %+v", counts, want)
+	}
+}
+
+func TestStoreReactionRemoved(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDB(t)
+	const bobJID = "15550003333@s.whatsapp.net"
+
+	reactions := func() []string {
+		t.Helper()
+		rows, err := d.(*db).db.QueryContext(ctx,
+			"SELECT sender, emoji FROM reactions WHERE message_id = ? AND chat_jid = ? ORDER BY sender",
+			"3EB0A1", testChatJID,
+		)
+		if err != nil {
+			t.Fatalf("query reactions: %v", err)
+		}
+		defer rows.Close()
+
+		got := []string{}
+		for rows.Next() {
+			var sender, emoji string
+			if err := rows.Scan(&sender, &emoji); err != nil {
+				t.Fatalf("scan reaction: %v", err)
+			}
+			got = append(got, sender+" "+emoji)
+		}
+		return got
+	}
+	react := func(sender string, emoji string) {
+		t.Helper()
+		err := d.StoreReaction(ctx, models.Reaction{
+			MessageID: "3EB0A1",
+			ChatJID:   testChatJID,
+			Sender:    sender,
+			Emoji:     emoji,
+			Timestamp: time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC),
+		})
+		if err != nil {
+			t.Fatalf("StoreReaction(%q, %q): %v", sender, emoji, err)
+		}
+	}
+
+	react(testChatJID, "👍")
+	react(bobJID, "😂")
+	react(testChatJID, "❤️")
+	if want := []string{testChatJID + " ❤️", bobJID + " 😂"}; !slices.Equal(reactions(), want) {
+		t.Fatalf("reactions = %v, want %v", reactions(), want)
+	}
+
+	// An empty emoji removes only the sender's own reaction
+	react(testChatJID, "")
+	if want := []string{bobJID + " 😂"}; !slices.Equal(reactions(), want) {
+		t.Fatalf("reactions after one removal = %v, want %v", reactions(), want)
+	}
+
+	react(bobJID, "")
+	if got := reactions(); len(got) != 0 {
+		t.Errorf("reactions after removing all = %v, want none", got)
+	}
+
+	// Removing a reaction that is already gone is not an error
+	react(bobJID, "")
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// migration adds a table or column introduced after the initial schema
+type migration struct {
+	table  string
+	column string // empty when the migration creates the table
+	// definition is the column type, or the column definitions of a new table
+	definition string
+}
+
+// migrations are applied in order, the schema version being the number of migrations applied.
+// Only ever append to this list.
+var migrations = []migration{
+	{"messages", "mentions_me", "BOOLEAN DEFAULT 0"},
+	{"messages", "message_type", "TEXT"},
+	{"messages", "raw_type", "TEXT"},
+	{"messages", "media_type", "TEXT"},
+	{"messages", "mime_type", "TEXT"},
+	{"messages", "media_path", "TEXT"},
+	{"messages", "file_length", "INTEGER"},
+	{"messages", "url", "TEXT"},
+	{"messages", "direct_path", "TEXT"},
+	{"messages", "media_key", "BLOB"},
+	{"messages", "file_sha256", "BLOB"},
+	{"messages", "file_enc_sha256", "BLOB"},
+	{"labels", "", "id TEXT PRIMARY KEY, name TEXT, color INTEGER, synced BOOLEAN"},
+	{"chat_labels", "", "chat_jid TEXT, label_id TEXT, PRIMARY KEY (chat_jid, label_id)"},
+	{"receipts", "", "message_id TEXT, chat_jid TEXT, participant TEXT, delivered_at TIMESTAMP, read_at TIMESTAMP, PRIMARY KEY (message_id, chat_jid, participant)"},
+	{"messages", "forwarding_score", "INTEGER"},
+	{"messages", "is_read", "BOOLEAN DEFAULT 0"},
+	{"messages", "edited_at", "TIMESTAMP"},
+	{"group_participants", "", "group_jid TEXT, participant_jid TEXT, PRIMARY KEY (group_jid, participant_jid)"},
+	{"raw_messages", "", "message_id TEXT, chat_jid TEXT, raw TEXT, size INTEGER, truncated BOOLEAN, received_at TIMESTAMP, PRIMARY KEY (message_id, chat_jid)"},
+	{"sync_state", "", "name TEXT PRIMARY KEY, completed_at TIMESTAMP"},
+}
+
+// SchemaVersion returns the schema version expected by this version of the code
+func SchemaVersion() int {
+	return len(migrations)
+}
+
+// migrate applies pending migrations and verifies the resulting schema
+func (s *db) migrate(ctx context.Context) error {
+	var version int
+	if err := s.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %v", err)
+	}
+
+	if version > len(migrations) {
+		return fmt.Errorf("database schema version %d is newer than supported version %d, please upgrade", version, len(migrations))
+	}
+
+	for _, m := range migrations[version:] {
+		if m.column == "" {
+			_, err := s.db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", m.table, m.definition))
+			if err != nil {
+				return fmt.Errorf("failed to create table %s: %v", m.table, err)
+			}
+			continue
+		}
+
+		if err := s.addColumn(ctx, m.table, m.column, m.definition); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %v", m.table, m.column, err)
+		}
+	}
+
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", len(migrations))); err != nil {
+		return fmt.Errorf("failed to update schema version: %v", err)
+	}
+
+	return s.verifySchema(ctx)
+}
+
+// verifySchema checks that every migrated table and column is present
+func (s *db) verifySchema(ctx context.Context) error {
+	for _, m := range migrations {
+		if m.column == "" {
+			var name string
+			err := s.db.QueryRowContext(ctx, "SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?", m.table).Scan(&name)
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("schema mismatch: table %s is missing after migration", m.table)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to inspect table %s: %v", m.table, err)
+			}
+			continue
+		}
+
+		exists, err := s.hasColumn(ctx, m.table, m.column)
+		if err != nil {
+			return fmt.Errorf("failed to inspect table %s: %v", m.table, err)
+		}
+		if !exists {
+			return fmt.Errorf("schema mismatch: column %s.%s is missing after migration", m.table, m.column)
+		}
+	}
+	return nil
+}
+
+// addColumn adds a column to a table unless it already exists
+func (s *db) addColumn(ctx context.Context, table, column, definition string) error {
+	exists, err := s.hasColumn(ctx, table, column)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+func (s *db) hasColumn(ctx context.Context, table, column string) (bool, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
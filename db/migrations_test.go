@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name string
+		// prepare runs on the database file before NewDB opens it
+		prepare func(t *testing.T, conn *sql.DB)
+		wantErr bool
+	}{
+		{
+			name:    "fresh database",
+			prepare: func(t *testing.T, conn *sql.DB) {},
+		},
+		{
+			name: "stale schema",
+			prepare: func(t *testing.T, conn *sql.DB) {
+				// The original schema, from before any migration was versioned
+				exec(t, conn, "CREATE TABLE chats (jid TEXT PRIMARY KEY, name TEXT, last_message_time TIMESTAMP)")
+				exec(t, conn, `CREATE TABLE messages (id TEXT, chat_jid TEXT, sender TEXT, content TEXT,
+					timestamp TIMESTAMP, is_from_me BOOLEAN, PRIMARY KEY (id, chat_jid))`)
+			},
+		},
+		{
+			name: "partially migrated",
+			prepare: func(t *testing.T, conn *sql.DB) {
+				ctx := context.Background()
+				d := &db{conn}
+				if err := d.initDB(ctx); err != nil {
+					t.Fatalf("initDB: %v", err)
+				}
+				exec(t, conn, fmt.Sprintf("PRAGMA user_version = %d", SchemaVersion()-1))
+			},
+		},
+		{
+			name: "newer than supported",
+			prepare: func(t *testing.T, conn *sql.DB) {
+				exec(t, conn, fmt.Sprintf("PRAGMA user_version = %d", SchemaVersion()+1))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			conn, err := sql.Open("sqlite3", filepath.Join(dir, "messages.db"))
+			if err != nil {
+				t.Fatalf("sql.Open: %v", err)
+			}
+			tt.prepare(t, conn)
+			conn.Close()
+
+			d, err := NewDB(context.Background(), dir, Options{})
+			if tt.wantErr {
+				if err == nil {
+					d.Close()
+					t.Fatal("NewDB succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewDB: %v", err)
+			}
+			defer d.Close()
+
+			var version int
+			if err := d.(*db).db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+				t.Fatalf("read user_version: %v", err)
+			}
+			if version != SchemaVersion() {
+				t.Errorf("schema version = %d, want %d", version, SchemaVersion())
+			}
+			if err := d.(*db).verifySchema(context.Background()); err != nil {
+				t.Errorf("verifySchema: %v", err)
+			}
+		})
+	}
+}
+
+func exec(t *testing.T, conn *sql.DB, query string) {
+	t.Helper()
+	if _, err := conn.Exec(query); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
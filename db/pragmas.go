@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// allowedPragmas lists the pragmas operators may tune. They only affect performance and
+// durability trade-offs, never the schema or integrity checks the bridge relies on.
+var allowedPragmas = map[string]bool{
+	"cache_size":         true,
+	"mmap_size":          true,
+	"synchronous":        true,
+	"temp_store":         true,
+	"busy_timeout":       true,
+	"wal_autocheckpoint": true,
+	"journal_size_limit": true,
+}
+
+// pragmaValue accepts plain integers and keywords such as NORMAL or MEMORY
+var pragmaValue = regexp.MustCompile(`^(-?[0-9]+|[A-Za-z]+)$`)
+
+// parsePragma validates a "name=value" pragma setting against the allowlist
+func parsePragma(setting string) (string, string, error) {
+	name, value, ok := strings.Cut(setting, "=")
+	if !ok {
+		return "", "", fmt.Errorf("invalid pragma %q, expected name=value", setting)
+	}
+
+	name = strings.ToLower(strings.TrimSpace(name))
+	value = strings.TrimSpace(value)
+	if !allowedPragmas[name] {
+		return "", "", fmt.Errorf("pragma %q is not allowed", name)
+	}
+	if !pragmaValue.MatchString(value) {
+		return "", "", fmt.Errorf("invalid value %q for pragma %s", value, name)
+	}
+
+	return name, value, nil
+}
+
+// pragmaStatements validates custom pragmas and returns the statements applying them.
+// Nothing is returned unless all of them are valid.
+func pragmaStatements(pragmas []string) ([]string, error) {
+	statements := make([]string, 0, len(pragmas))
+	for _, setting := range pragmas {
+		name, value, err := parsePragma(setting)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, fmt.Sprintf("PRAGMA %s = %s;", name, value))
+	}
+	return statements, nil
+}
+
+// pragmaConnector opens connections to dsn and runs the pragma statements on each of them.
+// The allowed pragmas only affect the connection that sets them, and database/sql opens
+// new connections as needed, so setting them once on the pool is not enough.
+type pragmaConnector struct {
+	driver *sqlite3.SQLiteDriver
+	dsn    string
+}
+
+func newPragmaConnector(dsn string, statements []string) *pragmaConnector {
+	return &pragmaConnector{
+		dsn: dsn,
+		driver: &sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				for _, statement := range statements {
+					if _, err := conn.Exec(statement, nil); err != nil {
+						return fmt.Errorf("failed to apply %s: %v", statement, err)
+					}
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// Connect opens a new connection with the pragmas applied
+func (c *pragmaConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+// Driver returns the underlying SQLite driver
+func (c *pragmaConnector) Driver() driver.Driver {
+	return c.driver
+}
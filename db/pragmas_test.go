@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParsePragma(t *testing.T) {
+	tests := []struct {
+		setting   string
+		wantName  string
+		wantValue string
+		wantErr   bool
+	}{
+		{setting: "cache_size=-20000", wantName: "cache_size", wantValue: "-20000"},
+		{setting: " Synchronous = NORMAL ", wantName: "synchronous", wantValue: "NORMAL"},
+		{setting: "busy_timeout=5000", wantName: "busy_timeout", wantValue: "5000"},
+		{setting: "temp_store=MEMORY", wantName: "temp_store", wantValue: "MEMORY"},
+		{setting: "cache_size", wantErr: true},
+		{setting: "foreign_keys=OFF", wantErr: true},
+		{setting: "journal_mode=DELETE", wantErr: true},
+		{setting: "cache_size=1; DROP TABLE messages", wantErr: true},
+		{setting: "mmap_size=", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		name, value, err := parsePragma(tt.setting)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parsePragma(%q) succeeded, want an error", tt.setting)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parsePragma(%q): %v", tt.setting, err)
+			continue
+		}
+		if name != tt.wantName || value != tt.wantValue {
+			t.Errorf("parsePragma(%q) = %s, %s, want %s, %s", tt.setting, name, value, tt.wantName, tt.wantValue)
+		}
+	}
+}
+
+func TestPragmasApplyToEveryConnection(t *testing.T) {
+	ctx := context.Background()
+
+	d, err := NewDB(ctx, t.TempDir(), Options{Pragmas: []string{"cache_size=-1234", "busy_timeout=4321"}})
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer d.Close()
+
+	// Hold several connections at once so the pool has to open new ones
+	pool := d.(*db).db
+	for i := range 3 {
+		conn, err := pool.Conn(ctx)
+		if err != nil {
+			t.Fatalf("connection %d: %v", i, err)
+		}
+		defer conn.Close()
+
+		var cacheSize, busyTimeout int
+		if err := conn.QueryRowContext(ctx, "PRAGMA cache_size").Scan(&cacheSize); err != nil {
+			t.Fatalf("connection %d: read cache_size: %v", i, err)
+		}
+		if err := conn.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&busyTimeout); err != nil {
+			t.Fatalf("connection %d: read busy_timeout: %v", i, err)
+		}
+		if cacheSize != -1234 || busyTimeout != 4321 {
+			t.Errorf("connection %d: cache_size = %d, busy_timeout = %d, want -1234, 4321", i, cacheSize, busyTimeout)
+		}
+	}
+}
+
+func TestInvalidPragmasRejected(t *testing.T) {
+	d, err := NewDB(context.Background(), t.TempDir(), Options{Pragmas: []string{"cache_size=100", "foreign_keys=OFF"}})
+	if err == nil {
+		d.Close()
+		t.Fatal("NewDB succeeded with a disallowed pragma")
+	}
+}
@@ -9,6 +9,34 @@ import (
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
+// dateRangeArgument parses an optional [start, end] array of RFC3339 dates
+func dateRangeArgument(arguments map[string]interface{}) []time.Time {
+	dr, ok := arguments["date_range"].([]interface{})
+	if !ok || len(dr) != 2 {
+		return nil
+	}
+
+	startStr, ok := dr[0].(string)
+	if !ok {
+		return nil
+	}
+	start, err := time.Parse(time.RFC3339, startStr)
+	if err != nil {
+		return nil
+	}
+
+	endStr, ok := dr[1].(string)
+	if !ok {
+		return nil
+	}
+	end, err := time.Parse(time.RFC3339, endStr)
+	if err != nil {
+		return nil
+	}
+
+	return []time.Time{start, end}
+}
+
 func searchContactsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	query, ok := request.Params.Arguments["query"].(string)
 	if !ok {
@@ -28,26 +56,36 @@ func searchContactsHandler(ctx context.Context, request mcp.CallToolRequest) (*m
 	return mcp.NewToolResultText(string(contactsData)), nil
 }
 
+func listChatJIDsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var query string
+	if q, ok := request.Params.Arguments["query"].(string); ok {
+		query = q
+	}
+
+	chats, err := ListChatJIDs(query)
+	if err != nil {
+		return nil, err
+	}
+
+	chatsData, err := json.Marshal(chats)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(chatsData)), nil
+}
+
 func listMessagesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	var dateRange []time.Time
-	var senderPhoneNumber, chatJID, query string
+	var senderPhoneNumber, chatJID, query, matchMode, messageType string
 	limit := 20
 	page := 0
-	includeContext := true
+	includeContext := false
 	contextBefore := 1
 	contextAfter := 1
+	maxContext := defaultMaxContext
+	groupByChat := false
 
-	if dr, ok := request.Params.Arguments["date_range"].([]interface{}); ok && len(dr) == 2 {
-		if startStr, ok := dr[0].(string); ok {
-			if start, err := time.Parse(time.RFC3339, startStr); err == nil {
-				if endStr, ok := dr[1].(string); ok {
-					if end, err := time.Parse(time.RFC3339, endStr); err == nil {
-						dateRange = []time.Time{start, end}
-					}
-				}
-			}
-		}
-	}
+	dateRange := dateRangeArgument(request.Params.Arguments)
 
 	if s, ok := request.Params.Arguments["sender_phone_number"].(string); ok {
 		senderPhoneNumber = s
@@ -61,6 +99,14 @@ func listMessagesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp
 		query = q
 	}
 
+	if m, ok := request.Params.Arguments["match_mode"].(string); ok {
+		matchMode = m
+	}
+
+	if t, ok := request.Params.Arguments["message_type"].(string); ok {
+		messageType = t
+	}
+
 	if l, ok := request.Params.Arguments["limit"].(float64); ok {
 		limit = int(l)
 	}
@@ -81,12 +127,44 @@ func listMessagesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp
 		contextAfter = int(ca)
 	}
 
-	messages, err := ListMessages(dateRange, senderPhoneNumber, chatJID, query, limit, page, includeContext, contextBefore, contextAfter)
+	if mc, ok := request.Params.Arguments["max_context"].(float64); ok {
+		maxContext = int(mc)
+	}
+
+	if gbc, ok := request.Params.Arguments["group_by_chat"].(bool); ok {
+		groupByChat = gbc
+	}
+
+	if groupByChat {
+		groups, truncated, err := ListMessagesByChat(dateRange, senderPhoneNumber, chatJID, query, matchMode, messageType, limit, page, includeContext, contextBefore, contextAfter, maxContext)
+		if err != nil {
+			return nil, err
+		}
+
+		var result interface{} = groups
+		if includeContext {
+			result = ChatMessageResults{Chats: groups, ContextTruncated: truncated}
+		}
+
+		groupsData, err := json.Marshal(result)
+		if err != nil {
+			return nil, err
+		}
+
+		return mcp.NewToolResultText(string(groupsData)), nil
+	}
+
+	messages, truncated, err := ListMessages(dateRange, senderPhoneNumber, chatJID, query, matchMode, messageType, limit, page, includeContext, contextBefore, contextAfter, maxContext)
 	if err != nil {
 		return nil, err
 	}
 
-	messagesData, err := json.Marshal(messages)
+	var result interface{} = messages
+	if includeContext {
+		result = MessageResults{Messages: messages, ContextTruncated: truncated}
+	}
+
+	messagesData, err := json.Marshal(result)
 	if err != nil {
 		return nil, err
 	}
@@ -94,12 +172,46 @@ func listMessagesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp
 	return mcp.NewToolResultText(string(messagesData)), nil
 }
 
+func listMessagesMultiHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	rawChatJIDs, ok := request.Params.Arguments["chat_jids"].([]interface{})
+	if !ok {
+		return nil, errors.New("chat_jids must be an array")
+	}
+
+	chatJIDs := make([]string, 0, len(rawChatJIDs))
+	for _, c := range rawChatJIDs {
+		chatJID, ok := c.(string)
+		if !ok {
+			return nil, errors.New("chat_jids must be strings")
+		}
+		chatJIDs = append(chatJIDs, chatJID)
+	}
+
+	perChat := 10
+	if l, ok := request.Params.Arguments["limit_per_chat"].(float64); ok {
+		perChat = int(l)
+	}
+
+	groups, err := ListMessagesMulti(chatJIDs, perChat)
+	if err != nil {
+		return nil, err
+	}
+
+	groupsData, err := json.Marshal(groups)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(groupsData)), nil
+}
+
 func listChatsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	var query string
 	limit := 20
 	page := 0
 	includeLastMessage := true
 	sortBy := "last_active"
+	chatType := "all"
 
 	if q, ok := request.Params.Arguments["query"].(string); ok {
 		query = q
@@ -121,7 +233,11 @@ func listChatsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.Ca
 		sortBy = sb
 	}
 
-	chats, err := ListChats(query, limit, page, includeLastMessage, sortBy)
+	if ct, ok := request.Params.Arguments["chat_type"].(string); ok {
+		chatType = ct
+	}
+
+	chats, err := ListChats(query, limit, page, includeLastMessage, sortBy, chatType)
 	if err != nil {
 		return nil, err
 	}
@@ -206,6 +322,73 @@ func getContactChatsHandler(ctx context.Context, request mcp.CallToolRequest) (*
 	return mcp.NewToolResultText(string(chatsData)), nil
 }
 
+func getRawMessageHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	messageID, ok := request.Params.Arguments["message_id"].(string)
+	if !ok {
+		return nil, errors.New("message_id must be a string")
+	}
+
+	var chatJID string
+	if c, ok := request.Params.Arguments["chat_jid"].(string); ok {
+		chatJID = c
+	}
+
+	raw, err := GetRawMessage(messageID, chatJID)
+	if err != nil {
+		return nil, err
+	}
+
+	rawData, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(rawData)), nil
+}
+
+func listLargeMediaHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	minSize := int64(1024 * 1024)
+	if m, ok := request.Params.Arguments["min_size_bytes"].(float64); ok {
+		minSize = int64(m)
+	}
+
+	limit := 20
+	if l, ok := request.Params.Arguments["limit"].(float64); ok {
+		limit = int(l)
+	}
+
+	media, err := ListLargeMedia(minSize, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaData, err := json.Marshal(media)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(mediaData)), nil
+}
+
+func getSharedChatsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	jid, ok := request.Params.Arguments["jid"].(string)
+	if !ok {
+		return nil, errors.New("jid must be a string")
+	}
+
+	chats, err := GetSharedChats(jid)
+	if err != nil {
+		return nil, err
+	}
+
+	chatsData, err := json.Marshal(chats)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(chatsData)), nil
+}
+
 func getLastInteractionHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	jid, ok := request.Params.Arguments["jid"].(string)
 	if !ok {
@@ -225,6 +408,25 @@ func getLastInteractionHandler(ctx context.Context, request mcp.CallToolRequest)
 	return mcp.NewToolResultText(string(messageData)), nil
 }
 
+func getFirstInteractionHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	jid, ok := request.Params.Arguments["jid"].(string)
+	if !ok {
+		return nil, errors.New("jid must be a string")
+	}
+
+	message, err := GetFirstInteraction(jid)
+	if err != nil {
+		return nil, err
+	}
+
+	messageData, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(messageData)), nil
+}
+
 func getMessageContextHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	messageID, ok := request.Params.Arguments["message_id"].(string)
 	if !ok {
@@ -254,6 +456,79 @@ func getMessageContextHandler(ctx context.Context, request mcp.CallToolRequest)
 	return mcp.NewToolResultText(string(contextData)), nil
 }
 
+func getMessagesAroundTimeHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	chatJID, ok := request.Params.Arguments["chat_jid"].(string)
+	if !ok {
+		return nil, errors.New("chat_jid must be a string")
+	}
+
+	timestampStr, ok := request.Params.Arguments["timestamp"].(string)
+	if !ok {
+		return nil, errors.New("timestamp must be a string")
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, timestampStr)
+	if err != nil {
+		return nil, errors.New("timestamp must be an RFC3339 timestamp")
+	}
+
+	before := 5
+	if b, ok := request.Params.Arguments["before"].(float64); ok {
+		before = int(b)
+	}
+
+	after := 5
+	if a, ok := request.Params.Arguments["after"].(float64); ok {
+		after = int(a)
+	}
+
+	context, err := GetMessagesAroundTime(chatJID, timestamp, before, after)
+	if err != nil {
+		return nil, err
+	}
+
+	contextData, err := json.Marshal(context)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(contextData)), nil
+}
+
+func scrollMessagesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	chatJID, ok := request.Params.Arguments["chat_jid"].(string)
+	if !ok {
+		return nil, errors.New("chat_jid must be a string")
+	}
+
+	messageID, ok := request.Params.Arguments["message_id"].(string)
+	if !ok {
+		return nil, errors.New("message_id must be a string")
+	}
+
+	direction, ok := request.Params.Arguments["direction"].(string)
+	if !ok {
+		return nil, errors.New("direction must be a string")
+	}
+
+	count := 10
+	if c, ok := request.Params.Arguments["count"].(float64); ok {
+		count = int(c)
+	}
+
+	page, err := ScrollMessages(chatJID, messageID, direction, count)
+	if err != nil {
+		return nil, err
+	}
+
+	pageData, err := json.Marshal(page)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(pageData)), nil
+}
+
 func sendMessageHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	recipient, ok := request.Params.Arguments["recipient"].(string)
 	if !ok {
@@ -265,13 +540,100 @@ func sendMessageHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.
 		return nil, errors.New("message must be a string")
 	}
 
-	success, statusMessage := SendMessage(recipient, message)
+	withPreview := false
+	if wp, ok := request.Params.Arguments["with_preview"].(bool); ok {
+		withPreview = wp
+	}
+
+	quotedMessageID := ""
+	if q, ok := request.Params.Arguments["quoted_message_id"].(string); ok {
+		quotedMessageID = q
+	}
+
+	expireSeconds := 0
+	if e, ok := request.Params.Arguments["expire_seconds"].(float64); ok {
+		expireSeconds = int(e)
+	}
+
+	requireKnownContact := false
+	if rk, ok := request.Params.Arguments["require_known_contact"].(bool); ok {
+		requireKnownContact = rk
+	}
+
+	messageID := ""
+	if id, ok := request.Params.Arguments["message_id"].(string); ok {
+		messageID = id
+	}
+
+	success, statusMessage, sendResult := SendMessage(recipient, message, withPreview, quotedMessageID, expireSeconds, requireKnownContact, messageID)
 
 	result := map[string]interface{}{
 		"success": success,
 		"message": statusMessage,
 	}
 
+	if success {
+		result["message_id"] = sendResult.MessageID
+		if sendResult.Delivery != nil {
+			result["delivery"] = sendResult.Delivery
+		}
+	}
+
+	if requireKnownContact && success {
+		result["recipient_name"] = sendResult.RecipientName
+	}
+
+	if quotedMessageID != "" && success {
+		result["quoted_snippet"] = sendResult.QuotedSnippet
+		result["quoted_sender"] = sendResult.QuotedSender
+	}
+
+	resultData, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(resultData)), nil
+}
+
+func replyToLastHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	chatJID, ok := request.Params.Arguments["chat_jid"].(string)
+	if !ok {
+		return nil, errors.New("chat_jid must be a string")
+	}
+
+	message, ok := request.Params.Arguments["message"].(string)
+	if !ok {
+		return nil, errors.New("message must be a string")
+	}
+
+	withPreview := false
+	if wp, ok := request.Params.Arguments["with_preview"].(bool); ok {
+		withPreview = wp
+	}
+
+	quotedMessageID, err := GetLatestMessageID(chatJID)
+	if err != nil {
+		return nil, err
+	}
+
+	success, statusMessage, sendResult := SendMessage(chatJID, message, withPreview, quotedMessageID, 0, false, "")
+
+	result := map[string]interface{}{
+		"success":           success,
+		"message":           statusMessage,
+		"quoted_message_id": quotedMessageID,
+	}
+
+	if success {
+		result["message_id"] = sendResult.MessageID
+		result["quoted_snippet"] = sendResult.QuotedSnippet
+		result["quoted_sender"] = sendResult.QuotedSender
+		if sendResult.Delivery != nil {
+			result["delivery"] = sendResult.Delivery
+		}
+	}
+
 	resultData, err := json.Marshal(result)
 	if err != nil {
 		return nil, err
@@ -279,3 +641,608 @@ func sendMessageHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.
 
 	return mcp.NewToolResultText(string(resultData)), nil
 }
+
+func sendReactionHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	chatJID, ok := request.Params.Arguments["chat_jid"].(string)
+	if !ok {
+		return nil, errors.New("chat_jid must be a string")
+	}
+
+	messageID, ok := request.Params.Arguments["message_id"].(string)
+	if !ok {
+		return nil, errors.New("message_id must be a string")
+	}
+
+	emoji, ok := request.Params.Arguments["emoji"].(string)
+	if !ok {
+		return nil, errors.New("emoji must be a string")
+	}
+
+	success, statusMessage := SendReaction(chatJID, messageID, emoji)
+
+	result := map[string]interface{}{
+		"success": success,
+		"message": statusMessage,
+	}
+
+	resultData, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(resultData)), nil
+}
+
+func getMessageReactionsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	chatJID, ok := request.Params.Arguments["chat_jid"].(string)
+	if !ok {
+		return nil, errors.New("chat_jid must be a string")
+	}
+
+	messageID, ok := request.Params.Arguments["message_id"].(string)
+	if !ok {
+		return nil, errors.New("message_id must be a string")
+	}
+
+	reactions, err := GetMessageReactions(chatJID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	reactionsData, err := json.Marshal(reactions)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(reactionsData)), nil
+}
+
+func listMentionsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	limit := 20
+	if l, ok := request.Params.Arguments["limit"].(float64); ok {
+		limit = int(l)
+	}
+
+	page := 0
+	if p, ok := request.Params.Arguments["page"].(float64); ok {
+		page = int(p)
+	}
+
+	messages, err := ListMentions(limit, page)
+	if err != nil {
+		return nil, err
+	}
+
+	messagesData, err := json.Marshal(messages)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(messagesData)), nil
+}
+
+func retryMediaDownloadHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var chatJID string
+	if c, ok := request.Params.Arguments["chat_jid"].(string); ok {
+		chatJID = c
+	}
+
+	limit := 20
+	if l, ok := request.Params.Arguments["limit"].(float64); ok {
+		limit = int(l)
+	}
+
+	results, err := RetryMediaDownload(chatJID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsData, err := json.Marshal(results)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(resultsData)), nil
+}
+
+func getChatParticipantsActivityHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	chatJID, ok := request.Params.Arguments["chat_jid"].(string)
+	if !ok {
+		return nil, errors.New("chat_jid must be a string")
+	}
+
+	dateRange := dateRangeArgument(request.Params.Arguments)
+
+	minMessages := 0
+	if m, ok := request.Params.Arguments["min_messages"].(float64); ok {
+		minMessages = int(m)
+	}
+
+	participants, err := GetChatParticipantsActivity(chatJID, dateRange, minMessages)
+	if err != nil {
+		return nil, err
+	}
+
+	participantsData, err := json.Marshal(participants)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(participantsData)), nil
+}
+
+func getConversationBalanceHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	chatJID, ok := request.Params.Arguments["chat_jid"].(string)
+	if !ok {
+		return nil, errors.New("chat_jid must be a string")
+	}
+
+	dateRange := dateRangeArgument(request.Params.Arguments)
+
+	balance, err := GetConversationBalance(chatJID, dateRange)
+	if err != nil {
+		return nil, err
+	}
+
+	balanceData, err := json.Marshal(balance)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(balanceData)), nil
+}
+
+func findUnansweredQuestionsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	chatJID, ok := request.Params.Arguments["chat_jid"].(string)
+	if !ok {
+		return nil, errors.New("chat_jid must be a string")
+	}
+
+	windowHours := 24.0
+	if w, ok := request.Params.Arguments["window_hours"].(float64); ok {
+		windowHours = w
+	}
+
+	heuristic := ""
+	if h, ok := request.Params.Arguments["heuristic"].(string); ok {
+		heuristic = h
+	}
+
+	limit := 20
+	if l, ok := request.Params.Arguments["limit"].(float64); ok {
+		limit = int(l)
+	}
+
+	dateRange := dateRangeArgument(request.Params.Arguments)
+
+	window := time.Duration(windowHours * float64(time.Hour))
+	questions, err := FindUnansweredQuestions(chatJID, window, heuristic, dateRange, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	questionsData, err := json.Marshal(questions)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(questionsData)), nil
+}
+
+func exportContactsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	format := "json"
+	if f, ok := request.Params.Arguments["format"].(string); ok {
+		format = f
+	}
+
+	contacts, err := ExportContacts(format)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(contacts), nil
+}
+
+func getLabelsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	labels, err := GetLabels()
+	if err != nil {
+		return nil, err
+	}
+
+	labelsData, err := json.Marshal(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(labelsData)), nil
+}
+
+func createLabelHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, ok := request.Params.Arguments["name"].(string)
+	if !ok {
+		return nil, errors.New("name must be a string")
+	}
+
+	color := 0
+	if c, ok := request.Params.Arguments["color"].(float64); ok {
+		color = int(c)
+	}
+
+	label, err := CreateLabel(name, color)
+	if err != nil {
+		return nil, err
+	}
+
+	labelData, err := json.Marshal(label)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(labelData)), nil
+}
+
+func assignLabelHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	chatJID, ok := request.Params.Arguments["chat_jid"].(string)
+	if !ok {
+		return nil, errors.New("chat_jid must be a string")
+	}
+
+	labelID, ok := request.Params.Arguments["label_id"].(string)
+	if !ok {
+		return nil, errors.New("label_id must be a string")
+	}
+
+	assigned := true
+	if a, ok := request.Params.Arguments["assigned"].(bool); ok {
+		assigned = a
+	}
+
+	success, statusMessage := AssignLabel(chatJID, labelID, assigned)
+
+	result := map[string]interface{}{
+		"success": success,
+		"message": statusMessage,
+	}
+
+	resultData, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(resultData)), nil
+}
+
+func getMessageHistogramHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	chatJID, ok := request.Params.Arguments["chat_jid"].(string)
+	if !ok {
+		return nil, errors.New("chat_jid must be a string")
+	}
+
+	dateRange := dateRangeArgument(request.Params.Arguments)
+
+	granularity := "day"
+	if g, ok := request.Params.Arguments["granularity"].(string); ok {
+		granularity = g
+	}
+
+	histogram, err := GetMessageHistogram(chatJID, dateRange, granularity)
+	if err != nil {
+		return nil, err
+	}
+
+	histogramData, err := json.Marshal(histogram)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(histogramData)), nil
+}
+
+func detectActivitySpikesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	chatJID, ok := request.Params.Arguments["chat_jid"].(string)
+	if !ok {
+		return nil, errors.New("chat_jid must be a string")
+	}
+
+	recentDays := 7
+	if d, ok := request.Params.Arguments["recent_days"].(float64); ok {
+		recentDays = int(d)
+	}
+
+	sensitivity := 3.0
+	if s, ok := request.Params.Arguments["sensitivity"].(float64); ok {
+		sensitivity = s
+	}
+
+	report, err := DetectActivitySpikes(chatJID, recentDays, sensitivity)
+	if err != nil {
+		return nil, err
+	}
+
+	reportData, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(reportData)), nil
+}
+
+func listChatsModifiedSinceHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sinceStr, ok := request.Params.Arguments["since"].(string)
+	if !ok {
+		return nil, errors.New("since must be a string")
+	}
+
+	since, err := time.Parse(time.RFC3339, sinceStr)
+	if err != nil {
+		return nil, errors.New("since must be an RFC3339 timestamp")
+	}
+
+	var sinceJID string
+	if j, ok := request.Params.Arguments["since_jid"].(string); ok {
+		sinceJID = j
+	}
+
+	limit := 100
+	if l, ok := request.Params.Arguments["limit"].(float64); ok {
+		limit = int(l)
+	}
+
+	chats, err := ListChatsModifiedSince(since, sinceJID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	chatsData, err := json.Marshal(chats)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(chatsData)), nil
+}
+
+func resyncChatHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	chatJID, ok := request.Params.Arguments["chat_jid"].(string)
+	if !ok {
+		return nil, errors.New("chat_jid must be a string")
+	}
+
+	chat, err := ResyncChat(chatJID)
+	if err != nil {
+		return nil, err
+	}
+
+	chatData, err := json.Marshal(chat)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(chatData)), nil
+}
+
+func sendBroadcastHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	rawRecipients, ok := request.Params.Arguments["recipients"].([]interface{})
+	if !ok {
+		return nil, errors.New("recipients must be an array")
+	}
+
+	recipients := make([]string, 0, len(rawRecipients))
+	for _, r := range rawRecipients {
+		recipient, ok := r.(string)
+		if !ok {
+			return nil, errors.New("recipients must be strings")
+		}
+		recipients = append(recipients, recipient)
+	}
+
+	message, ok := request.Params.Arguments["message"].(string)
+	if !ok {
+		return nil, errors.New("message must be a string")
+	}
+
+	result, err := SendBroadcast(recipients, message)
+	if err != nil {
+		return nil, err
+	}
+
+	resultData, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(resultData)), nil
+}
+
+func wasMessageReadHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	chatJID, ok := request.Params.Arguments["chat_jid"].(string)
+	if !ok {
+		return nil, errors.New("chat_jid must be a string")
+	}
+
+	messageID, ok := request.Params.Arguments["message_id"].(string)
+	if !ok {
+		return nil, errors.New("message_id must be a string")
+	}
+
+	status, err := WasMessageRead(chatJID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	statusData, err := json.Marshal(status)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(statusData)), nil
+}
+
+func deleteMediaHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	chatJID, ok := request.Params.Arguments["chat_jid"].(string)
+	if !ok {
+		return nil, errors.New("chat_jid must be a string")
+	}
+
+	messageID, ok := request.Params.Arguments["message_id"].(string)
+	if !ok {
+		return nil, errors.New("message_id must be a string")
+	}
+
+	deleted, err := DeleteMedia(chatJID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{
+		"message_id": messageID,
+		"deleted":    deleted,
+	}
+
+	resultData, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(resultData)), nil
+}
+
+func sendVideoHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	recipient, ok := request.Params.Arguments["recipient"].(string)
+	if !ok {
+		return nil, errors.New("recipient must be a string")
+	}
+
+	mediaPath, ok := request.Params.Arguments["media_path"].(string)
+	if !ok {
+		return nil, errors.New("media_path must be a string")
+	}
+
+	caption := ""
+	if c, ok := request.Params.Arguments["caption"].(string); ok {
+		caption = c
+	}
+
+	asGIF := false
+	if g, ok := request.Params.Arguments["as_gif"].(bool); ok {
+		asGIF = g
+	}
+
+	success, statusMessage := SendVideo(recipient, mediaPath, caption, asGIF)
+
+	result := map[string]interface{}{
+		"success": success,
+		"message": statusMessage,
+	}
+
+	resultData, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(resultData)), nil
+}
+
+func getStorageUsageHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	usage, err := GetStorageUsage()
+	if err != nil {
+		return nil, err
+	}
+
+	usageData, err := json.Marshal(usage)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(usageData)), nil
+}
+
+func markChatReadHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	chatJID, ok := request.Params.Arguments["chat_jid"].(string)
+	if !ok {
+		return nil, errors.New("chat_jid must be a string")
+	}
+
+	result, err := MarkChatRead(chatJID)
+	if err != nil {
+		return nil, err
+	}
+
+	resultData, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(resultData)), nil
+}
+
+func getCountsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	counts, err := GetCounts()
+	if err != nil {
+		return nil, err
+	}
+
+	countsData, err := json.Marshal(counts)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(countsData)), nil
+}
+
+func getHistorySyncStatusHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	status, err := GetHistorySyncStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	statusData, err := json.Marshal(status)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(statusData)), nil
+}
+
+func requestHistorySyncHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	success, statusMessage := RequestHistorySync()
+
+	result := map[string]interface{}{
+		"success": success,
+		"message": statusMessage,
+	}
+
+	resultData, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(resultData)), nil
+}
+
+func pauseIngestionHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	state, err := PauseIngestion()
+	if err != nil {
+		return nil, err
+	}
+
+	stateData, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(stateData)), nil
+}
+
+func resumeIngestionHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	state, err := ResumeIngestion()
+	if err != nil {
+		return nil, err
+	}
+
+	stateData, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+
+	return mcp.NewToolResultText(string(stateData)), nil
+}
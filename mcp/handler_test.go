@@ -0,0 +1,166 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mbenaiss/whatsapp-mcp/models"
+)
+
+// testBridge records the requests made to a fake bridge API
+type testBridge struct {
+	mu       sync.Mutex
+	payloads map[string]map[string]interface{}
+}
+
+// newTestBridge points WhatsappAPIBaseURL at a fake bridge answering every request
+// successfully with data
+func newTestBridge(t testing.TB, data interface{}) *testBridge {
+	t.Helper()
+
+	bridge := &testBridge{payloads: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		if r.Body != nil && r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				t.Errorf("decoding request to %s: %v", r.URL.Path, err)
+			}
+		}
+
+		bridge.mu.Lock()
+		bridge.payloads[r.URL.Path] = payload
+		bridge.mu.Unlock()
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "ok", "data": data})
+	}))
+	t.Cleanup(server.Close)
+
+	previous := WhatsappAPIBaseURL
+	WhatsappAPIBaseURL = server.URL + "/api"
+	t.Cleanup(func() { WhatsappAPIBaseURL = previous })
+
+	return bridge
+}
+
+// payload returns the JSON body of the last request to the API path
+func (b *testBridge) payload(path string) map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.payloads["/api"+path]
+}
+
+// callTool calls a tool handler with arguments and decodes its JSON text result into v
+func callTool(t testing.TB, handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error), arguments map[string]interface{}, v interface{}) {
+	t.Helper()
+
+	var request mcp.CallToolRequest
+	request.Params.Arguments = arguments
+	result, err := handler(context.Background(), request)
+	if err != nil {
+		t.Fatalf("calling tool: %v", err)
+	}
+
+	text, ok := result.Content[0].(mcp.TextContent)
+	if !ok {
+		t.Fatalf("tool returned %T, want text", result.Content[0])
+	}
+	if err := json.Unmarshal([]byte(text.Text), v); err != nil {
+		t.Fatalf("decoding tool result %q: %v", text.Text, err)
+	}
+}
+
+func TestReplyToLast(t *testing.T) {
+	d := newTestDB(t)
+
+	// Stored out of order, with a later message in another chat
+	storeMessages(t, d,
+		testMessage(testAliceJID, "M2", testAliceJID, "are you coming?", 2*time.Minute),
+		testMessage(testAliceJID, "M3", "", "on my way", time.Minute),
+		testMessage(testAliceJID, "M1", testAliceJID, "hi", 0),
+		testMessage(testBobJID, "M4", testBobJID, "hello", time.Hour),
+	)
+	bridge := newTestBridge(t, SendResult{MessageID: "3EB0FF", QuotedSnippet: "are you coming?", QuotedSender: testAliceJID})
+
+	var result struct {
+		Success         bool   `json:"success"`
+		QuotedMessageID string `json:"quoted_message_id"`
+		QuotedSnippet   string `json:"quoted_snippet"`
+	}
+	callTool(t, replyToLastHandler, map[string]interface{}{"chat_jid": testAliceJID, "message": "yes"}, &result)
+
+	if !result.Success || result.QuotedMessageID != "M2" || result.QuotedSnippet != "are you coming?" {
+		t.Errorf("result = %+v, want a reply quoting M2", result)
+	}
+
+	sent := bridge.payload("/send")
+	if sent["recipient"] != testAliceJID || sent["message"] != "yes" || sent["quoted_message_id"] != "M2" {
+		t.Errorf("sent %v, want yes to %s quoting M2", sent, testAliceJID)
+	}
+
+	var request mcp.CallToolRequest
+	request.Params.Arguments = map[string]interface{}{"chat_jid": testCarolJID, "message": "yes"}
+	if _, err := replyToLastHandler(context.Background(), request); err == nil {
+		t.Errorf("replied in a chat without messages")
+	}
+}
+
+func TestListChatJIDs(t *testing.T) {
+	d := newTestDB(t)
+
+	storeMessages(t, d,
+		testMessage(testAliceJID, "M1", testAliceJID, "hi", 0),
+		testMessage(testGroupJID, "M2", testBobJID, "team lunch?", time.Minute),
+		testMessage(testCarolJID, "M3", testCarolJID, "hello", 2*time.Minute),
+	)
+	for jid, name := range map[string]string{testAliceJID: "Alice", testGroupJID: "Lunch crew", testCarolJID: "Carol"} {
+		if err := d.StoreChat(context.Background(), models.Chat{JID: jid, Name: name, LastMessageTime: testEpoch}); err != nil {
+			t.Fatalf("StoreChat: %v", err)
+		}
+	}
+
+	var chats []map[string]interface{}
+	callTool(t, listChatJIDsHandler, map[string]interface{}{"query": "l"}, &chats)
+
+	want := []map[string]interface{}{
+		{"JID": testAliceJID, "Name": "Alice", "IsGroup": false},
+		{"JID": testCarolJID, "Name": "Carol", "IsGroup": false},
+		{"JID": testGroupJID, "Name": "Lunch crew", "IsGroup": true},
+	}
+	if len(chats) != len(want) {
+		t.Fatalf("chats = %v, want %v", chats, want)
+	}
+	for i, chat := range chats {
+		// Only the reference is returned, none of the last message fields of list_chats
+		if !maps.Equal(chat, want[i]) {
+			t.Errorf("chat %d = %v, want %v", i, chat, want[i])
+		}
+	}
+}
+
+func TestSendMessageCustomID(t *testing.T) {
+	bridge := newTestBridge(t, SendResult{MessageID: "3EB0C0FFEE0123456789"})
+
+	var result struct {
+		Success   bool   `json:"success"`
+		MessageID string `json:"message_id"`
+	}
+	callTool(t, sendMessageHandler, map[string]interface{}{
+		"recipient":  testAliceJID,
+		"message":    "hello",
+		"message_id": "3EB0C0FFEE0123456789",
+	}, &result)
+
+	if sent := bridge.payload("/send"); sent["message_id"] != "3EB0C0FFEE0123456789" {
+		t.Errorf("sent message ID %v, want 3EB0C0FFEE0123456789", sent["message_id"])
+	}
+	if !result.Success || result.MessageID != "3EB0C0FFEE0123456789" {
+		t.Errorf("result = %+v, want the message ID echoed back", result)
+	}
+}
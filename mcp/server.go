@@ -34,6 +34,12 @@ func NewMCPServer(name string, version string) *server.MCPServer {
 		mcp.WithString("query",
 			mcp.Description("Optional search term to filter messages by content"),
 		),
+		mcp.WithString("match_mode",
+			mcp.Description("How to match the query: 'phrase' for the exact phrase, 'any' for any of its words or 'all' for all of its words (default 'phrase')"),
+		),
+		mcp.WithString("message_type",
+			mcp.Description("Optional message type to filter by: 'text', 'image', 'video', 'audio', 'document', 'sticker' or 'unsupported'"),
+		),
 		mcp.WithNumber("limit",
 			mcp.Description("Maximum number of messages to return (default 20)"),
 		),
@@ -41,7 +47,7 @@ func NewMCPServer(name string, version string) *server.MCPServer {
 			mcp.Description("Page number for pagination (default 0)"),
 		),
 		mcp.WithBoolean("include_context",
-			mcp.Description("Whether to include messages before and after matches (default true)"),
+			mcp.Description("Whether to include messages before and after matches, at the cost of extra lookups per match (default false). Results are then wrapped with a ContextTruncated flag"),
 		),
 		mcp.WithNumber("context_before",
 			mcp.Description("Number of messages to include before each match (default 1)"),
@@ -49,6 +55,12 @@ func NewMCPServer(name string, version string) *server.MCPServer {
 		mcp.WithNumber("context_after",
 			mcp.Description("Number of messages to include after each match (default 1)"),
 		),
+		mcp.WithNumber("max_context",
+			mcp.Description("Maximum number of context messages added across all matches; overlapping windows are merged (default 200)"),
+		),
+		mcp.WithBoolean("group_by_chat",
+			mcp.Description("Whether to group results under each chat with its name and match count (default false)"),
+		),
 	)
 
 	listChatsTool := mcp.NewTool("list_chats",
@@ -68,6 +80,9 @@ func NewMCPServer(name string, version string) *server.MCPServer {
 		mcp.WithString("sort_by",
 			mcp.Description("Field to sort results by, either 'last_active' or 'name' (default 'last_active')"),
 		),
+		mcp.WithString("chat_type",
+			mcp.Description("Type of chats to return, either 'direct', 'group' or 'all' (default 'all')"),
+		),
 	)
 
 	getChatTool := mcp.NewTool("get_chat",
@@ -111,6 +126,14 @@ func NewMCPServer(name string, version string) *server.MCPServer {
 		),
 	)
 
+	getFirstInteractionTool := mcp.NewTool("get_first_interaction",
+		mcp.WithDescription("Retrieve the earliest stored WhatsApp message involving the contact, i.e. when the conversation started"),
+		mcp.WithString("jid",
+			mcp.Required(),
+			mcp.Description("JID of the contact to search for"),
+		),
+	)
+
 	getMessageContextTool := mcp.NewTool("get_message_context",
 		mcp.WithDescription("Retrieve context around a specific WhatsApp message"),
 		mcp.WithString("message_id",
@@ -125,6 +148,25 @@ func NewMCPServer(name string, version string) *server.MCPServer {
 		),
 	)
 
+	scrollMessagesTool := mcp.NewTool("scroll_messages",
+		mcp.WithDescription("Retrieve the next chunk of WhatsApp messages before or after a boundary message, to scroll through a conversation. Pass back the returned OldestID or NewestID to continue"),
+		mcp.WithString("chat_jid",
+			mcp.Required(),
+			mcp.Description("JID of the chat the boundary message belongs to"),
+		),
+		mcp.WithString("message_id",
+			mcp.Required(),
+			mcp.Description("ID of the boundary message, e.g. OldestID or NewestID from get_message_context"),
+		),
+		mcp.WithString("direction",
+			mcp.Required(),
+			mcp.Description("Direction to scroll, either 'before' for older messages or 'after' for newer ones"),
+		),
+		mcp.WithNumber("count",
+			mcp.Description("Number of messages to retrieve (default 10)"),
+		),
+	)
+
 	sendMessageTool := mcp.NewTool("send_message",
 		mcp.WithDescription("Send a WhatsApp message to a person or group. For group chats, use the JID"),
 		mcp.WithString("recipient",
@@ -135,6 +177,367 @@ func NewMCPServer(name string, version string) *server.MCPServer {
 			mcp.Required(),
 			mcp.Description("The text of the message to send"),
 		),
+		mcp.WithBoolean("with_preview",
+			mcp.Description("Whether to generate a rich link preview for the first URL in the message (default false)"),
+		),
+		mcp.WithString("quoted_message_id",
+			mcp.Description("Optional ID of a message in the same chat to reply to; its snippet and sender are echoed in the result"),
+		),
+		mcp.WithNumber("expire_seconds",
+			mcp.Description("Optional time after which this message disappears, even in chats without disappearing messages: 86400 (24 hours), 604800 (7 days) or 7776000 (90 days)"),
+		),
+		mcp.WithBoolean("require_known_contact",
+			mcp.Description("Refuse to send unless the recipient is a saved contact or an existing chat, to guard against mistyped numbers; the resolved name is returned (default false)"),
+		),
+		mcp.WithString("message_id",
+			mcp.Description("Optional ID to send the message under instead of a generated one, for idempotent retries and correlation: 16 to 64 uppercase hexadecimal characters not used before in the chat"),
+		),
+	)
+
+	sendReactionTool := mcp.NewTool("send_reaction",
+		mcp.WithDescription("React to a WhatsApp message with an emoji"),
+		mcp.WithString("chat_jid",
+			mcp.Required(),
+			mcp.Description("JID of the chat containing the message"),
+		),
+		mcp.WithString("message_id",
+			mcp.Required(),
+			mcp.Description("ID of the message to react to"),
+		),
+		mcp.WithString("emoji",
+			mcp.Required(),
+			mcp.Description("A single emoji to react with, including multi-codepoint emojis such as flags"),
+		),
+	)
+
+	getMessageReactionsTool := mcp.NewTool("get_message_reactions",
+		mcp.WithDescription("Retrieve all reactions on a WhatsApp message with counts per emoji and the reactors"),
+		mcp.WithString("chat_jid",
+			mcp.Required(),
+			mcp.Description("JID of the chat the message is in"),
+		),
+		mcp.WithString("message_id",
+			mcp.Required(),
+			mcp.Description("ID of the message to get reactions for"),
+		),
+	)
+
+	listMentionsTool := mcp.NewTool("list_mentions",
+		mcp.WithDescription("Retrieve WhatsApp messages that mention me across all groups, newest first"),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of messages to return (default 20)"),
+		),
+		mcp.WithNumber("page",
+			mcp.Description("Page number for pagination (default 0)"),
+		),
+	)
+
+	retryMediaDownloadTool := mcp.NewTool("retry_media_download",
+		mcp.WithDescription("Retry downloading media for WhatsApp messages whose attachment failed to download, reporting the result per message"),
+		mcp.WithString("chat_jid",
+			mcp.Description("Optional chat JID to restrict the retry to a single chat"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of messages to retry (default 20)"),
+		),
+	)
+
+	getChatParticipantsActivityTool := mcp.NewTool("get_chat_participants_activity",
+		mcp.WithDescription("Retrieve per-participant message counts and last active time for a WhatsApp group, including members who never wrote"),
+		mcp.WithString("chat_jid",
+			mcp.Required(),
+			mcp.Description("JID of the group chat"),
+		),
+		mcp.WithArray("date_range",
+			mcp.Description("Optional tuple of (start_date, end_date) to restrict the activity to"),
+		),
+		mcp.WithNumber("min_messages",
+			mcp.Description("Only include participants with at least this many messages (default 0)"),
+		),
+	)
+
+	exportContactsTool := mcp.NewTool("export_contacts",
+		mcp.WithDescription("Export all WhatsApp contacts with their name, phone number, JID and business status"),
+		mcp.WithString("format",
+			mcp.Description("Output format, either 'json' or 'csv' (default 'json')"),
+		),
+	)
+
+	getLabelsTool := mcp.NewTool("get_labels",
+		mcp.WithDescription("List all chat labels and the chats each label is assigned to"),
+	)
+
+	createLabelTool := mcp.NewTool("create_label",
+		mcp.WithDescription("Create a chat label, synced to WhatsApp for business accounts and stored locally otherwise"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the label"),
+		),
+		mcp.WithNumber("color",
+			mcp.Description("WhatsApp label color index (default 0)"),
+		),
+	)
+
+	assignLabelTool := mcp.NewTool("assign_label",
+		mcp.WithDescription("Assign a label to a WhatsApp chat or remove it"),
+		mcp.WithString("chat_jid",
+			mcp.Required(),
+			mcp.Description("JID of the chat to label"),
+		),
+		mcp.WithString("label_id",
+			mcp.Required(),
+			mcp.Description("ID of the label, as returned by get_labels or create_label"),
+		),
+		mcp.WithBoolean("assigned",
+			mcp.Description("Whether to assign the label (true) or remove it (false) (default true)"),
+		),
+	)
+
+	getMessageHistogramTool := mcp.NewTool("get_message_histogram",
+		mcp.WithDescription("Retrieve message counts for a WhatsApp chat bucketed by hour, day or week in UTC, including empty buckets"),
+		mcp.WithString("chat_jid",
+			mcp.Required(),
+			mcp.Description("JID of the chat"),
+		),
+		mcp.WithArray("date_range",
+			mcp.Description("Optional tuple of (start_date, end_date) to restrict the histogram to"),
+		),
+		mcp.WithString("granularity",
+			mcp.Description("Bucket size, either 'hour', 'day' or 'week' (default 'day')"),
+		),
+	)
+
+	listChatsModifiedSinceTool := mcp.NewTool("list_chats_modified_since",
+		mcp.WithDescription("Retrieve WhatsApp chats with new messages after a timestamp, along with the timestamp and JID to use for the next sync"),
+		mcp.WithString("since",
+			mcp.Required(),
+			mcp.Description("RFC3339 timestamp of the previous sync, i.e. its NextSyncTimestamp"),
+		),
+		mcp.WithString("since_jid",
+			mcp.Description("NextSyncJID of the previous sync, to continue between chats sharing its timestamp"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of chats to return (default 100)"),
+		),
+	)
+
+	resyncChatTool := mcp.NewTool("resync_chat",
+		mcp.WithDescription("Refresh the stored name of a WhatsApp chat from the group subject or contact name"),
+		mcp.WithString("chat_jid",
+			mcp.Required(),
+			mcp.Description("JID of the chat to resync"),
+		),
+	)
+
+	sendBroadcastTool := mcp.NewTool("send_broadcast",
+		mcp.WithDescription("Send a WhatsApp message to several recipients, each receiving it as a direct message, and report the result per recipient. Sends are spaced out to avoid rate limits."),
+		mcp.WithArray("recipients",
+			mcp.Required(),
+			mcp.Description("Phone numbers with country code but no + or other symbols, or JIDs, of up to 256 recipients"),
+		),
+		mcp.WithString("message",
+			mcp.Required(),
+			mcp.Description("The message text to send"),
+		),
+	)
+
+	wasMessageReadTool := mcp.NewTool("was_message_read",
+		mcp.WithDescription("Check whether a sent WhatsApp message was delivered and read, with timestamps and per-participant status for groups"),
+		mcp.WithString("chat_jid",
+			mcp.Required(),
+			mcp.Description("JID of the chat the message was sent in"),
+		),
+		mcp.WithString("message_id",
+			mcp.Required(),
+			mcp.Description("ID of the sent message"),
+		),
+	)
+
+	deleteMediaTool := mcp.NewTool("delete_media",
+		mcp.WithDescription("Delete the downloaded media file of a WhatsApp message to free disk space, keeping the message and its metadata"),
+		mcp.WithString("chat_jid",
+			mcp.Required(),
+			mcp.Description("JID of the chat containing the message"),
+		),
+		mcp.WithString("message_id",
+			mcp.Required(),
+			mcp.Description("ID of the message whose media file to delete"),
+		),
+	)
+
+	sendVideoTool := mcp.NewTool("send_video",
+		mcp.WithDescription("Send an MP4 video to a WhatsApp contact or group, optionally as a GIF that plays muted on a loop"),
+		mcp.WithString("recipient",
+			mcp.Required(),
+			mcp.Description("The recipient - either a phone number with country code but without + or other symbols, or a JID (e.g. '123456789@s.whatsapp.net' or a group JID like '123456789@g.us')"),
+		),
+		mcp.WithString("media_path",
+			mcp.Required(),
+			mcp.Description("Path of the MP4 file to send, inside the bridge's send media directory (SEND_MEDIA_DIR, by default the outbox directory of its store). Relative paths are relative to that directory."),
+		),
+		mcp.WithString("caption",
+			mcp.Description("Optional caption for the video"),
+		),
+		mcp.WithBoolean("as_gif",
+			mcp.Description("Whether to send the video as a GIF (default false)"),
+		),
+	)
+
+	getStorageUsageTool := mcp.NewTool("get_storage_usage",
+		mcp.WithDescription("Report the disk space used by the WhatsApp bridge's message database, session database and downloaded media, with message and media counts"),
+	)
+
+	getHistorySyncStatusTool := mcp.NewTool("get_history_sync_status",
+		mcp.WithDescription("Report whether a WhatsApp history sync is in progress, when it started and how many conversations and messages it has ingested"),
+	)
+
+	requestHistorySyncTool := mcp.NewTool("request_history_sync",
+		mcp.WithDescription("Ask WhatsApp to send message history to the bridge; follow progress with get_history_sync_status"),
+	)
+
+	getMessagesAroundTimeTool := mcp.NewTool("get_messages_around_time",
+		mcp.WithDescription("Retrieve the WhatsApp messages closest to a point in time in a chat, even if no message was sent at exactly that time"),
+		mcp.WithString("chat_jid",
+			mcp.Required(),
+			mcp.Description("JID of the chat"),
+		),
+		mcp.WithString("timestamp",
+			mcp.Required(),
+			mcp.Description("RFC3339 timestamp to look around"),
+		),
+		mcp.WithNumber("before",
+			mcp.Description("Number of messages to include before the closest message (default 5)"),
+		),
+		mcp.WithNumber("after",
+			mcp.Description("Number of messages to include after the closest message (default 5)"),
+		),
+	)
+
+	getCountsTool := mcp.NewTool("get_counts",
+		mcp.WithDescription("Count WhatsApp contacts, direct chats, group chats and stored messages in one cheap call"),
+	)
+
+	markChatReadTool := mcp.NewTool("mark_chat_read",
+		mcp.WithDescription("Mark every unread received message of a WhatsApp chat as read"),
+		mcp.WithString("chat_jid",
+			mcp.Required(),
+			mcp.Description("JID of the chat to mark read"),
+		),
+	)
+
+	getSharedChatsTool := mcp.NewTool("get_shared_chats",
+		mcp.WithDescription("Retrieve the direct WhatsApp chat with a contact and every group they are a member of, even groups where they have not sent a message. Each chat is typed direct or group"),
+		mcp.WithString("jid",
+			mcp.Required(),
+			mcp.Description("JID or phone number of the contact"),
+		),
+	)
+
+	getRawMessageTool := mcp.NewTool("get_raw_message",
+		mcp.WithDescription("Retrieve the base64 encoded raw proto of a received WhatsApp message, to debug why it was parsed incorrectly or dropped. Only available when the bridge runs with DEBUG_STORE_RAW"),
+		mcp.WithString("message_id",
+			mcp.Required(),
+			mcp.Description("ID of the message"),
+		),
+		mcp.WithString("chat_jid",
+			mcp.Description("JID of the chat, to disambiguate message IDs"),
+		),
+	)
+
+	replyToLastTool := mcp.NewTool("reply_to_last",
+		mcp.WithDescription("Send a WhatsApp message to a chat as a reply quoting the most recent message in it"),
+		mcp.WithString("chat_jid",
+			mcp.Required(),
+			mcp.Description("JID of the chat to reply in"),
+		),
+		mcp.WithString("message",
+			mcp.Required(),
+			mcp.Description("Text of the reply"),
+		),
+		mcp.WithBoolean("with_preview",
+			mcp.Description("Whether to generate a rich link preview for the first URL in the message (default false)"),
+		),
+	)
+
+	listLargeMediaTool := mcp.NewTool("list_large_media",
+		mcp.WithDescription("List WhatsApp media messages with the largest attachments first, with their size, type, chat and message ID, to find what to delete with delete_media"),
+		mcp.WithNumber("min_size_bytes",
+			mcp.Description("Minimum attachment size in bytes (default 1048576)"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of messages to return (default 20)"),
+		),
+	)
+
+	detectActivitySpikesTool := mcp.NewTool("detect_activity_spikes",
+		mcp.WithDescription("Flag recent days on which a WhatsApp chat had unusually many messages compared to its daily average, which may indicate an emergency or spam"),
+		mcp.WithString("chat_jid",
+			mcp.Required(),
+			mcp.Description("JID of the chat"),
+		),
+		mcp.WithNumber("recent_days",
+			mcp.Description("Number of recent days to check, compared against all earlier days (default 7)"),
+		),
+		mcp.WithNumber("sensitivity",
+			mcp.Description("Number of standard deviations above the average a day must reach to be flagged; lower flags more days (default 3)"),
+		),
+	)
+
+	listChatJIDsTool := mcp.NewTool("list_chat_jids",
+		mcp.WithDescription("List the JID and name of every WhatsApp chat, without last messages. Much lighter than list_chats for resolving a chat name to its JID"),
+		mcp.WithString("query",
+			mcp.Description("Optional text to filter chats by name or JID"),
+		),
+	)
+
+	listMessagesMultiTool := mcp.NewTool("list_messages_multi",
+		mcp.WithDescription("Get the most recent WhatsApp messages of several chats at once, grouped by chat, to catch up on them in a single call"),
+		mcp.WithArray("chat_jids",
+			mcp.Required(),
+			mcp.Description("JIDs of up to 50 chats to read"),
+		),
+		mcp.WithNumber("limit_per_chat",
+			mcp.Description("Maximum number of messages to return per chat, newest first (default 10, at most 100)"),
+		),
+	)
+
+	pauseIngestionTool := mcp.NewTool("pause_ingestion",
+		mcp.WithDescription("Stop the WhatsApp bridge from writing received messages to the database, buffering them in memory, so the database can be vacuumed or backed up safely. Call resume_ingestion afterwards. Ingestion resumes by itself once buffer_limit messages are buffered"),
+	)
+
+	resumeIngestionTool := mcp.NewTool("resume_ingestion",
+		mcp.WithDescription("Store the WhatsApp messages buffered since pause_ingestion and resume writing messages as they arrive"),
+	)
+
+	getConversationBalanceTool := mcp.NewTool("get_conversation_balance",
+		mcp.WithDescription("Compare how many messages and characters each party sent in a WhatsApp direct chat, as counts and percentages, to see who talks more"),
+		mcp.WithString("chat_jid",
+			mcp.Required(),
+			mcp.Description("JID of the direct chat"),
+		),
+		mcp.WithArray("date_range",
+			mcp.Description("Optional tuple of (start_date, end_date) to restrict the comparison to"),
+		),
+	)
+
+	findUnansweredQuestionsTool := mcp.NewTool("find_unanswered_questions",
+		mcp.WithDescription("Find questions others asked in a WhatsApp chat that I did not reply to within a time window, as likely needing a reply"),
+		mcp.WithString("chat_jid",
+			mcp.Required(),
+			mcp.Description("JID of the chat"),
+		),
+		mcp.WithNumber("window_hours",
+			mcp.Description("Hours within which any message of mine counts as a reply (default 24)"),
+		),
+		mcp.WithString("heuristic",
+			mcp.Description("How to recognize questions: 'trailing' for messages ending with a question mark or 'contains' for messages with one anywhere (default 'trailing')"),
+		),
+		mcp.WithArray("date_range",
+			mcp.Description("Optional tuple of (start_date, end_date) to restrict the scan to"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of questions to return, keeping the most recent (default 20)"),
+		),
 	)
 
 	s.AddTool(searchContactsTool, searchContactsHandler)
@@ -144,8 +547,43 @@ func NewMCPServer(name string, version string) *server.MCPServer {
 	s.AddTool(getDirectChatByContactTool, getDirectChatByContactHandler)
 	s.AddTool(getContactChatsTool, getContactChatsHandler)
 	s.AddTool(getLastInteractionTool, getLastInteractionHandler)
+	s.AddTool(getFirstInteractionTool, getFirstInteractionHandler)
 	s.AddTool(getMessageContextTool, getMessageContextHandler)
+	s.AddTool(scrollMessagesTool, scrollMessagesHandler)
 	s.AddTool(sendMessageTool, sendMessageHandler)
+	s.AddTool(sendReactionTool, sendReactionHandler)
+	s.AddTool(getMessageReactionsTool, getMessageReactionsHandler)
+	s.AddTool(listMentionsTool, listMentionsHandler)
+	s.AddTool(retryMediaDownloadTool, retryMediaDownloadHandler)
+	s.AddTool(getChatParticipantsActivityTool, getChatParticipantsActivityHandler)
+	s.AddTool(exportContactsTool, exportContactsHandler)
+	s.AddTool(getLabelsTool, getLabelsHandler)
+	s.AddTool(createLabelTool, createLabelHandler)
+	s.AddTool(assignLabelTool, assignLabelHandler)
+	s.AddTool(getMessageHistogramTool, getMessageHistogramHandler)
+	s.AddTool(listChatsModifiedSinceTool, listChatsModifiedSinceHandler)
+	s.AddTool(resyncChatTool, resyncChatHandler)
+	s.AddTool(sendBroadcastTool, sendBroadcastHandler)
+	s.AddTool(wasMessageReadTool, wasMessageReadHandler)
+	s.AddTool(deleteMediaTool, deleteMediaHandler)
+	s.AddTool(sendVideoTool, sendVideoHandler)
+	s.AddTool(getStorageUsageTool, getStorageUsageHandler)
+	s.AddTool(getHistorySyncStatusTool, getHistorySyncStatusHandler)
+	s.AddTool(requestHistorySyncTool, requestHistorySyncHandler)
+	s.AddTool(getMessagesAroundTimeTool, getMessagesAroundTimeHandler)
+	s.AddTool(getCountsTool, getCountsHandler)
+	s.AddTool(markChatReadTool, markChatReadHandler)
+	s.AddTool(getSharedChatsTool, getSharedChatsHandler)
+	s.AddTool(getRawMessageTool, getRawMessageHandler)
+	s.AddTool(replyToLastTool, replyToLastHandler)
+	s.AddTool(listLargeMediaTool, listLargeMediaHandler)
+	s.AddTool(detectActivitySpikesTool, detectActivitySpikesHandler)
+	s.AddTool(listChatJIDsTool, listChatJIDsHandler)
+	s.AddTool(listMessagesMultiTool, listMessagesMultiHandler)
+	s.AddTool(pauseIngestionTool, pauseIngestionHandler)
+	s.AddTool(resumeIngestionTool, resumeIngestionHandler)
+	s.AddTool(getConversationBalanceTool, getConversationBalanceHandler)
+	s.AddTool(findUnansweredQuestionsTool, findUnansweredQuestionsHandler)
 
 	return s
 }
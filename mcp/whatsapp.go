@@ -4,16 +4,24 @@ import (
 	"bytes"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
+	whatsappdb "github.com/mbenaiss/whatsapp-mcp/db"
 )
 
 // Constants for paths and API URL
@@ -50,6 +58,7 @@ type Chat struct {
 	LastMessage     string
 	LastSender      string
 	LastIsFromMe    bool
+	Labels          []string
 }
 
 // IsGroup determines if the chat is a group based on JID pattern
@@ -64,11 +73,117 @@ type Contact struct {
 	JID         string
 }
 
-// MessageContext represents a message with its context (messages before and after)
+// MessageContext represents a message with its context (messages before and after).
+// OldestID and NewestID can be passed to ScrollMessages to continue in either direction.
 type MessageContext struct {
-	Message Message
-	Before  []Message
-	After   []Message
+	Message  Message
+	Before   []Message
+	After    []Message
+	OldestID string
+	NewestID string
+}
+
+// MessagePage represents a chunk of consecutive messages in a chat, in chronological order
+type MessagePage struct {
+	Messages []Message
+	OldestID string
+	NewestID string
+	HasMore  bool
+}
+
+// ChatSync represents the chats changed since a sync timestamp and the timestamp to pass to the next sync
+type ChatSync struct {
+	Chats             []Chat
+	NextSyncTimestamp time.Time
+	NextSyncJID       string
+	HasMore           bool
+}
+
+// ChatMessages represents the messages of a single chat within grouped search results
+type ChatMessages struct {
+	ChatJID    string
+	ChatName   string
+	MatchCount int
+	Messages   []Message
+}
+
+// MessageResults represents search results with context, flagging when context was cut short by the cap
+type MessageResults struct {
+	Messages         []Message
+	ContextTruncated bool
+}
+
+// ChatMessageResults represents grouped search results with context, flagging when context was cut short by the cap
+type ChatMessageResults struct {
+	Chats            []ChatMessages
+	ContextTruncated bool
+}
+
+// HistogramBucket represents the number of messages in a time bucket
+type HistogramBucket struct {
+	Start time.Time
+	Count int
+}
+
+// ParticipantReceipt represents the delivery and read status of a message for one group participant
+type ParticipantReceipt struct {
+	JID         string
+	Status      string
+	DeliveredAt *time.Time
+	ReadAt      *time.Time
+}
+
+// MessageReadStatus represents whether a sent message was delivered and read. Status is
+// "sent", "delivered" or "read", and for groups reflects the furthest any participant got.
+type MessageReadStatus struct {
+	MessageID    string
+	ChatJID      string
+	Status       string
+	SentAt       *time.Time
+	DeliveredAt  *time.Time
+	ReadAt       *time.Time
+	Participants []ParticipantReceipt
+}
+
+// ParticipantActivity represents the messaging activity of a group participant
+type ParticipantActivity struct {
+	JID          string
+	Name         string
+	MessageCount int
+	// LastActive is nil for members without messages
+	LastActive *time.Time
+}
+
+// ReactionSummary represents all reactions using the same emoji on a message
+type ReactionSummary struct {
+	Emoji    string
+	Count    int
+	Reactors []string
+}
+
+// MessageReactions represents the reactions on a message grouped by emoji
+type MessageReactions struct {
+	MessageID string
+	ChatJID   string
+	Total     int
+	Reactions []ReactionSummary
+}
+
+// MediaDownloadResult represents the outcome of a media download retry for a message
+type MediaDownloadResult struct {
+	MessageID string `json:"message_id"`
+	ChatJID   string `json:"chat_jid"`
+	Status    string `json:"status"`
+	MediaPath string `json:"media_path,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Label represents a chat label, synced to WhatsApp for business accounts
+type Label struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Color  int32  `json:"color"`
+	Synced bool   `json:"synced"`
 }
 
 // PrintMessage displays a message with consistent formatting
@@ -161,15 +276,44 @@ func PrintPaginatedMessages(messages []Message, page int, totalPages int, chatNa
 	}
 }
 
+// schemaChecked records that the database schema was found up to date
+var schemaChecked atomic.Bool
+
 // GetDB creates a connection to the SQLite database
 func GetDB() (*sql.DB, error) {
 	db, err := sql.Open("sqlite3", MessagesDBPath)
 	if err != nil {
 		return nil, fmt.Errorf("unable to open database: %v", err)
 	}
+
+	if err := checkSchema(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	return db, nil
 }
 
+// checkSchema ensures the bridge has migrated the database to the version this code expects,
+// so queries don't fail with cryptic "no such column" errors
+func checkSchema(db *sql.DB) error {
+	if schemaChecked.Load() {
+		return nil
+	}
+
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("unable to read database schema version: %v", err)
+	}
+
+	if version < whatsappdb.SchemaVersion() {
+		return fmt.Errorf("message database schema is at version %d but version %d is required, restart the WhatsApp bridge to apply pending migrations", version, whatsappdb.SchemaVersion())
+	}
+
+	schemaChecked.Store(true)
+	return nil
+}
+
 // PrintRecentMessages retrieves and displays recent messages
 func PrintRecentMessages(limit int) ([]Message, error) {
 	if limit <= 0 {
@@ -250,8 +394,47 @@ func PrintRecentMessages(limit int) ([]Message, error) {
 	return messages, nil
 }
 
-// ListMessages retrieves messages matching specified criteria
-func ListMessages(dateRange []time.Time, senderPhoneNumber, chatJID, query string, limit, page int, includeContext bool, contextBefore, contextAfter int) ([]Message, error) {
+// ListMessages retrieves messages matching specified criteria. Context costs extra
+// queries per match, so it is skipped entirely unless requested, and at most
+// maxContext context messages are added; the returned flag reports whether
+// context was cut short.
+func ListMessages(dateRange []time.Time, senderPhoneNumber, chatJID, query, matchMode, messageType string, limit, page int, includeContext bool, contextBefore, contextAfter, maxContext int) ([]Message, bool, error) {
+	messages, err := findMessages(dateRange, senderPhoneNumber, chatJID, query, matchMode, messageType, limit, page)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if wantsContext(includeContext, contextBefore, contextAfter) && len(messages) > 0 {
+		expander := newContextExpander(contextBefore, contextAfter, maxContext)
+		return expander.expand(messages), expander.truncated, nil
+	}
+
+	return messages, false, nil
+}
+
+// ListMessagesByChat retrieves messages matching specified criteria grouped by chat.
+// The context cap applies across all groups.
+func ListMessagesByChat(dateRange []time.Time, senderPhoneNumber, chatJID, query, matchMode, messageType string, limit, page int, includeContext bool, contextBefore, contextAfter, maxContext int) ([]ChatMessages, bool, error) {
+	messages, err := findMessages(dateRange, senderPhoneNumber, chatJID, query, matchMode, messageType, limit, page)
+	if err != nil {
+		return nil, false, err
+	}
+
+	groups := GroupMessagesByChat(messages)
+	if !wantsContext(includeContext, contextBefore, contextAfter) {
+		return groups, false, nil
+	}
+
+	expander := newContextExpander(contextBefore, contextAfter, maxContext)
+	for i := range groups {
+		groups[i].Messages = expander.expand(groups[i].Messages)
+	}
+
+	return groups, expander.truncated, nil
+}
+
+// findMessages retrieves the messages matching the specified criteria without context
+func findMessages(dateRange []time.Time, senderPhoneNumber, chatJID, query, matchMode, messageType string, limit, page int) ([]Message, error) {
 	if limit <= 0 {
 		limit = 20
 	}
@@ -286,8 +469,17 @@ func ListMessages(dateRange []time.Time, senderPhoneNumber, chatJID, query strin
 	}
 
 	if query != "" {
-		whereClauses = append(whereClauses, "LOWER(messages.content) LIKE LOWER(?)")
-		params = append(params, "%"+query+"%")
+		clause, queryParams, err := contentMatch(query, matchMode)
+		if err != nil {
+			return nil, err
+		}
+		whereClauses = append(whereClauses, clause)
+		params = append(params, queryParams...)
+	}
+
+	if messageType != "" {
+		whereClauses = append(whereClauses, "COALESCE(messages.message_type, 'text') = ?")
+		params = append(params, messageType)
 	}
 
 	if len(whereClauses) > 0 {
@@ -343,21 +535,253 @@ func ListMessages(dateRange []time.Time, senderPhoneNumber, chatJID, query strin
 		return nil, fmt.Errorf("error traversing results: %v", err)
 	}
 
-	if includeContext && len(messages) > 0 {
-		var messagesWithContext []Message
-		for _, msg := range messages {
-			context, err := GetMessageContext(msg.ID, contextBefore, contextAfter)
-			if err != nil {
+	return messages, nil
+}
+
+// contentMatch builds the content filter for a search query. "phrase" matches the query
+// as written, "any" matches messages containing at least one of its words and "all"
+// matches messages containing every word. LIKE wildcards in the query match literally.
+func contentMatch(query, matchMode string) (string, []interface{}, error) {
+	escaper := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	like := "LOWER(messages.content) LIKE LOWER(?) ESCAPE '\\'"
+
+	var terms []string
+	var join string
+	switch matchMode {
+	case "", "phrase":
+		terms = []string{query}
+	case "any":
+		terms, join = strings.Fields(query), " OR "
+	case "all":
+		terms, join = strings.Fields(query), " AND "
+	default:
+		return "", nil, fmt.Errorf("invalid match mode %q, expected 'phrase', 'any' or 'all'", matchMode)
+	}
+
+	if len(terms) == 0 {
+		terms = []string{query}
+	}
+
+	clauses := make([]string, len(terms))
+	params := make([]interface{}, len(terms))
+	for i, term := range terms {
+		clauses[i] = like
+		params[i] = "%" + escaper.Replace(term) + "%"
+	}
+
+	return "(" + strings.Join(clauses, join) + ")", params, nil
+}
+
+// wantsContext reports whether context lookups would add any messages
+func wantsContext(includeContext bool, contextBefore, contextAfter int) bool {
+	return includeContext && (contextBefore > 0 || contextAfter > 0)
+}
+
+// defaultMaxContext caps the context messages list_messages adds across all matches
+const defaultMaxContext = 200
+
+// contextExpander surrounds matches with their context, skipping messages already
+// emitted by an overlapping window and stopping lookups once max context
+// messages have been added
+type contextExpander struct {
+	before, after int
+	max           int
+	added         int
+	truncated     bool
+	seen          map[string]bool
+}
+
+func newContextExpander(before, after, max int) *contextExpander {
+	return &contextExpander{before: before, after: after, max: max, seen: map[string]bool{}}
+}
+
+// expand replaces each message with the messages surrounding it. Matches are
+// always kept; only context counts towards the cap.
+func (e *contextExpander) expand(messages []Message) []Message {
+	var messagesWithContext []Message
+	for _, msg := range messages {
+		if e.added >= e.max {
+			e.truncated = true
+			messagesWithContext = e.appendUnseen(messagesWithContext, []Message{msg}, false)
+			continue
+		}
+
+		context, err := GetMessageContext(msg.ID, e.before, e.after)
+		if err != nil {
+			continue
+		}
+		messagesWithContext = e.appendUnseen(messagesWithContext, context.Before, true)
+		messagesWithContext = e.appendUnseen(messagesWithContext, []Message{context.Message}, false)
+		messagesWithContext = e.appendUnseen(messagesWithContext, context.After, true)
+	}
+	return messagesWithContext
+}
+
+// appendUnseen appends the messages not emitted yet, counting context messages
+// against the cap and dropping those beyond it
+func (e *contextExpander) appendUnseen(dst, messages []Message, isContext bool) []Message {
+	for _, msg := range messages {
+		key := msg.ChatJID + "/" + msg.ID
+		if e.seen[key] {
+			continue
+		}
+		if isContext {
+			if e.added >= e.max {
+				e.truncated = true
 				continue
 			}
-			messagesWithContext = append(messagesWithContext, context.Before...)
-			messagesWithContext = append(messagesWithContext, context.Message)
-			messagesWithContext = append(messagesWithContext, context.After...)
+			e.added++
 		}
-		return messagesWithContext, nil
+		e.seen[key] = true
+		dst = append(dst, msg)
 	}
+	return dst
+}
 
-	return messages, nil
+// GroupMessagesByChat groups messages under their chat, keeping chats in order of first appearance
+func GroupMessagesByChat(messages []Message) []ChatMessages {
+	var groups []ChatMessages
+	index := map[string]int{}
+
+	for _, msg := range messages {
+		i, ok := index[msg.ChatJID]
+		if !ok {
+			i = len(groups)
+			index[msg.ChatJID] = i
+			groups = append(groups, ChatMessages{
+				ChatJID:  msg.ChatJID,
+				ChatName: msg.ChatName,
+			})
+		}
+
+		groups[i].MatchCount++
+		groups[i].Messages = append(groups[i].Messages, msg)
+	}
+
+	return groups
+}
+
+const (
+	// maxMultiChats bounds the number of chats ListMessagesMulti reads in one call
+	maxMultiChats = 50
+	// maxMultiPerChat bounds the number of messages ListMessagesMulti reads per chat
+	maxMultiPerChat = 100
+)
+
+// ListMessagesMulti retrieves the most recent messages of each of several chats in a single
+// query, at most perChat per chat. Chats are returned in the order requested, including
+// those without messages.
+func ListMessagesMulti(chatJIDs []string, perChat int) ([]ChatMessages, error) {
+	if len(chatJIDs) == 0 {
+		return nil, fmt.Errorf("at least one chat JID is required")
+	}
+	if len(chatJIDs) > maxMultiChats {
+		return nil, fmt.Errorf("at most %d chats can be read at once", maxMultiChats)
+	}
+	if perChat <= 0 {
+		perChat = 10
+	}
+	if perChat > maxMultiPerChat {
+		return nil, fmt.Errorf("at most %d messages per chat can be read at once", maxMultiPerChat)
+	}
+
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	groups := []ChatMessages{}
+	index := map[string]int{}
+	placeholders := []string{}
+	params := []interface{}{}
+	for _, jid := range chatJIDs {
+		if _, ok := index[jid]; ok {
+			continue
+		}
+		index[jid] = len(groups)
+		groups = append(groups, ChatMessages{ChatJID: jid, Messages: []Message{}})
+		placeholders = append(placeholders, "?")
+		params = append(params, jid)
+	}
+	params = append(params, perChat)
+
+	rows, err := db.Query(`
+		SELECT timestamp, sender, name, content, is_from_me, chat_jid, id
+		FROM (
+			SELECT messages.timestamp, messages.sender, chats.name, messages.content, messages.is_from_me, messages.chat_jid, messages.id,
+				ROW_NUMBER() OVER (PARTITION BY messages.chat_jid ORDER BY julianday(messages.timestamp) DESC) AS position
+			FROM messages
+			JOIN chats ON messages.chat_jid = chats.jid
+			WHERE messages.chat_jid IN (`+strings.Join(placeholders, ", ")+`)
+		)
+		WHERE position <= ?
+		ORDER BY chat_jid, position
+	`, params...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var msg Message
+		var timestampStr string
+		var chatName sql.NullString
+
+		if err := rows.Scan(&timestampStr, &msg.Sender, &chatName, &msg.Content, &msg.IsFromMe, &msg.ChatJID, &msg.ID); err != nil {
+			return nil, fmt.Errorf("error reading data: %v", err)
+		}
+
+		msg.Timestamp, err = parseTimestamp(timestampStr)
+		if err != nil {
+			return nil, fmt.Errorf("error converting timestamp: %v", err)
+		}
+
+		if chatName.Valid {
+			msg.ChatName = chatName.String
+		} else {
+			msg.ChatName = "Unknown Chat"
+		}
+
+		group := &groups[index[msg.ChatJID]]
+		group.ChatName = msg.ChatName
+		group.MatchCount++
+		group.Messages = append(group.Messages, msg)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error traversing results: %v", err)
+	}
+
+	return groups, nil
+}
+
+// GetMessagesAroundTime retrieves the context around the message closest to a point in time,
+// so the target need not match a message exactly. In sparse chats the closest message may be
+// far from the target; its timestamp shows how far.
+func GetMessagesAroundTime(chatJID string, target time.Time, before, after int) (*MessageContext, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var messageID string
+	err = db.QueryRow(`
+		SELECT id
+		FROM messages
+		WHERE chat_jid = ?
+		ORDER BY ABS(julianday(timestamp) - julianday(?))
+		LIMIT 1
+	`, chatJID, target).Scan(&messageID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no messages found in chat %s", chatJID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error finding closest message: %v", err)
+	}
+
+	return GetMessageContext(messageID, before, after)
 }
 
 // GetMessageContext retrieves the context around a specific message
@@ -409,11 +833,11 @@ func GetMessageContext(messageID string, before, after int) (*MessageContext, er
 		SELECT messages.timestamp, messages.sender, chats.name, messages.content, messages.is_from_me, chats.jid, messages.id
 		FROM messages
 		JOIN chats ON messages.chat_jid = chats.jid
-		WHERE messages.chat_jid = ? AND messages.timestamp < ?
+		WHERE messages.chat_jid = ? AND julianday(messages.timestamp) < julianday(?)
 		ORDER BY messages.timestamp DESC
 		LIMIT ?
 	`
-	rowsBefore, err := db.Query(queryBefore, chatJID, timestampStr, before)
+	rowsBefore, err := db.Query(queryBefore, chatJID, targetMsg.Timestamp, before)
 	if err != nil {
 		return nil, fmt.Errorf("error retrieving previous messages: %v", err)
 	}
@@ -456,11 +880,11 @@ func GetMessageContext(messageID string, before, after int) (*MessageContext, er
 		SELECT messages.timestamp, messages.sender, chats.name, messages.content, messages.is_from_me, chats.jid, messages.id
 		FROM messages
 		JOIN chats ON messages.chat_jid = chats.jid
-		WHERE messages.chat_jid = ? AND messages.timestamp > ?
+		WHERE messages.chat_jid = ? AND julianday(messages.timestamp) > julianday(?)
 		ORDER BY messages.timestamp ASC
 		LIMIT ?
 	`
-	rowsAfter, err := db.Query(queryAfter, chatJID, timestampStr, after)
+	rowsAfter, err := db.Query(queryAfter, chatJID, targetMsg.Timestamp, after)
 	if err != nil {
 		return nil, fmt.Errorf("error retrieving following messages: %v", err)
 	}
@@ -499,15 +923,98 @@ func GetMessageContext(messageID string, before, after int) (*MessageContext, er
 		afterMessages = append(afterMessages, msg)
 	}
 
+	oldestID, newestID := targetMsg.ID, targetMsg.ID
+	if len(beforeMessages) > 0 {
+		oldestID = beforeMessages[len(beforeMessages)-1].ID
+	}
+	if len(afterMessages) > 0 {
+		newestID = afterMessages[len(afterMessages)-1].ID
+	}
+
 	return &MessageContext{
-		Message: targetMsg,
-		Before:  beforeMessages,
-		After:   afterMessages,
+		Message:  targetMsg,
+		Before:   beforeMessages,
+		After:    afterMessages,
+		OldestID: oldestID,
+		NewestID: newestID,
 	}, nil
 }
 
+// ScrollMessages retrieves the next chunk of messages before or after a boundary message in its chat.
+// Messages sharing a timestamp are ordered by ID, so none are skipped or repeated across pages.
+func ScrollMessages(chatJID string, messageID string, direction string, count int) (*MessagePage, error) {
+	if count <= 0 {
+		count = 10
+	}
+
+	var comparison, order string
+	switch direction {
+	case "before":
+		comparison, order = "<", "DESC"
+	case "after":
+		comparison, order = ">", "ASC"
+	default:
+		return nil, fmt.Errorf("direction must be either 'before' or 'after', got %q", direction)
+	}
+
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var exists bool
+	err = db.QueryRow("SELECT EXISTS (SELECT 1 FROM messages WHERE chat_jid = ? AND id = ?)", chatJID, messageID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("error reading data: %v", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("message with ID %s not found in chat %s", messageID, chatJID)
+	}
+
+	// Fetch one extra message to know whether there is more to scroll to
+	queryStr := fmt.Sprintf(`
+		SELECT messages.timestamp, messages.sender, chats.name, messages.content, messages.is_from_me, chats.jid, messages.id
+		FROM messages
+		JOIN chats ON messages.chat_jid = chats.jid
+		WHERE messages.chat_jid = ?
+			AND (messages.timestamp, messages.id) %s (SELECT timestamp, id FROM messages WHERE chat_jid = ? AND id = ?)
+		ORDER BY messages.timestamp %s, messages.id %s
+		LIMIT ?
+	`, comparison, order, order)
+
+	rows, err := db.Query(queryStr, chatJID, chatJID, messageID, count+1)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	messages, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &MessagePage{OldestID: messageID, NewestID: messageID}
+	if len(messages) > count {
+		page.HasMore = true
+		messages = messages[:count]
+	}
+
+	if direction == "before" {
+		slices.Reverse(messages)
+	}
+
+	page.Messages = messages
+	if len(messages) > 0 {
+		page.OldestID = messages[0].ID
+		page.NewestID = messages[len(messages)-1].ID
+	}
+
+	return page, nil
+}
+
 // ListChats retrieves chats matching specified criteria
-func ListChats(query string, limit, page int, includeLastMessage bool, sortBy string) ([]Chat, error) {
+func ListChats(query string, limit, page int, includeLastMessage bool, sortBy, chatType string) ([]Chat, error) {
 	if limit <= 0 {
 		limit = 20
 	}
@@ -547,6 +1054,16 @@ func ListChats(query string, limit, page int, includeLastMessage bool, sortBy st
 		params = append(params, "%"+query+"%", "%"+query+"%")
 	}
 
+	switch chatType {
+	case "", "all":
+	case "direct":
+		whereClauses = append(whereClauses, whatsappdb.DirectChatCondition)
+	case "group":
+		whereClauses = append(whereClauses, whatsappdb.GroupChatCondition)
+	default:
+		return nil, fmt.Errorf("invalid chat type %q, expected 'direct', 'group' or 'all'", chatType)
+	}
+
 	if len(whereClauses) > 0 {
 		queryParts = append(queryParts, "WHERE "+strings.Join(whereClauses, " AND "))
 	}
@@ -681,85 +1198,733 @@ func SearchContacts(query string) ([]Contact, error) {
 	return contacts, nil
 }
 
-// SendMessage sends a WhatsApp message to the specified recipient
-func SendMessage(recipient, message string) (bool, string) {
-	if recipient == "" {
-		return false, "Recipient must be provided"
-	}
-
-	url := fmt.Sprintf("%s/send", WhatsappAPIBaseURL)
-	payload := map[string]string{
-		"recipient": recipient,
-		"message":   message,
-	}
+// ChatRef represents a chat by JID and name only, for resolving names to JIDs cheaply
+type ChatRef struct {
+	JID     string
+	Name    string
+	IsGroup bool
+}
 
-	jsonData, err := json.Marshal(payload)
+// ListChatJIDs retrieves the JID and name of every chat whose name or JID matches the query,
+// without the last message lookups done by ListChats
+func ListChatJIDs(query string) ([]ChatRef, error) {
+	db, err := GetDB()
 	if err != nil {
-		return false, fmt.Sprintf("JSON serialization error: %v", err)
+		return nil, err
 	}
+	defer db.Close()
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	searchPattern := "%" + query + "%"
+
+	rows, err := db.Query(`
+		SELECT jid, name
+		FROM chats
+		WHERE LOWER(name) LIKE LOWER(?) OR LOWER(jid) LIKE LOWER(?)
+		ORDER BY name, jid
+	`, searchPattern, searchPattern)
 	if err != nil {
-		return false, fmt.Sprintf("Request error: %v", err)
+		return nil, fmt.Errorf("error executing query: %v", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusOK {
-		var result map[string]interface{}
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return false, fmt.Sprintf("Response decoding error: %v", err)
-		}
+	defer rows.Close()
 
-		success, ok := result["success"].(bool)
-		if !ok {
-			return false, "Unexpected response format"
+	chats := []ChatRef{}
+	for rows.Next() {
+		var chat ChatRef
+		var name sql.NullString
+		if err := rows.Scan(&chat.JID, &name); err != nil {
+			return nil, fmt.Errorf("error reading data: %v", err)
 		}
 
-		message, _ := result["message"].(string)
-		return success, message
+		chat.Name = name.String
+		chat.IsGroup = strings.HasSuffix(chat.JID, "@g.us")
+		chats = append(chats, chat)
 	}
 
-	body, _ := io.ReadAll(resp.Body)
-	return false, fmt.Sprintf("Error: HTTP %d - %s", resp.StatusCode, string(body))
+	return chats, rows.Err()
 }
 
-// GetChat retrieves metadata for a WhatsApp chat by JID
-func GetChat(chatJID string, includeLastMessage bool) (*Chat, error) {
-	db, err := GetDB()
+// apiResponse mirrors the generic response returned by the WhatsApp bridge API
+type apiResponse struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// callAPI sends a JSON request to the WhatsApp bridge API and decodes its response
+func callAPI(method, path string, payload any) (*apiResponse, error) {
+	body, err := requestAPI(method, path, payload)
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
-
-	queryStr := `
-		SELECT 
-			chats.jid,
-			chats.name,
-			chats.last_message_time
-	`
 
-	if includeLastMessage {
-		queryStr += `,
-			messages.content as last_message,
-			messages.sender as last_sender,
-			messages.is_from_me as last_is_from_me
-		FROM chats
-		LEFT JOIN messages ON chats.jid = messages.chat_jid 
-		AND chats.last_message_time = messages.timestamp
-		`
-	} else {
-		queryStr += `
-		FROM chats
-		`
+	var result apiResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("Response decoding error: %v", err)
 	}
 
-	queryStr += `
-		WHERE chats.jid = ?
-	`
-
-	row := db.QueryRow(queryStr, chatJID)
+	return &result, nil
+}
 
-	var chat Chat
+// requestAPI sends a request to the WhatsApp bridge API and returns the raw response body
+func requestAPI(method, path string, payload any) ([]byte, error) {
+	var body io.Reader
+	if payload != nil {
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("JSON serialization error: %v", err)
+		}
+		body = bytes.NewBuffer(jsonData)
+	}
+
+	req, err := http.NewRequest(method, WhatsappAPIBaseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("Request error: %v", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Request error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Response reading error: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error: HTTP %d - %s", resp.StatusCode, string(respBody))
+	}
+
+	return respBody, nil
+}
+
+// SendResult represents the outcome of sending a message
+type SendResult struct {
+	Reconnected   bool   `json:"reconnected"`
+	QuotedSnippet string `json:"quoted_snippet,omitempty"`
+	QuotedSender  string `json:"quoted_sender,omitempty"`
+	RecipientName string `json:"recipient_name,omitempty"`
+	MessageID     string `json:"message_id,omitempty"`
+	// Delivery lists the devices the message did not reach, set only when it was partially delivered
+	Delivery *DeliveryReport `json:"delivery,omitempty"`
+}
+
+// DeliveryReport lists the devices a sent message could not be delivered to
+type DeliveryReport struct {
+	FailedDevices       []DeviceFailure `json:"failed_devices,omitempty"`
+	ParticipantsChanged bool            `json:"participants_changed,omitempty"`
+}
+
+// DeviceFailure represents a device a message could not be encrypted for
+type DeviceFailure struct {
+	JID   string `json:"jid"`
+	Error string `json:"error"`
+}
+
+// SendMessage sends a WhatsApp message to the specified recipient, optionally with a link preview
+// or as a reply to a stored message. With requireKnownContact, unknown recipients are refused.
+// A non-empty messageID is used as the ID of the sent message.
+func SendMessage(recipient, message string, withPreview bool, quotedMessageID string, expireSeconds int, requireKnownContact bool, messageID string) (bool, string, SendResult) {
+	if recipient == "" {
+		return false, "Recipient must be provided", SendResult{}
+	}
+
+	result, err := callAPI(http.MethodPost, "/send", map[string]interface{}{
+		"recipient":             recipient,
+		"message":               message,
+		"with_preview":          withPreview,
+		"quoted_message_id":     quotedMessageID,
+		"expire_seconds":        expireSeconds,
+		"require_known_contact": requireKnownContact,
+		"message_id":            messageID,
+	})
+	if err != nil {
+		return false, err.Error(), SendResult{}
+	}
+
+	var sendResult SendResult
+	if len(result.Data) > 0 {
+		if err := json.Unmarshal(result.Data, &sendResult); err != nil {
+			return false, fmt.Sprintf("Response decoding error: %v", err), SendResult{}
+		}
+	}
+
+	return result.Success, result.Message, sendResult
+}
+
+// GetLatestMessageID retrieves the ID of the most recent message in a chat
+func GetLatestMessageID(chatJID string) (string, error) {
+	db, err := GetDB()
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	var messageID string
+	err = db.QueryRow(`
+		SELECT id
+		FROM messages
+		WHERE chat_jid = ?
+		ORDER BY julianday(timestamp) DESC
+		LIMIT 1
+	`, chatJID).Scan(&messageID)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("no messages found in chat %s", chatJID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error finding latest message: %v", err)
+	}
+
+	return messageID, nil
+}
+
+// SendVideo sends an MP4 file to the specified recipient, optionally as a looping GIF
+func SendVideo(recipient, mediaPath, caption string, asGIF bool) (bool, string) {
+	if recipient == "" || mediaPath == "" {
+		return false, "Recipient and media path must be provided"
+	}
+
+	if !strings.EqualFold(filepath.Ext(mediaPath), ".mp4") {
+		return false, "Video must be an MP4 file"
+	}
+
+	// The bridge resolves relative paths against its send media directory
+	result, err := callAPI(http.MethodPost, "/send/video", map[string]interface{}{
+		"recipient":  recipient,
+		"media_path": mediaPath,
+		"caption":    caption,
+		"as_gif":     asGIF,
+	})
+	if err != nil {
+		return false, err.Error()
+	}
+
+	return result.Success, result.Message
+}
+
+// RecipientResult represents the outcome of sending a broadcast to one recipient
+type RecipientResult struct {
+	Recipient string    `json:"recipient"`
+	JID       string    `json:"jid,omitempty"`
+	Status    string    `json:"status"`
+	MessageID string    `json:"message_id,omitempty"`
+	Timestamp time.Time `json:"timestamp,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// BroadcastResult represents the outcome of a broadcast and the pseudo-chat it is recorded under
+type BroadcastResult struct {
+	ChatJID    string            `json:"chat_jid"`
+	Sent       int               `json:"sent"`
+	Failed     int               `json:"failed"`
+	Recipients []RecipientResult `json:"recipients"`
+}
+
+// SendBroadcast sends a WhatsApp message to each recipient individually
+func SendBroadcast(recipients []string, message string) (*BroadcastResult, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("at least one recipient must be provided")
+	}
+
+	result, err := callAPI(http.MethodPost, "/broadcast", map[string]interface{}{
+		"recipients": recipients,
+		"message":    message,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var broadcast BroadcastResult
+	if err := json.Unmarshal(result.Data, &broadcast); err != nil {
+		return nil, fmt.Errorf("Response decoding error: %v", err)
+	}
+
+	return &broadcast, nil
+}
+
+// SendReaction reacts to a WhatsApp message with the given emoji
+func SendReaction(chatJID, messageID, emoji string) (bool, string) {
+	if chatJID == "" || messageID == "" {
+		return false, "Chat JID and message ID must be provided"
+	}
+
+	result, err := callAPI(http.MethodPost, "/react", map[string]string{
+		"chat_jid":   chatJID,
+		"message_id": messageID,
+		"emoji":      emoji,
+	})
+	if err != nil {
+		return false, err.Error()
+	}
+
+	return result.Success, result.Message
+}
+
+// RetryMediaDownload asks the bridge to download media again for messages missing their file
+func RetryMediaDownload(chatJID string, limit int) ([]MediaDownloadResult, error) {
+	result, err := callAPI(http.MethodPost, "/media/retry", map[string]interface{}{
+		"chat_jid": chatJID,
+		"limit":    limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, errors.New(result.Message)
+	}
+
+	var results []MediaDownloadResult
+	if err := json.Unmarshal(result.Data, &results); err != nil {
+		return nil, fmt.Errorf("Response decoding error: %v", err)
+	}
+
+	return results, nil
+}
+
+// ResyncChat asks the bridge to refresh a chat's name from WhatsApp and returns the updated chat
+func ResyncChat(chatJID string) (*Chat, error) {
+	if chatJID == "" {
+		return nil, errors.New("chat JID must be provided")
+	}
+
+	result, err := callAPI(http.MethodPost, "/chats/"+url.PathEscape(chatJID)+"/resync", nil)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, errors.New(result.Message)
+	}
+
+	var data struct {
+		JID             string    `json:"jid"`
+		Name            string    `json:"name"`
+		LastMessageTime time.Time `json:"last_message_time"`
+	}
+	if err := json.Unmarshal(result.Data, &data); err != nil {
+		return nil, fmt.Errorf("Response decoding error: %v", err)
+	}
+
+	return &Chat{
+		JID:             data.JID,
+		Name:            data.Name,
+		LastMessageTime: data.LastMessageTime,
+	}, nil
+}
+
+// GetLabels retrieves all labels along with the chats they are assigned to
+func GetLabels() (map[string]interface{}, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`
+		SELECT labels.id, labels.name, labels.color, labels.synced, chat_labels.chat_jid
+		FROM labels
+		LEFT JOIN chat_labels ON chat_labels.label_id = labels.id
+		ORDER BY labels.name, chat_labels.chat_jid
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying database: %v", err)
+	}
+	defer rows.Close()
+
+	labels := []Label{}
+	chats := map[string][]string{}
+	for rows.Next() {
+		var label Label
+		var chatJID sql.NullString
+		if err := rows.Scan(&label.ID, &label.Name, &label.Color, &label.Synced, &chatJID); err != nil {
+			return nil, fmt.Errorf("error reading data: %v", err)
+		}
+
+		if _, seen := chats[label.ID]; !seen {
+			labels = append(labels, label)
+			chats[label.ID] = []string{}
+		}
+		if chatJID.Valid {
+			chats[label.ID] = append(chats[label.ID], chatJID.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading data: %v", err)
+	}
+
+	return map[string]interface{}{
+		"labels": labels,
+		"chats":  chats,
+	}, nil
+}
+
+// CreateLabel asks the bridge to create a label
+func CreateLabel(name string, color int) (*Label, error) {
+	result, err := callAPI(http.MethodPost, "/labels", map[string]interface{}{
+		"name":  name,
+		"color": color,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, errors.New(result.Message)
+	}
+
+	var label Label
+	if err := json.Unmarshal(result.Data, &label); err != nil {
+		return nil, fmt.Errorf("Response decoding error: %v", err)
+	}
+
+	return &label, nil
+}
+
+// AssignLabel asks the bridge to assign a label to a chat or remove it
+func AssignLabel(chatJID, labelID string, assigned bool) (bool, string) {
+	if chatJID == "" || labelID == "" {
+		return false, "Chat JID and label ID must be provided"
+	}
+
+	result, err := callAPI(http.MethodPost, "/labels/assign", map[string]interface{}{
+		"chat_jid": chatJID,
+		"label_id": labelID,
+		"assigned": assigned,
+	})
+	if err != nil {
+		return false, err.Error()
+	}
+
+	return result.Success, result.Message
+}
+
+// DeleteMedia asks the bridge to delete the downloaded media file of a message, keeping the message.
+// It reports whether a file was deleted.
+func DeleteMedia(chatJID, messageID string) (bool, error) {
+	if chatJID == "" || messageID == "" {
+		return false, errors.New("chat JID and message ID must be provided")
+	}
+
+	result, err := callAPI(http.MethodPost, "/media/delete", map[string]string{
+		"chat_jid":   chatJID,
+		"message_id": messageID,
+	})
+	if err != nil {
+		return false, err
+	}
+	if !result.Success {
+		return false, errors.New(result.Message)
+	}
+
+	var data struct {
+		Deleted bool `json:"deleted"`
+	}
+	if err := json.Unmarshal(result.Data, &data); err != nil {
+		return false, fmt.Errorf("Response decoding error: %v", err)
+	}
+
+	return data.Deleted, nil
+}
+
+// StorageUsage represents the disk space used by the bridge's store
+type StorageUsage struct {
+	MessagesDBBytes         int64 `json:"messages_db_bytes"`
+	SessionDBBytes          int64 `json:"session_db_bytes"`
+	MediaBytes              int64 `json:"media_bytes"`
+	TotalBytes              int64 `json:"total_bytes"`
+	MediaFiles              int   `json:"media_files"`
+	Messages                int   `json:"messages"`
+	MediaMessages           int   `json:"media_messages"`
+	DownloadedMediaMessages int   `json:"downloaded_media_messages"`
+}
+
+// GetStorageUsage retrieves the disk space used by the bridge's databases and downloaded media
+func GetStorageUsage() (*StorageUsage, error) {
+	result, err := callAPI(http.MethodGet, "/storage", nil)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, errors.New(result.Message)
+	}
+
+	var usage StorageUsage
+	if err := json.Unmarshal(result.Data, &usage); err != nil {
+		return nil, fmt.Errorf("Response decoding error: %v", err)
+	}
+
+	return &usage, nil
+}
+
+// ChatReadResult represents the outcome of marking a chat read
+type ChatReadResult struct {
+	ChatJID string `json:"chat_jid"`
+	Marked  int    `json:"marked"`
+	Batches int    `json:"batches"`
+}
+
+// MarkChatRead marks every unread message of a chat as read on WhatsApp
+func MarkChatRead(chatJID string) (*ChatReadResult, error) {
+	if chatJID == "" {
+		return nil, errors.New("chat JID must be provided")
+	}
+
+	result, err := callAPI(http.MethodPost, "/chats/"+url.PathEscape(chatJID)+"/read", nil)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, errors.New(result.Message)
+	}
+
+	var data ChatReadResult
+	if err := json.Unmarshal(result.Data, &data); err != nil {
+		return nil, fmt.Errorf("Response decoding error: %v", err)
+	}
+
+	return &data, nil
+}
+
+// Counts represents the number of contacts, chats and messages known to the bridge
+type Counts struct {
+	Contacts    int `json:"contacts"`
+	DirectChats int `json:"direct_chats"`
+	GroupChats  int `json:"group_chats"`
+	Messages    int `json:"messages"`
+}
+
+// GetCounts retrieves contact, chat and message totals without listing them
+func GetCounts() (*Counts, error) {
+	result, err := callAPI(http.MethodGet, "/counts", nil)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, errors.New(result.Message)
+	}
+
+	var counts Counts
+	if err := json.Unmarshal(result.Data, &counts); err != nil {
+		return nil, fmt.Errorf("Response decoding error: %v", err)
+	}
+
+	return &counts, nil
+}
+
+// HistorySyncStatus represents the progress of the current or last history sync
+type HistorySyncStatus struct {
+	State         string     `json:"state"`
+	SyncType      string     `json:"sync_type,omitempty"`
+	Progress      uint32     `json:"progress"`
+	Chunks        int        `json:"chunks"`
+	Conversations int        `json:"conversations"`
+	Messages      int        `json:"messages"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	LastChunkAt   *time.Time `json:"last_chunk_at,omitempty"`
+}
+
+// GetHistorySyncStatus retrieves whether a history sync is running and how much it has ingested
+func GetHistorySyncStatus() (*HistorySyncStatus, error) {
+	result, err := callAPI(http.MethodGet, "/history-sync/status", nil)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, errors.New(result.Message)
+	}
+
+	var status HistorySyncStatus
+	if err := json.Unmarshal(result.Data, &status); err != nil {
+		return nil, fmt.Errorf("Response decoding error: %v", err)
+	}
+
+	return &status, nil
+}
+
+// RequestHistorySync asks the bridge to request message history from WhatsApp
+func RequestHistorySync() (bool, string) {
+	result, err := callAPI(http.MethodPost, "/history-sync", nil)
+	if err != nil {
+		return false, err.Error()
+	}
+
+	return result.Success, result.Message
+}
+
+// IngestionState represents the effect of pausing or resuming message ingestion on the bridge
+type IngestionState struct {
+	Paused           bool `json:"paused"`
+	BufferedMessages int  `json:"buffered_messages"`
+	BufferLimit      int  `json:"buffer_limit,omitempty"`
+	Flushed          int  `json:"flushed"`
+	AutoResumed      bool `json:"auto_resumed,omitempty"`
+}
+
+// PauseIngestion stops the bridge from writing received messages to the database until resumed
+func PauseIngestion() (*IngestionState, error) {
+	return setIngestion("/ingestion/pause")
+}
+
+// ResumeIngestion stores the messages buffered while paused and resumes writing them as they arrive
+func ResumeIngestion() (*IngestionState, error) {
+	return setIngestion("/ingestion/resume")
+}
+
+func setIngestion(path string) (*IngestionState, error) {
+	result, err := callAPI(http.MethodPost, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, errors.New(result.Message)
+	}
+
+	var data IngestionState
+	if err := json.Unmarshal(result.Data, &data); err != nil {
+		return nil, fmt.Errorf("Response decoding error: %v", err)
+	}
+
+	return &data, nil
+}
+
+// ExportContacts retrieves all contacts from the bridge as JSON or CSV
+func ExportContacts(format string) (string, error) {
+	switch format {
+	case "", "json":
+		result, err := callAPI(http.MethodGet, "/contacts/export?format=json", nil)
+		if err != nil {
+			return "", err
+		}
+		if !result.Success {
+			return "", errors.New(result.Message)
+		}
+		return string(result.Data), nil
+	case "csv":
+		body, err := requestAPI(http.MethodGet, "/contacts/export?format=csv", nil)
+		if err != nil {
+			return "", err
+		}
+		return string(body), nil
+	}
+
+	return "", fmt.Errorf("unsupported format %q, expected json or csv", format)
+}
+
+// ListChatsModifiedSince retrieves chats with a message after since, oldest change first.
+// Chats changed at the same time are ordered by JID, and sinceJID skips those up to and
+// including it, so passing NextSyncTimestamp and NextSyncJID back returns only the chats
+// not seen yet, even when a page ends between chats sharing a timestamp.
+func ListChatsModifiedSince(since time.Time, sinceJID string, limit int) (*ChatSync, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	condition := "julianday(last_message_time) > julianday(?)"
+	params := []interface{}{since}
+	if sinceJID != "" {
+		condition = "(" + condition + " OR (julianday(last_message_time) = julianday(?) AND jid > ?))"
+		params = append(params, since, sinceJID)
+	}
+
+	// Fetch one extra row to know whether the client needs to sync again
+	rows, err := db.Query(`
+		SELECT jid, name, last_message_time
+		FROM chats
+		WHERE `+condition+`
+		ORDER BY julianday(last_message_time), jid
+		LIMIT ?
+	`, append(params, limit+1)...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	result := &ChatSync{
+		Chats:             []Chat{},
+		NextSyncTimestamp: since,
+		NextSyncJID:       sinceJID,
+	}
+
+	for rows.Next() {
+		var chat Chat
+		var name sql.NullString
+		var timestampStr string
+
+		if err := rows.Scan(&chat.JID, &name, &timestampStr); err != nil {
+			return nil, fmt.Errorf("error reading data: %v", err)
+		}
+
+		if len(result.Chats) == limit {
+			result.HasMore = true
+			break
+		}
+
+		if name.Valid {
+			chat.Name = name.String
+		}
+
+		chat.LastMessageTime, err = parseTimestamp(timestampStr)
+		if err != nil {
+			return nil, fmt.Errorf("error converting timestamp: %v", err)
+		}
+
+		result.Chats = append(result.Chats, chat)
+		result.NextSyncTimestamp = chat.LastMessageTime
+		result.NextSyncJID = chat.JID
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error traversing results: %v", err)
+	}
+
+	return result, nil
+}
+
+// GetChat retrieves metadata for a WhatsApp chat by JID
+func GetChat(chatJID string, includeLastMessage bool) (*Chat, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	queryStr := `
+		SELECT 
+			chats.jid,
+			chats.name,
+			chats.last_message_time
+	`
+
+	if includeLastMessage {
+		queryStr += `,
+			messages.content as last_message,
+			messages.sender as last_sender,
+			messages.is_from_me as last_is_from_me
+		FROM chats
+		LEFT JOIN messages ON chats.jid = messages.chat_jid 
+		AND chats.last_message_time = messages.timestamp
+		`
+	} else {
+		queryStr += `
+		FROM chats
+		`
+	}
+
+	queryStr += `
+		WHERE chats.jid = ?
+	`
+
+	row := db.QueryRow(queryStr, chatJID)
+
+	var chat Chat
 	var timestampStr sql.NullString
 	var name, lastMessage, lastSender sql.NullString
 	var lastIsFromMe sql.NullBool
@@ -774,65 +1939,808 @@ func GetChat(chatJID string, includeLastMessage bool) (*Chat, error) {
 			&lastIsFromMe,
 		)
 	} else {
-		err = row.Scan(
-			&chat.JID,
-			&name,
-			&timestampStr,
-		)
+		err = row.Scan(
+			&chat.JID,
+			&name,
+			&timestampStr,
+		)
+	}
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("chat with JID %s not found", chatJID)
+		}
+		return nil, fmt.Errorf("error reading data: %v", err)
+	}
+
+	if name.Valid {
+		chat.Name = name.String
+	}
+
+	if timestampStr.Valid {
+		chat.LastMessageTime, err = time.Parse(time.RFC3339, timestampStr.String)
+		if err != nil {
+			return nil, fmt.Errorf("error converting timestamp: %v", err)
+		}
+	}
+
+	if includeLastMessage {
+		if lastMessage.Valid {
+			chat.LastMessage = lastMessage.String
+		}
+
+		if lastSender.Valid {
+			chat.LastSender = lastSender.String
+		}
+
+		if lastIsFromMe.Valid {
+			chat.LastIsFromMe = lastIsFromMe.Bool
+		}
+	}
+
+	rows, err := db.Query(`
+		SELECT labels.name
+		FROM chat_labels
+		JOIN labels ON labels.id = chat_labels.label_id
+		WHERE chat_labels.chat_jid = ?
+		ORDER BY labels.name
+	`, chatJID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying labels: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var label string
+		if err := rows.Scan(&label); err != nil {
+			return nil, fmt.Errorf("error reading data: %v", err)
+		}
+		chat.Labels = append(chat.Labels, label)
+	}
+
+	return &chat, rows.Err()
+}
+
+// GetDirectChatByContact retrieves metadata for a direct chat by phone number
+func GetDirectChatByContact(phoneNumber string) (*Chat, error) {
+	jid := phoneNumber
+	if !strings.Contains(jid, "@") {
+		jid = phoneNumber + "@s.whatsapp.net"
+	}
+
+	return GetChat(jid, true)
+}
+
+// GetContactChats retrieves all chats involving the contact
+func GetContactChats(jid string, limit, page int) ([]Chat, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if page < 0 {
+		page = 0
+	}
+
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	phoneNumber := jid
+	if strings.Contains(jid, "@") {
+		parts := strings.Split(jid, "@")
+		phoneNumber = parts[0]
+	}
+
+	queryStr := `
+		SELECT DISTINCT
+			chats.jid,
+			chats.name,
+			chats.last_message_time,
+			messages.content as last_message,
+			messages.sender as last_sender,
+			messages.is_from_me as last_is_from_me
+		FROM chats
+		LEFT JOIN messages ON chats.jid = messages.chat_jid 
+		AND chats.last_message_time = messages.timestamp
+		JOIN messages as m ON chats.jid = m.chat_jid
+		WHERE 
+			(chats.jid = ? OR m.sender = ? OR m.sender LIKE ?)
+		GROUP BY chats.jid
+		ORDER BY chats.last_message_time DESC
+		LIMIT ? OFFSET ?
+	`
+
+	offset := page * limit
+	rows, err := db.Query(queryStr, jid, phoneNumber, "%"+phoneNumber+"%", limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	var chats []Chat
+
+	for rows.Next() {
+		var chat Chat
+		var timestampStr sql.NullString
+		var name, lastMessage, lastSender sql.NullString
+		var lastIsFromMe sql.NullBool
+
+		err := rows.Scan(
+			&chat.JID,
+			&name,
+			&timestampStr,
+			&lastMessage,
+			&lastSender,
+			&lastIsFromMe,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error reading data: %v", err)
+		}
+
+		if name.Valid {
+			chat.Name = name.String
+		}
+
+		if timestampStr.Valid {
+			chat.LastMessageTime, err = time.Parse(time.RFC3339, timestampStr.String)
+			if err != nil {
+				return nil, fmt.Errorf("error converting timestamp: %v", err)
+			}
+		}
+
+		if lastMessage.Valid {
+			chat.LastMessage = lastMessage.String
+		}
+
+		if lastSender.Valid {
+			chat.LastSender = lastSender.String
+		}
+
+		if lastIsFromMe.Valid {
+			chat.LastIsFromMe = lastIsFromMe.Bool
+		}
+
+		chats = append(chats, chat)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error traversing results: %v", err)
+	}
+
+	return chats, nil
+}
+
+// RawMessage represents the base64 encoded proto of a received message, stored when the
+// bridge runs with DEBUG_STORE_RAW. Size is the full proto length, cut short when Truncated is set.
+type RawMessage struct {
+	MessageID  string
+	ChatJID    string
+	Raw        string
+	Size       int
+	Truncated  bool
+	ReceivedAt time.Time
+}
+
+// GetRawMessage retrieves the stored raw proto of a message. chatJID may be empty when
+// the message ID is unambiguous.
+func GetRawMessage(messageID, chatJID string) (*RawMessage, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var raw RawMessage
+	var receivedAt string
+	err = db.QueryRow(`
+		SELECT message_id, chat_jid, raw, size, truncated, received_at
+		FROM raw_messages
+		WHERE message_id = ? AND (? = '' OR chat_jid = ?)
+		ORDER BY received_at DESC
+		LIMIT 1
+	`, messageID, chatJID, chatJID).Scan(&raw.MessageID, &raw.ChatJID, &raw.Raw, &raw.Size, &raw.Truncated, &receivedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no raw message stored for %s, raw storage requires DEBUG_STORE_RAW on the bridge", messageID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading raw message: %v", err)
+	}
+
+	raw.ReceivedAt, err = parseTimestamp(receivedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error converting timestamp: %v", err)
+	}
+
+	return &raw, nil
+}
+
+// LargeMedia represents a media message and the size of its attachment
+type LargeMedia struct {
+	MessageID  string
+	ChatJID    string
+	ChatName   string
+	MediaType  string
+	SizeBytes  int64
+	Downloaded bool
+	Timestamp  time.Time
+}
+
+// ListLargeMedia retrieves media messages with attachments of at least minSize bytes, largest first
+func ListLargeMedia(minSize int64, limit int) ([]LargeMedia, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`
+		SELECT messages.id, messages.chat_jid, chats.name, messages.media_type, messages.file_length,
+			messages.media_path IS NOT NULL, messages.timestamp
+		FROM messages
+		LEFT JOIN chats ON chats.jid = messages.chat_jid
+		WHERE messages.media_type IS NOT NULL AND messages.file_length >= ?
+		ORDER BY messages.file_length DESC
+		LIMIT ?
+	`, minSize, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	media := []LargeMedia{}
+	for rows.Next() {
+		var item LargeMedia
+		var chatName sql.NullString
+		var timestamp string
+		err := rows.Scan(&item.MessageID, &item.ChatJID, &chatName, &item.MediaType, &item.SizeBytes, &item.Downloaded, &timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("error reading data: %v", err)
+		}
+
+		item.ChatName = chatName.String
+		item.Timestamp, err = parseTimestamp(timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("error converting timestamp: %v", err)
+		}
+
+		media = append(media, item)
+	}
+
+	return media, rows.Err()
+}
+
+// SharedChat represents a chat a contact takes part in
+type SharedChat struct {
+	JID             string
+	Name            string
+	Type            string // "direct" or "group"
+	LastMessageTime time.Time
+}
+
+// GetSharedChats retrieves the direct chat with a contact and every group they are a member of,
+// whether or not they have sent anything there. jid may be a JID or a phone number.
+func GetSharedChats(jid string) ([]SharedChat, error) {
+	if jid == "" {
+		return nil, errors.New("jid must be provided")
+	}
+	if !strings.Contains(jid, "@") {
+		jid = strings.TrimPrefix(jid, "+") + "@s.whatsapp.net"
+	}
+
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`
+		SELECT jid, name, last_message_time, 'direct'
+		FROM chats
+		WHERE jid = ?
+		UNION ALL
+		SELECT group_participants.group_jid, chats.name, chats.last_message_time, 'group'
+		FROM group_participants
+		LEFT JOIN chats ON chats.jid = group_participants.group_jid
+		WHERE group_participants.participant_jid = ?
+	`, jid, jid)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	chats := []SharedChat{}
+	for rows.Next() {
+		var chat SharedChat
+		var name, lastMessageTime sql.NullString
+		if err := rows.Scan(&chat.JID, &name, &lastMessageTime, &chat.Type); err != nil {
+			return nil, fmt.Errorf("error reading data: %v", err)
+		}
+
+		chat.Name = name.String
+		if lastMessageTime.Valid {
+			chat.LastMessageTime, err = parseTimestamp(lastMessageTime.String)
+			if err != nil {
+				return nil, fmt.Errorf("error converting timestamp: %v", err)
+			}
+		}
+
+		chats = append(chats, chat)
+	}
+
+	return chats, rows.Err()
+}
+
+// GetLastInteraction retrieves the most recent message involving the contact
+func GetLastInteraction(jid string) (*Message, error) {
+	return getInteraction(jid, "DESC")
+}
+
+// GetFirstInteraction retrieves the earliest stored message involving the contact
+func GetFirstInteraction(jid string) (*Message, error) {
+	return getInteraction(jid, "ASC")
+}
+
+// getInteraction retrieves the first message involving the contact in the given timestamp order
+func getInteraction(jid string, order string) (*Message, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	phoneNumber := jid
+	if strings.Contains(jid, "@") {
+		parts := strings.Split(jid, "@")
+		phoneNumber = parts[0]
+	} else {
+		// A phone number also selects our own messages in the direct chat
+		jid = phoneNumber + "@s.whatsapp.net"
 	}
 
+	queryStr := fmt.Sprintf(`
+		SELECT 
+			messages.timestamp,
+			messages.sender,
+			chats.name,
+			messages.content,
+			messages.is_from_me,
+			chats.jid,
+			messages.id
+		FROM messages
+		JOIN chats ON messages.chat_jid = chats.jid
+		WHERE 
+			(messages.chat_jid = ? OR messages.sender = ? OR messages.sender LIKE ?)
+		ORDER BY messages.timestamp %s
+		LIMIT 1
+	`, order)
+
+	row := db.QueryRow(queryStr, jid, phoneNumber, "%"+phoneNumber+"%")
+
+	var msg Message
+	var timestampStr string
+	var chatName sql.NullString
+
+	err = row.Scan(
+		&timestampStr,
+		&msg.Sender,
+		&chatName,
+		&msg.Content,
+		&msg.IsFromMe,
+		&msg.ChatJID,
+		&msg.ID,
+	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("chat with JID %s not found", chatJID)
+			return nil, fmt.Errorf("no message found for contact with JID %s", jid)
 		}
 		return nil, fmt.Errorf("error reading data: %v", err)
 	}
 
-	if name.Valid {
-		chat.Name = name.String
+	msg.Timestamp, err = time.Parse(time.RFC3339, timestampStr)
+	if err != nil {
+		return nil, fmt.Errorf("error converting timestamp: %v", err)
 	}
 
-	if timestampStr.Valid {
-		chat.LastMessageTime, err = time.Parse(time.RFC3339, timestampStr.String)
+	if chatName.Valid {
+		msg.ChatName = chatName.String
+	} else {
+		msg.ChatName = "Unknown Chat"
+	}
+
+	return &msg, nil
+}
+
+// GetMessageReactions retrieves all reactions on a message of a chat grouped by emoji
+func GetMessageReactions(chatJID, messageID string) (*MessageReactions, error) {
+	if chatJID == "" || messageID == "" {
+		return nil, errors.New("chat JID and message ID must be provided")
+	}
+
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`
+		SELECT sender, emoji
+		FROM reactions
+		WHERE chat_jid = ? AND message_id = ? AND emoji != ''
+		ORDER BY timestamp
+	`, chatJID, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	result := &MessageReactions{MessageID: messageID, ChatJID: chatJID}
+	index := map[string]int{}
+
+	for rows.Next() {
+		var sender, emoji string
+		if err := rows.Scan(&sender, &emoji); err != nil {
+			return nil, fmt.Errorf("error reading data: %v", err)
+		}
+
+		i, ok := index[emoji]
+		if !ok {
+			i = len(result.Reactions)
+			index[emoji] = i
+			result.Reactions = append(result.Reactions, ReactionSummary{Emoji: emoji})
+		}
+
+		result.Reactions[i].Count++
+		result.Reactions[i].Reactors = append(result.Reactions[i].Reactors, sender)
+		result.Total++
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error traversing results: %v", err)
+	}
+
+	return result, nil
+}
+
+// WasMessageRead retrieves the delivery and read status of a message of a chat from its stored
+// receipts. Messages without receipts are reported as sent.
+func WasMessageRead(chatJID, messageID string) (*MessageReadStatus, error) {
+	if chatJID == "" || messageID == "" {
+		return nil, errors.New("chat JID and message ID must be provided")
+	}
+
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	result := &MessageReadStatus{MessageID: messageID, ChatJID: chatJID, Status: "sent"}
+
+	var sentAtStr string
+	err = db.QueryRow("SELECT timestamp FROM messages WHERE chat_jid = ? AND id = ?", chatJID, messageID).Scan(&sentAtStr)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error reading data: %v", err)
+	}
+	if err == nil {
+		sentAt, err := parseTimestamp(sentAtStr)
 		if err != nil {
 			return nil, fmt.Errorf("error converting timestamp: %v", err)
 		}
+		result.SentAt = &sentAt
 	}
 
-	if includeLastMessage {
-		if lastMessage.Valid {
-			chat.LastMessage = lastMessage.String
+	rows, err := db.Query(`
+		SELECT participant, delivered_at, read_at
+		FROM receipts
+		WHERE chat_jid = ? AND message_id = ?
+		ORDER BY participant
+	`, chatJID, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	found := result.SentAt != nil
+	for rows.Next() {
+		var participant ParticipantReceipt
+		var deliveredAtStr, readAtStr sql.NullString
+
+		if err := rows.Scan(&participant.JID, &deliveredAtStr, &readAtStr); err != nil {
+			return nil, fmt.Errorf("error reading data: %v", err)
+		}
+		found = true
+
+		participant.Status = "sent"
+		if deliveredAtStr.Valid {
+			deliveredAt, err := parseTimestamp(deliveredAtStr.String)
+			if err != nil {
+				return nil, fmt.Errorf("error converting timestamp: %v", err)
+			}
+			participant.DeliveredAt = &deliveredAt
+			participant.Status = "delivered"
+		}
+		if readAtStr.Valid {
+			readAt, err := parseTimestamp(readAtStr.String)
+			if err != nil {
+				return nil, fmt.Errorf("error converting timestamp: %v", err)
+			}
+			participant.ReadAt = &readAt
+			participant.Status = "read"
+			// A read receipt can arrive without a delivery receipt
+			if participant.DeliveredAt == nil {
+				participant.DeliveredAt = &readAt
+			}
+		}
+
+		result.DeliveredAt = earliest(result.DeliveredAt, participant.DeliveredAt)
+		result.ReadAt = earliest(result.ReadAt, participant.ReadAt)
+		result.Participants = append(result.Participants, participant)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error traversing results: %v", err)
+	}
+
+	if !found {
+		return nil, fmt.Errorf("message %s not found in chat %s", messageID, chatJID)
+	}
+
+	if result.ReadAt != nil {
+		result.Status = "read"
+	} else if result.DeliveredAt != nil {
+		result.Status = "delivered"
+	}
+
+	if !strings.HasSuffix(result.ChatJID, "@g.us") {
+		result.Participants = nil
+	}
+
+	return result, nil
+}
+
+// earliest returns the earlier of two optional times
+func earliest(a, b *time.Time) *time.Time {
+	if a == nil || (b != nil && b.Before(*a)) {
+		return b
+	}
+	return a
+}
+
+// scanMessages reads rows selecting timestamp, sender, chat name, content, is_from_me, chat JID and ID
+func scanMessages(rows *sql.Rows) ([]Message, error) {
+	var messages []Message
+
+	for rows.Next() {
+		var msg Message
+		var timestampStr string
+		var chatName sql.NullString
+
+		err := rows.Scan(
+			&timestampStr,
+			&msg.Sender,
+			&chatName,
+			&msg.Content,
+			&msg.IsFromMe,
+			&msg.ChatJID,
+			&msg.ID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error reading data: %v", err)
+		}
+
+		msg.Timestamp, err = time.Parse(time.RFC3339, timestampStr)
+		if err != nil {
+			return nil, fmt.Errorf("error converting timestamp: %v", err)
+		}
+
+		if chatName.Valid {
+			msg.ChatName = chatName.String
+		} else {
+			msg.ChatName = "Unknown Chat"
+		}
+
+		messages = append(messages, msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error traversing results: %v", err)
+	}
+
+	return messages, nil
+}
+
+// ListMentions retrieves messages that mention the logged-in account, newest first
+func ListMentions(limit, page int) ([]Message, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if page < 0 {
+		page = 0
+	}
+
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	queryStr := `
+		SELECT
+			messages.timestamp,
+			messages.sender,
+			chats.name,
+			messages.content,
+			messages.is_from_me,
+			chats.jid,
+			messages.id
+		FROM messages
+		JOIN chats ON messages.chat_jid = chats.jid
+		WHERE messages.mentions_me = 1
+		ORDER BY messages.timestamp DESC
+		LIMIT ? OFFSET ?
+	`
+
+	rows, err := db.Query(queryStr, limit, page*limit)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	return scanMessages(rows)
+}
+
+// parseTimestamp parses a timestamp returned by an aggregate or expression column,
+// which the driver leaves in its storage format instead of converting to time.Time
+func parseTimestamp(value string) (time.Time, error) {
+	layouts := append([]string{time.RFC3339Nano}, sqlite3.SQLiteTimestampFormats...)
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp format: %q", value)
+}
+
+// GetChatParticipantsActivity retrieves per-participant message counts and last activity for a group
+func GetChatParticipantsActivity(chatJID string, dateRange []time.Time, minMessages int) ([]ParticipantActivity, error) {
+	if !strings.HasSuffix(chatJID, "@g.us") {
+		return nil, fmt.Errorf("chat %s is not a group", chatJID)
+	}
+
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	// Known members are listed even without messages, next to senders who have since left
+	rangeFilter := ""
+	var rangeParams []interface{}
+	if len(dateRange) == 2 {
+		rangeFilter = " AND messages.timestamp BETWEEN ? AND ?"
+		rangeParams = []interface{}{dateRange[0], dateRange[1]}
+	}
+
+	queryStr := `
+		SELECT members.jid, COUNT(messages.id), MAX(messages.timestamp)
+		FROM (
+			SELECT participant_jid AS jid FROM group_participants WHERE group_jid = ?
+			UNION
+			SELECT sender FROM messages WHERE chat_jid = ?` + rangeFilter + `
+		) AS members
+		LEFT JOIN messages ON messages.chat_jid = ? AND messages.sender = members.jid` + rangeFilter + `
+		GROUP BY members.jid
+	`
+	params := []interface{}{chatJID, chatJID}
+	params = append(params, rangeParams...)
+	params = append(params, chatJID)
+	params = append(params, rangeParams...)
+
+	rows, err := db.Query(queryStr, params...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	// Senders may be stored with a device suffix, so merge them per user
+	var participants []ParticipantActivity
+	index := map[string]int{}
+
+	for rows.Next() {
+		var sender string
+		var count int
+		var lastActiveStr sql.NullString
+
+		if err := rows.Scan(&sender, &count, &lastActiveStr); err != nil {
+			return nil, fmt.Errorf("error reading data: %v", err)
+		}
+
+		jid := normalizeJID(sender)
+		i, ok := index[jid]
+		if !ok {
+			i = len(participants)
+			index[jid] = i
+			participants = append(participants, ParticipantActivity{JID: jid})
+		}
+
+		participants[i].MessageCount += count
+		if !lastActiveStr.Valid {
+			continue
 		}
 
-		if lastSender.Valid {
-			chat.LastSender = lastSender.String
+		lastActive, err := parseTimestamp(lastActiveStr.String)
+		if err != nil {
+			return nil, fmt.Errorf("error converting timestamp: %v", err)
+		}
+		if participants[i].LastActive == nil || lastActive.After(*participants[i].LastActive) {
+			participants[i].LastActive = &lastActive
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error traversing results: %v", err)
+	}
+
+	var result []ParticipantActivity
+	for _, participant := range participants {
+		if participant.MessageCount < minMessages {
+			continue
 		}
 
-		if lastIsFromMe.Valid {
-			chat.LastIsFromMe = lastIsFromMe.Bool
+		var name sql.NullString
+		err := db.QueryRow("SELECT name FROM chats WHERE jid = ?", participant.JID).Scan(&name)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("error reading data: %v", err)
 		}
+		participant.Name = name.String
+
+		result = append(result, participant)
 	}
 
-	return &chat, nil
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].MessageCount > result[j].MessageCount
+	})
+
+	return result, nil
 }
 
-// GetDirectChatByContact retrieves metadata for a direct chat by phone number
-func GetDirectChatByContact(phoneNumber string) (*Chat, error) {
-	jid := phoneNumber
-	if !strings.Contains(jid, "@") {
-		jid = phoneNumber + "@s.whatsapp.net"
+// normalizeJID strips the device suffix from a JID, e.g. 123:4@s.whatsapp.net becomes 123@s.whatsapp.net
+func normalizeJID(jid string) string {
+	user, server, found := strings.Cut(jid, "@")
+	if !found {
+		return jid
+	}
+	if i := strings.Index(user, ":"); i >= 0 {
+		user = user[:i]
 	}
+	return user + "@" + server
+}
 
-	return GetChat(jid, true)
+// histogramGranularities maps each granularity to the SQL expression bucketing a UTC
+// timestamp and the layout of the resulting bucket key. Weeks start on Monday.
+var histogramGranularities = map[string]struct {
+	expr   string
+	layout string
+}{
+	"hour": {"strftime('%Y-%m-%d %H:00', timestamp)", "2006-01-02 15:04"},
+	"day":  {"date(timestamp)", "2006-01-02"},
+	"week": {"date(timestamp, 'weekday 0', '-6 days')", "2006-01-02"},
 }
 
-// GetContactChats retrieves all chats involving the contact
-func GetContactChats(jid string, limit, page int) ([]Chat, error) {
-	if limit <= 0 {
-		limit = 20
+// GetMessageHistogram retrieves message counts for a chat bucketed by hour, day or week in UTC.
+// Buckets without messages are included so the series is continuous.
+func GetMessageHistogram(chatJID string, dateRange []time.Time, granularity string) ([]HistogramBucket, error) {
+	if granularity == "" {
+		granularity = "day"
 	}
-	if page < 0 {
-		page = 0
+	bucket, ok := histogramGranularities[granularity]
+	if !ok {
+		return nil, fmt.Errorf("invalid granularity %q, expected 'hour', 'day' or 'week'", granularity)
 	}
 
 	db, err := GetDB()
@@ -841,154 +2749,402 @@ func GetContactChats(jid string, limit, page int) ([]Chat, error) {
 	}
 	defer db.Close()
 
-	phoneNumber := jid
-	if strings.Contains(jid, "@") {
-		parts := strings.Split(jid, "@")
-		phoneNumber = parts[0]
+	queryStr := fmt.Sprintf(`
+		SELECT %s AS bucket, COUNT(*)
+		FROM messages
+		WHERE chat_jid = ?
+	`, bucket.expr)
+	params := []interface{}{chatJID}
+
+	if len(dateRange) == 2 {
+		queryStr += " AND timestamp BETWEEN ? AND ?"
+		params = append(params, dateRange[0], dateRange[1])
 	}
 
-	queryStr := `
-		SELECT DISTINCT
-			chats.jid,
-			chats.name,
-			chats.last_message_time,
-			messages.content as last_message,
-			messages.sender as last_sender,
-			messages.is_from_me as last_is_from_me
-		FROM chats
-		LEFT JOIN messages ON chats.jid = messages.chat_jid 
-		AND chats.last_message_time = messages.timestamp
-		JOIN messages as m ON chats.jid = m.chat_jid
-		WHERE 
-			(chats.jid = ? OR m.sender = ? OR m.sender LIKE ?)
-		GROUP BY chats.jid
-		ORDER BY chats.last_message_time DESC
-		LIMIT ? OFFSET ?
-	`
+	queryStr += " GROUP BY bucket ORDER BY bucket"
 
-	offset := page * limit
-	rows, err := db.Query(queryStr, jid, phoneNumber, "%"+phoneNumber+"%", limit, offset)
+	rows, err := db.Query(queryStr, params...)
 	if err != nil {
 		return nil, fmt.Errorf("error executing query: %v", err)
 	}
 	defer rows.Close()
 
-	var chats []Chat
+	counts := map[time.Time]int{}
+	var first, last time.Time
 
 	for rows.Next() {
-		var chat Chat
-		var timestampStr sql.NullString
-		var name, lastMessage, lastSender sql.NullString
-		var lastIsFromMe sql.NullBool
+		var key string
+		var count int
 
-		err := rows.Scan(
-			&chat.JID,
-			&name,
-			&timestampStr,
-			&lastMessage,
-			&lastSender,
-			&lastIsFromMe,
-		)
-		if err != nil {
+		if err := rows.Scan(&key, &count); err != nil {
 			return nil, fmt.Errorf("error reading data: %v", err)
 		}
 
-		if name.Valid {
-			chat.Name = name.String
+		start, err := time.Parse(bucket.layout, key)
+		if err != nil {
+			return nil, fmt.Errorf("error converting timestamp: %v", err)
 		}
 
-		if timestampStr.Valid {
-			chat.LastMessageTime, err = time.Parse(time.RFC3339, timestampStr.String)
-			if err != nil {
-				return nil, fmt.Errorf("error converting timestamp: %v", err)
-			}
+		counts[start] = count
+		if first.IsZero() {
+			first = start
 		}
+		last = start
+	}
 
-		if lastMessage.Valid {
-			chat.LastMessage = lastMessage.String
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error traversing results: %v", err)
+	}
+
+	if len(dateRange) == 2 {
+		first = truncateToBucket(dateRange[0].UTC(), granularity)
+		last = truncateToBucket(dateRange[1].UTC(), granularity)
+	}
+
+	histogram := []HistogramBucket{}
+	if first.IsZero() {
+		return histogram, nil
+	}
+
+	for start := first; !start.After(last); start = nextBucket(start, granularity) {
+		histogram = append(histogram, HistogramBucket{Start: start, Count: counts[start]})
+	}
+
+	return histogram, nil
+}
+
+// minSpikeBaselineDays is the shortest history activity spikes are measured against
+const minSpikeBaselineDays = 7
+
+// ActivitySpike represents a recent day with unusually many messages
+type ActivitySpike struct {
+	Day    time.Time
+	Count  int
+	ZScore float64
+}
+
+// ActivityReport represents the recent days of a chat compared to its daily message history
+type ActivityReport struct {
+	ChatJID        string
+	BaselineDays   int
+	BaselineMean   float64
+	BaselineStdDev float64
+	Spikes         []ActivitySpike
+}
+
+// DetectActivitySpikes flags the days among the last recentDays (UTC) whose message count
+// lies at least sensitivity standard deviations above the chat's earlier daily average
+func DetectActivitySpikes(chatJID string, recentDays int, sensitivity float64) (*ActivityReport, error) {
+	if recentDays <= 0 {
+		recentDays = 7
+	}
+	if sensitivity <= 0 {
+		sensitivity = 3
+	}
+
+	buckets, err := GetMessageHistogram(chatJID, nil, "day")
+	if err != nil {
+		return nil, err
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	return detectActivitySpikes(chatJID, buckets, today, recentDays, sensitivity)
+}
+
+// detectActivitySpikes compares the recentDays up to today with the daily counts before them.
+// The standard deviation is floored at one message so perfectly regular chats do not flag
+// every small change.
+func detectActivitySpikes(chatJID string, buckets []HistogramBucket, today time.Time, recentDays int, sensitivity float64) (*ActivityReport, error) {
+	// Extend the series with the quiet days since the last message
+	var counts []HistogramBucket
+	for _, bucket := range buckets {
+		if bucket.Start.After(today) {
+			break
 		}
+		counts = append(counts, bucket)
+	}
+	if len(counts) == 0 {
+		return nil, fmt.Errorf("no messages found in chat %s", chatJID)
+	}
+	for day := counts[len(counts)-1].Start.AddDate(0, 0, 1); !day.After(today); day = day.AddDate(0, 0, 1) {
+		counts = append(counts, HistogramBucket{Start: day})
+	}
 
-		if lastSender.Valid {
-			chat.LastSender = lastSender.String
+	baseline := counts[:max(len(counts)-recentDays, 0)]
+	recent := counts[len(baseline):]
+	if len(baseline) < minSpikeBaselineDays {
+		return nil, fmt.Errorf("chat %s has %d days of history before the last %d days, at least %d are needed", chatJID, len(baseline), recentDays, minSpikeBaselineDays)
+	}
+
+	var sum float64
+	for _, bucket := range baseline {
+		sum += float64(bucket.Count)
+	}
+	mean := sum / float64(len(baseline))
+
+	var variance float64
+	for _, bucket := range baseline {
+		variance += math.Pow(float64(bucket.Count)-mean, 2)
+	}
+	stdDev := math.Sqrt(variance / float64(len(baseline)))
+
+	report := &ActivityReport{
+		ChatJID:        chatJID,
+		BaselineDays:   len(baseline),
+		BaselineMean:   mean,
+		BaselineStdDev: stdDev,
+		Spikes:         []ActivitySpike{},
+	}
+
+	for _, bucket := range recent {
+		z := (float64(bucket.Count) - mean) / math.Max(stdDev, 1)
+		if z >= sensitivity {
+			report.Spikes = append(report.Spikes, ActivitySpike{Day: bucket.Start, Count: bucket.Count, ZScore: z})
 		}
+	}
 
-		if lastIsFromMe.Valid {
-			chat.LastIsFromMe = lastIsFromMe.Bool
+	return report, nil
+}
+
+// PartyBalance represents the messages and characters sent by one party of a direct chat,
+// with their share of the chat's total as percentages
+type PartyBalance struct {
+	Messages         int
+	Characters       int
+	MessagePercent   float64
+	CharacterPercent float64
+}
+
+// ConversationBalance represents how much each party of a direct chat contributes to it
+type ConversationBalance struct {
+	ChatJID         string
+	TotalMessages   int
+	TotalCharacters int
+	Me              PartyBalance
+	Them            PartyBalance
+}
+
+// GetConversationBalance compares the messages and characters sent by each party of a direct
+// chat, optionally within a date range. A chat without messages has a balance of zero.
+func GetConversationBalance(chatJID string, dateRange []time.Time) (*ConversationBalance, error) {
+	if strings.HasSuffix(chatJID, "@g.us") {
+		return nil, fmt.Errorf("chat %s is a group, not a direct chat", chatJID)
+	}
+
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	queryStr := `
+		SELECT is_from_me, COUNT(*), COALESCE(SUM(LENGTH(content)), 0)
+		FROM messages
+		WHERE chat_jid = ?
+	`
+	params := []interface{}{chatJID}
+
+	if len(dateRange) == 2 {
+		queryStr += " AND timestamp BETWEEN ? AND ?"
+		params = append(params, dateRange[0], dateRange[1])
+	}
+
+	queryStr += " GROUP BY is_from_me"
+
+	rows, err := db.Query(queryStr, params...)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	balance := &ConversationBalance{ChatJID: chatJID}
+	for rows.Next() {
+		var isFromMe bool
+		var party PartyBalance
+		if err := rows.Scan(&isFromMe, &party.Messages, &party.Characters); err != nil {
+			return nil, fmt.Errorf("error reading data: %v", err)
 		}
 
-		chats = append(chats, chat)
+		if isFromMe {
+			balance.Me = party
+		} else {
+			balance.Them = party
+		}
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("error traversing results: %v", err)
 	}
 
-	return chats, nil
+	balance.TotalMessages = balance.Me.Messages + balance.Them.Messages
+	balance.TotalCharacters = balance.Me.Characters + balance.Them.Characters
+	for _, party := range []*PartyBalance{&balance.Me, &balance.Them} {
+		party.MessagePercent = percentage(party.Messages, balance.TotalMessages)
+		party.CharacterPercent = percentage(party.Characters, balance.TotalCharacters)
+	}
+
+	return balance, nil
 }
 
-// GetLastInteraction retrieves the most recent message involving the contact
-func GetLastInteraction(jid string) (*Message, error) {
+// percentage returns part as a percentage of total rounded to one decimal, or zero if total is zero
+func percentage(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(part)*1000/float64(total)) / 10
+}
+
+// UnansweredQuestion represents a question from another participant that I did not reply to in time
+type UnansweredQuestion struct {
+	Message Message
+	// RepliedAt is when I next wrote in the chat, after the window; nil if I have not since
+	RepliedAt *time.Time
+}
+
+// questionMarks lists the characters that mark a question, including the full width form
+const questionMarks = "?？"
+
+// isQuestion reports whether content reads as a question. The "trailing" heuristic requires
+// a question mark at the end, ignoring trailing spaces, exclamation marks and closing punctuation, while
+// "contains" accepts one anywhere in the message.
+func isQuestion(content, heuristic string) bool {
+	if heuristic == "contains" {
+		return strings.ContainsAny(content, questionMarks)
+	}
+
+	trimmed := strings.TrimRight(content, " \t\r\n!)\"'»”’")
+	last, _ := utf8.DecodeLastRuneInString(trimmed)
+	return strings.ContainsRune(questionMarks, last)
+}
+
+// FindUnansweredQuestions retrieves the questions others asked in a chat that I did not reply
+// to within window, oldest first, keeping the most recent limit. Any message of mine counts
+// as a reply. Questions asked less than window ago without a reply are included too.
+func FindUnansweredQuestions(chatJID string, window time.Duration, heuristic string, dateRange []time.Time, limit int) ([]UnansweredQuestion, error) {
+	switch heuristic {
+	case "":
+		heuristic = "trailing"
+	case "trailing", "contains":
+	default:
+		return nil, fmt.Errorf("invalid heuristic %q, must be 'trailing' or 'contains'", heuristic)
+	}
+	if window <= 0 {
+		window = 24 * time.Hour
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+
 	db, err := GetDB()
 	if err != nil {
 		return nil, err
 	}
 	defer db.Close()
 
-	phoneNumber := jid
-	if strings.Contains(jid, "@") {
-		parts := strings.Split(jid, "@")
-		phoneNumber = parts[0]
-	}
-
 	queryStr := `
-		SELECT 
-			messages.timestamp,
-			messages.sender,
-			chats.name,
-			messages.content,
-			messages.is_from_me,
-			chats.jid,
-			messages.id
+		SELECT messages.timestamp, messages.sender, chats.name, messages.content, messages.is_from_me, messages.chat_jid, messages.id
 		FROM messages
 		JOIN chats ON messages.chat_jid = chats.jid
-		WHERE 
-			(messages.chat_jid = ? OR messages.sender = ? OR messages.sender LIKE ?)
-		ORDER BY messages.timestamp DESC
-		LIMIT 1
+		WHERE messages.chat_jid = ?
 	`
+	params := []interface{}{chatJID}
 
-	row := db.QueryRow(queryStr, jid, phoneNumber, "%"+phoneNumber+"%")
+	if len(dateRange) == 2 {
+		queryStr += " AND julianday(messages.timestamp) BETWEEN julianday(?) AND julianday(?)"
+		params = append(params, dateRange[0].Format(time.RFC3339), dateRange[1].Format(time.RFC3339))
+	}
 
-	var msg Message
-	var timestampStr string
-	var chatName sql.NullString
+	queryStr += " ORDER BY julianday(messages.timestamp)"
 
-	err = row.Scan(
-		&timestampStr,
-		&msg.Sender,
-		&chatName,
-		&msg.Content,
-		&msg.IsFromMe,
-		&msg.ChatJID,
-		&msg.ID,
-	)
+	rows, err := db.Query(queryStr, params...)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("no message found for contact with JID %s", jid)
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var msg Message
+		var timestampStr string
+		var chatName sql.NullString
+
+		if err := rows.Scan(&timestampStr, &msg.Sender, &chatName, &msg.Content, &msg.IsFromMe, &msg.ChatJID, &msg.ID); err != nil {
+			return nil, fmt.Errorf("error reading data: %v", err)
 		}
-		return nil, fmt.Errorf("error reading data: %v", err)
+
+		msg.Timestamp, err = parseTimestamp(timestampStr)
+		if err != nil {
+			return nil, fmt.Errorf("error converting timestamp: %v", err)
+		}
+		msg.ChatName = chatName.String
+
+		messages = append(messages, msg)
 	}
 
-	msg.Timestamp, err = time.Parse(time.RFC3339, timestampStr)
-	if err != nil {
-		return nil, fmt.Errorf("error converting timestamp: %v", err)
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error traversing results: %v", err)
 	}
 
-	if chatName.Valid {
-		msg.ChatName = chatName.String
-	} else {
-		msg.ChatName = "Unknown Chat"
+	questions := unansweredQuestions(messages, window, heuristic)
+	if len(questions) > limit {
+		questions = questions[len(questions)-limit:]
 	}
 
-	return &msg, nil
+	return questions, nil
+}
+
+// unansweredQuestions walks messages in chronological order, keeping the questions from others
+// that are still waiting for my next message. When it arrives, the questions asked within
+// window before it are answered and the older ones were left unanswered.
+func unansweredQuestions(messages []Message, window time.Duration, heuristic string) []UnansweredQuestion {
+	questions := []UnansweredQuestion{}
+	var waiting []Message
+
+	for _, msg := range messages {
+		if !msg.IsFromMe {
+			if isQuestion(msg.Content, heuristic) {
+				waiting = append(waiting, msg)
+			}
+			continue
+		}
+
+		repliedAt := msg.Timestamp
+		for _, question := range waiting {
+			if repliedAt.Sub(question.Timestamp) > window {
+				questions = append(questions, UnansweredQuestion{Message: question, RepliedAt: &repliedAt})
+			}
+		}
+		waiting = nil
+	}
+
+	for _, question := range waiting {
+		questions = append(questions, UnansweredQuestion{Message: question})
+	}
+
+	return questions
+}
+
+// truncateToBucket returns the start of the bucket containing t
+func truncateToBucket(t time.Time, granularity string) time.Time {
+	switch granularity {
+	case "hour":
+		return t.Truncate(time.Hour)
+	case "week":
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// nextBucket returns the start of the bucket following the one starting at t
+func nextBucket(t time.Time, granularity string) time.Time {
+	switch granularity {
+	case "hour":
+		return t.Add(time.Hour)
+	case "week":
+		return t.AddDate(0, 0, 7)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
 }
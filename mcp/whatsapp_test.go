@@ -0,0 +1,1546 @@
+package mcp
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	whatsappdb "github.com/mbenaiss/whatsapp-mcp/db"
+	"github.com/mbenaiss/whatsapp-mcp/models"
+)
+
+const (
+	testGroupJID = "120363000000000000@g.us"
+	testAliceJID = "15550002222@s.whatsapp.net"
+	testBobJID   = "15550003333@s.whatsapp.net"
+	testCarolJID = "15550004444@s.whatsapp.net"
+)
+
+// testEpoch is the timestamp test messages are offset from
+var testEpoch = time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+
+// newTestDB creates a message database in a temporary directory and points MessagesDBPath at it
+func newTestDB(t testing.TB) whatsappdb.DB {
+	t.Helper()
+
+	dir := t.TempDir()
+	d, err := whatsappdb.NewDB(context.Background(), dir, whatsappdb.Options{})
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	previous := MessagesDBPath
+	MessagesDBPath = filepath.Join(dir, "messages.db")
+	t.Cleanup(func() { MessagesDBPath = previous })
+
+	return d
+}
+
+// testMessage returns a received text message sent at testEpoch plus offset
+func testMessage(chatJID string, id string, sender string, content string, offset time.Duration) models.Message {
+	return models.Message{
+		ID:        id,
+		ChatJID:   chatJID,
+		Sender:    sender,
+		Content:   content,
+		Timestamp: testEpoch.Add(offset),
+		IsFromMe:  sender == "",
+		Type:      "text",
+	}
+}
+
+// storeMessages stores the messages along with their chats
+func storeMessages(t testing.TB, d whatsappdb.DB, messages ...models.Message) {
+	t.Helper()
+
+	chats := make([]models.Chat, 0, len(messages))
+	for _, msg := range messages {
+		chats = append(chats, models.Chat{
+			JID:             msg.ChatJID,
+			LastMessageTime: msg.Timestamp,
+			Messages:        []models.Message{msg},
+		})
+	}
+	if err := d.StoreBatch(context.Background(), chats); err != nil {
+		t.Fatalf("StoreBatch: %v", err)
+	}
+}
+
+func TestGetChatParticipantsActivity(t *testing.T) {
+	d := newTestDB(t)
+	ctx := context.Background()
+
+	storeMessages(t, d,
+		testMessage(testGroupJID, "M1", testAliceJID, "hi", 0),
+		testMessage(testGroupJID, "M2", "15550002222:3@s.whatsapp.net", "from my laptop", time.Hour),
+		testMessage(testGroupJID, "M3", testBobJID, "hello", 2*time.Hour),
+		testMessage(testGroupJID, "M4", "15550005555@s.whatsapp.net", "bye", 3*time.Hour),
+	)
+	if err := d.SetGroupParticipants(ctx, testGroupJID, []string{testAliceJID, testBobJID, testCarolJID}); err != nil {
+		t.Fatalf("SetGroupParticipants: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		dateRange   []time.Time
+		minMessages int
+		want        map[string]int
+	}{
+		{
+			name: "silent members and former senders",
+			want: map[string]int{testAliceJID: 2, testBobJID: 1, testCarolJID: 0, "15550005555@s.whatsapp.net": 1},
+		},
+		{
+			name:        "min messages drops silent members",
+			minMessages: 1,
+			want:        map[string]int{testAliceJID: 2, testBobJID: 1, "15550005555@s.whatsapp.net": 1},
+		},
+		{
+			name:      "date range counts members outside it as silent",
+			dateRange: []time.Time{testEpoch.Add(90 * time.Minute), testEpoch.Add(150 * time.Minute)},
+			want:      map[string]int{testAliceJID: 0, testBobJID: 1, testCarolJID: 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			participants, err := GetChatParticipantsActivity(testGroupJID, tt.dateRange, tt.minMessages)
+			if err != nil {
+				t.Fatalf("GetChatParticipantsActivity: %v", err)
+			}
+
+			got := map[string]int{}
+			for _, p := range participants {
+				got[p.JID] = p.MessageCount
+				if (p.MessageCount == 0) != (p.LastActive == nil) {
+					t.Errorf("%s: %d messages with last active %v", p.JID, p.MessageCount, p.LastActive)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("participants = %v, want %v", got, tt.want)
+			}
+			for jid, count := range tt.want {
+				if c, ok := got[jid]; !ok || c != count {
+					t.Errorf("%s: %d messages (listed %v), want %d", jid, c, ok, count)
+				}
+			}
+		})
+	}
+}
+
+func TestScrollMessages(t *testing.T) {
+	d := newTestDB(t)
+
+	// M2 to M4 share a timestamp, and the ID M3 is reused in another chat
+	storeMessages(t, d,
+		testMessage(testAliceJID, "M1", testAliceJID, "one", 0),
+		testMessage(testAliceJID, "M2", testAliceJID, "two", time.Minute),
+		testMessage(testAliceJID, "M3", testAliceJID, "three", time.Minute),
+		testMessage(testAliceJID, "M4", testAliceJID, "four", time.Minute),
+		testMessage(testAliceJID, "M5", testAliceJID, "five", 2*time.Minute),
+		testMessage(testBobJID, "M3", testBobJID, "elsewhere", time.Hour),
+	)
+
+	tests := []struct {
+		name      string
+		chatJID   string
+		messageID string
+		direction string
+		count     int
+		want      [][]string
+		wantErr   bool
+	}{
+		{
+			name:      "after through equal timestamps",
+			chatJID:   testAliceJID,
+			messageID: "M1",
+			direction: "after",
+			count:     2,
+			want:      [][]string{{"M2", "M3"}, {"M4", "M5"}},
+		},
+		{
+			name:      "before through equal timestamps",
+			chatJID:   testAliceJID,
+			messageID: "M5",
+			direction: "before",
+			count:     2,
+			want:      [][]string{{"M3", "M4"}, {"M1", "M2"}},
+		},
+		{
+			name:      "anchor inside equal timestamps",
+			chatJID:   testAliceJID,
+			messageID: "M3",
+			direction: "after",
+			count:     10,
+			want:      [][]string{{"M4", "M5"}},
+		},
+		{
+			name:      "anchor ID reused in another chat",
+			chatJID:   testBobJID,
+			messageID: "M3",
+			direction: "before",
+			count:     10,
+			want:      [][]string{{}},
+		},
+		{
+			name:      "anchor not in chat",
+			chatJID:   testBobJID,
+			messageID: "M1",
+			direction: "after",
+			wantErr:   true,
+		},
+		{
+			name:      "invalid direction",
+			chatJID:   testAliceJID,
+			messageID: "M1",
+			direction: "sideways",
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			anchor := tt.messageID
+			for i, want := range tt.want {
+				page, err := ScrollMessages(tt.chatJID, anchor, tt.direction, tt.count)
+				if err != nil {
+					t.Fatalf("page %d: ScrollMessages: %v", i, err)
+				}
+
+				got := make([]string, 0, len(page.Messages))
+				for _, msg := range page.Messages {
+					got = append(got, msg.ID)
+				}
+				if !slices.Equal(got, want) {
+					t.Fatalf("page %d = %v, want %v", i, got, want)
+				}
+				if wantMore := i < len(tt.want)-1; page.HasMore != wantMore {
+					t.Errorf("page %d: has more = %v, want %v", i, page.HasMore, wantMore)
+				}
+
+				anchor = page.NewestID
+				if tt.direction == "before" {
+					anchor = page.OldestID
+				}
+			}
+
+			if tt.wantErr {
+				if _, err := ScrollMessages(tt.chatJID, tt.messageID, tt.direction, tt.count); err == nil {
+					t.Fatal("ScrollMessages succeeded, want an error")
+				}
+			}
+		})
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		wantErr bool
+	}{
+		{name: "empty database", version: 0, wantErr: true},
+		{name: "stale", version: whatsappdb.SchemaVersion() - 1, wantErr: true},
+		{name: "current", version: whatsappdb.SchemaVersion()},
+		{name: "newer bridge", version: whatsappdb.SchemaVersion() + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schemaChecked.Store(false)
+			t.Cleanup(func() { schemaChecked.Store(false) })
+
+			db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "messages.db"))
+			if err != nil {
+				t.Fatalf("sql.Open: %v", err)
+			}
+			defer db.Close()
+
+			if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", tt.version)); err != nil {
+				t.Fatalf("set user_version: %v", err)
+			}
+
+			err = checkSchema(db)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("checkSchema succeeded, want an error")
+				}
+				if schemaChecked.Load() {
+					t.Error("stale schema was recorded as checked")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkSchema: %v", err)
+			}
+		})
+	}
+}
+
+func TestWantsContext(t *testing.T) {
+	tests := []struct {
+		includeContext bool
+		before, after  int
+		want           bool
+	}{
+		{includeContext: false, before: 1, after: 1},
+		{includeContext: true, before: 0, after: 0},
+		{includeContext: true, before: 1, after: 0, want: true},
+		{includeContext: true, before: 0, after: 1, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := wantsContext(tt.includeContext, tt.before, tt.after); got != tt.want {
+			t.Errorf("wantsContext(%v, %d, %d) = %v, want %v", tt.includeContext, tt.before, tt.after, got, tt.want)
+		}
+	}
+}
+
+func BenchmarkListMessages(b *testing.B) {
+	d := newTestDB(b)
+
+	messages := make([]models.Message, 0, 1000)
+	for i := range 1000 {
+		messages = append(messages, testMessage(testAliceJID, fmt.Sprintf("M%04d", i), testAliceJID, fmt.Sprintf("message %d", i), time.Duration(i)*time.Minute))
+	}
+	storeMessages(b, d, messages...)
+
+	modes := []struct {
+		name           string
+		includeContext bool
+	}{
+		{name: "without context"},
+		{name: "with context", includeContext: true},
+	}
+
+	for _, mode := range modes {
+		b.Run(mode.name, func(b *testing.B) {
+			for range b.N {
+				if _, _, err := ListMessages(nil, "", testAliceJID, "", "", "", 20, 0, mode.includeContext, 1, 1, defaultMaxContext); err != nil {
+					b.Fatalf("ListMessages: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestListMessagesMatchMode(t *testing.T) {
+	d := newTestDB(t)
+
+	storeMessages(t, d,
+		testMessage(testAliceJID, "M1", testAliceJID, "Lunch at noon tomorrow?", 0),
+		testMessage(testAliceJID, "M2", testAliceJID, "noon works, see you at lunch", time.Minute),
+		testMessage(testAliceJID, "M3", testAliceJID, "Dinner instead", 2*time.Minute),
+		testMessage(testAliceJID, "M4", testAliceJID, "100% sure", 3*time.Minute),
+		testMessage(testAliceJID, "M5", testAliceJID, "1000 sure", 4*time.Minute),
+		testMessage(testAliceJID, "M6", testAliceJID, "file_name.txt", 5*time.Minute),
+		testMessage(testAliceJID, "M7", testAliceJID, "filexname.txt", 6*time.Minute),
+	)
+
+	tests := []struct {
+		name      string
+		query     string
+		matchMode string
+		want      []string
+		wantErr   bool
+	}{
+		{name: "default is phrase", query: "lunch at", want: []string{"M1"}},
+		{name: "phrase", query: "at noon", matchMode: "phrase", want: []string{"M1"}},
+		{name: "phrase is case insensitive", query: "DINNER", matchMode: "phrase", want: []string{"M3"}},
+		{name: "any word", query: "dinner tomorrow", matchMode: "any", want: []string{"M1", "M3"}},
+		{name: "all words in any order", query: "noon lunch", matchMode: "all", want: []string{"M1", "M2"}},
+		{name: "all words, one missing", query: "noon dinner", matchMode: "all", want: []string{}},
+		{name: "percent is literal", query: "100%", matchMode: "phrase", want: []string{"M4"}},
+		{name: "underscore is literal", query: "file_name", matchMode: "any", want: []string{"M6"}},
+		{name: "blank query in word modes matches it as written", query: " ", matchMode: "all", want: []string{"M1", "M2", "M3", "M4", "M5"}},
+		{name: "invalid mode", query: "lunch", matchMode: "fuzzy", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, _, err := ListMessages(nil, "", testAliceJID, tt.query, tt.matchMode, "", 20, 0, false, 0, 0, 0)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("ListMessages succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ListMessages: %v", err)
+			}
+
+			got := make([]string, 0, len(messages))
+			for _, msg := range messages {
+				got = append(got, msg.ID)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("matched %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetMessageHistogram(t *testing.T) {
+	d := newTestDB(t)
+
+	paris := time.FixedZone("CET", 2*60*60)
+	late := testMessage(testAliceJID, "M4", testAliceJID, "late", 0)
+	// 01:00 at UTC+2 on March 13 is 23:00 UTC on March 12
+	late.Timestamp = time.Date(2025, 3, 13, 1, 0, 0, 0, paris)
+
+	storeMessages(t, d,
+		testMessage(testAliceJID, "M1", testAliceJID, "morning", 0),
+		testMessage(testAliceJID, "M2", testAliceJID, "still morning", 30*time.Minute),
+		testMessage(testAliceJID, "M3", testAliceJID, "later", 2*time.Hour+15*time.Minute),
+		late,
+	)
+
+	day := func(d int) time.Time { return time.Date(2025, 3, d, 0, 0, 0, 0, time.UTC) }
+	hour := func(h int) time.Time { return time.Date(2025, 3, 10, h, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name        string
+		granularity string
+		dateRange   []time.Time
+		want        []HistogramBucket
+		wantErr     bool
+	}{
+		{
+			name:        "daily with an empty day in between",
+			granularity: "day",
+			want: []HistogramBucket{
+				{Start: day(10), Count: 3},
+				{Start: day(11), Count: 0},
+				{Start: day(12), Count: 1},
+			},
+		},
+		{
+			name: "daily by default",
+			want: []HistogramBucket{
+				{Start: day(10), Count: 3},
+				{Start: day(11), Count: 0},
+				{Start: day(12), Count: 1},
+			},
+		},
+		{
+			name:        "hourly",
+			granularity: "hour",
+			dateRange:   []time.Time{hour(8), hour(12)},
+			want: []HistogramBucket{
+				{Start: hour(8), Count: 0},
+				{Start: hour(9), Count: 2},
+				{Start: hour(10), Count: 0},
+				{Start: hour(11), Count: 1},
+				{Start: hour(12), Count: 0},
+			},
+		},
+		{
+			name:        "daily range padded with empty days",
+			granularity: "day",
+			dateRange:   []time.Time{day(9), day(14)},
+			want: []HistogramBucket{
+				{Start: day(9), Count: 0},
+				{Start: day(10), Count: 3},
+				{Start: day(11), Count: 0},
+				{Start: day(12), Count: 1},
+				{Start: day(13), Count: 0},
+				{Start: day(14), Count: 0},
+			},
+		},
+		{
+			name:        "weekly",
+			granularity: "week",
+			want:        []HistogramBucket{{Start: day(10), Count: 4}},
+		},
+		{
+			name:        "invalid granularity",
+			granularity: "minute",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			histogram, err := GetMessageHistogram(testAliceJID, tt.dateRange, tt.granularity)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("GetMessageHistogram succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetMessageHistogram: %v", err)
+			}
+
+			if len(histogram) != len(tt.want) {
+				t.Fatalf("histogram = %v, want %v", histogram, tt.want)
+			}
+			for i, bucket := range histogram {
+				if !bucket.Start.Equal(tt.want[i].Start) || bucket.Count != tt.want[i].Count {
+					t.Errorf("bucket %d = %v %d, want %v %d", i, bucket.Start, bucket.Count, tt.want[i].Start, tt.want[i].Count)
+				}
+			}
+		})
+	}
+}
+
+func TestListChatsModifiedSince(t *testing.T) {
+	d := newTestDB(t)
+
+	// Three chats share the epoch timestamp, so pages of two end between them
+	storeMessages(t, d,
+		testMessage(testCarolJID, "M1", testCarolJID, "one", 0),
+		testMessage(testAliceJID, "M2", testAliceJID, "two", 0),
+		testMessage(testBobJID, "M3", testBobJID, "three", 0),
+		testMessage(testGroupJID, "M4", testAliceJID, "four", time.Minute),
+		testMessage("15550001111@s.whatsapp.net", "M5", "", "earlier", -time.Hour),
+	)
+
+	tests := []struct {
+		name  string
+		since time.Time
+		limit int
+		want  [][]string
+	}{
+		{
+			name:  "pages split chats sharing a timestamp",
+			since: testEpoch.Add(-time.Minute),
+			limit: 2,
+			want:  [][]string{{testAliceJID, testBobJID}, {testCarolJID, testGroupJID}, {}},
+		},
+		{
+			name:  "page of one",
+			since: testEpoch.Add(-time.Minute),
+			limit: 1,
+			want:  [][]string{{testAliceJID}, {testBobJID}, {testCarolJID}, {testGroupJID}, {}},
+		},
+		{
+			name:  "since excludes chats at that time",
+			since: testEpoch,
+			limit: 10,
+			want:  [][]string{{testGroupJID}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			since, sinceJID := tt.since, ""
+			for i, want := range tt.want {
+				sync, err := ListChatsModifiedSince(since, sinceJID, tt.limit)
+				if err != nil {
+					t.Fatalf("sync %d: ListChatsModifiedSince: %v", i, err)
+				}
+
+				got := make([]string, 0, len(sync.Chats))
+				for _, chat := range sync.Chats {
+					got = append(got, chat.JID)
+				}
+				if !slices.Equal(got, want) {
+					t.Fatalf("sync %d = %v, want %v", i, got, want)
+				}
+
+				since, sinceJID = sync.NextSyncTimestamp, sync.NextSyncJID
+			}
+		})
+	}
+}
+
+func TestListChatsChatType(t *testing.T) {
+	d := newTestDB(t)
+
+	storeMessages(t, d,
+		testMessage(testAliceJID, "M1", testAliceJID, "hi", 0),
+		testMessage(testBobJID, "M2", testBobJID, "hello", time.Minute),
+		testMessage(testGroupJID, "M3", testAliceJID, "team", 2*time.Minute),
+		testMessage("status@broadcast", "M4", testCarolJID, "my status", 3*time.Minute),
+	)
+
+	counts, err := d.CountChatsAndMessages(context.Background())
+	if err != nil {
+		t.Fatalf("CountChatsAndMessages: %v", err)
+	}
+
+	tests := []struct {
+		chatType  string
+		want      []string
+		wantCount int
+		wantErr   bool
+	}{
+		{chatType: "direct", want: []string{testBobJID, testAliceJID}, wantCount: counts.DirectChats},
+		{chatType: "group", want: []string{testGroupJID}, wantCount: counts.GroupChats},
+		{chatType: "all", want: []string{"status@broadcast", testGroupJID, testBobJID, testAliceJID}, wantCount: 4},
+		{chatType: "", want: []string{"status@broadcast", testGroupJID, testBobJID, testAliceJID}, wantCount: 4},
+		{chatType: "channel", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.chatType, func(t *testing.T) {
+			chats, err := ListChats("", 20, 0, true, "last_active", tt.chatType)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ListChats succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ListChats: %v", err)
+			}
+
+			got := make([]string, 0, len(chats))
+			for _, chat := range chats {
+				got = append(got, chat.JID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("chats = %v, want %v", got, tt.want)
+			}
+			// get_counts must agree with what list_chats returns
+			if len(got) != tt.wantCount {
+				t.Errorf("listed %d chats, get_counts reports %d", len(got), tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestListMessagesMulti(t *testing.T) {
+	d := newTestDB(t)
+
+	var messages []models.Message
+	for i := range 5 {
+		messages = append(messages, testMessage(testAliceJID, fmt.Sprintf("A%d", i), testAliceJID, "from alice", time.Duration(i)*time.Minute))
+	}
+	for i := range 2 {
+		messages = append(messages, testMessage(testBobJID, fmt.Sprintf("B%d", i), testBobJID, "from bob", time.Duration(i)*time.Minute))
+	}
+	storeMessages(t, d, messages...)
+
+	tests := []struct {
+		name     string
+		chatJIDs []string
+		perChat  int
+		want     map[string][]string
+		order    []string
+		wantErr  bool
+	}{
+		{
+			name:     "per chat cap keeps the newest",
+			chatJIDs: []string{testAliceJID, testBobJID},
+			perChat:  3,
+			want:     map[string][]string{testAliceJID: {"A4", "A3", "A2"}, testBobJID: {"B1", "B0"}},
+			order:    []string{testAliceJID, testBobJID},
+		},
+		{
+			name:     "default cap",
+			chatJIDs: []string{testBobJID, testAliceJID},
+			want:     map[string][]string{testAliceJID: {"A4", "A3", "A2", "A1", "A0"}, testBobJID: {"B1", "B0"}},
+			order:    []string{testBobJID, testAliceJID},
+		},
+		{
+			name:     "chat without messages and duplicates",
+			chatJIDs: []string{testCarolJID, testBobJID, testCarolJID},
+			perChat:  1,
+			want:     map[string][]string{testCarolJID: {}, testBobJID: {"B1"}},
+			order:    []string{testCarolJID, testBobJID},
+		},
+		{
+			name:     "per chat at the maximum",
+			chatJIDs: []string{testBobJID},
+			perChat:  maxMultiPerChat,
+			want:     map[string][]string{testBobJID: {"B1", "B0"}},
+			order:    []string{testBobJID},
+		},
+		{
+			name:     "per chat over the maximum",
+			chatJIDs: []string{testBobJID},
+			perChat:  maxMultiPerChat + 1,
+			wantErr:  true,
+		},
+		{
+			name:     "too many chats",
+			chatJIDs: make([]string, maxMultiChats+1),
+			wantErr:  true,
+		},
+		{
+			name:    "no chats",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, err := ListMessagesMulti(tt.chatJIDs, tt.perChat)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("ListMessagesMulti succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ListMessagesMulti: %v", err)
+			}
+
+			var order []string
+			for _, group := range groups {
+				order = append(order, group.ChatJID)
+
+				ids := []string{}
+				for _, msg := range group.Messages {
+					ids = append(ids, msg.ID)
+				}
+				if want := tt.want[group.ChatJID]; !slices.Equal(ids, want) {
+					t.Errorf("%s: messages = %v, want %v", group.ChatJID, ids, want)
+				}
+			}
+			if !slices.Equal(order, tt.order) {
+				t.Errorf("chats = %v, want %v", order, tt.order)
+			}
+		})
+	}
+}
+
+func TestIsQuestion(t *testing.T) {
+	tests := []struct {
+		content   string
+		heuristic string
+		want      bool
+	}{
+		{content: "Are you coming?", heuristic: "trailing", want: true},
+		{content: "Are you coming ?  ", heuristic: "trailing", want: true},
+		{content: "Are you coming?!", heuristic: "trailing", want: true},
+		{content: "(are you coming?)", heuristic: "trailing", want: true},
+		{content: "“Are you coming?”", heuristic: "trailing", want: true},
+		{content: "来吗？", heuristic: "trailing", want: true},
+		{content: "What? No way.", heuristic: "trailing"},
+		{content: "What? No way.", heuristic: "contains", want: true},
+		{content: "See you tomorrow", heuristic: "contains"},
+		{content: "", heuristic: "trailing"},
+	}
+
+	for _, tt := range tests {
+		if got := isQuestion(tt.content, tt.heuristic); got != tt.want {
+			t.Errorf("isQuestion(%q, %s) = %v, want %v", tt.content, tt.heuristic, got, tt.want)
+		}
+	}
+}
+
+func TestUnansweredQuestions(t *testing.T) {
+	msg := func(id string, fromMe bool, content string, offset time.Duration) Message {
+		return Message{ID: id, IsFromMe: fromMe, Content: content, Timestamp: testEpoch.Add(offset)}
+	}
+
+	tests := []struct {
+		name     string
+		messages []Message
+		want     []string
+		// wantReplied lists the questions expected to carry a late reply time
+		wantReplied []string
+	}{
+		{
+			name: "answered within the window",
+			messages: []Message{
+				msg("Q1", false, "Lunch?", 0),
+				msg("R1", true, "Sure", time.Hour),
+			},
+			want: []string{},
+		},
+		{
+			name: "answered after the window",
+			messages: []Message{
+				msg("Q1", false, "Lunch?", 0),
+				msg("R1", true, "Sorry, just saw this", 30*time.Hour),
+			},
+			want:        []string{"Q1"},
+			wantReplied: []string{"Q1"},
+		},
+		{
+			name: "never answered",
+			messages: []Message{
+				msg("Q1", false, "Lunch?", 0),
+				msg("M1", false, "Hello?", time.Minute),
+			},
+			want: []string{"Q1", "M1"},
+		},
+		{
+			name: "one reply answers every recent question",
+			messages: []Message{
+				msg("Q1", false, "Lunch?", 0),
+				msg("Q2", false, "Where?", time.Minute),
+				msg("R1", true, "Noon, at Marco's", 2*time.Hour),
+				msg("Q3", false, "Can I bring Sam?", 3*time.Hour),
+			},
+			want: []string{"Q3"},
+		},
+		{
+			name: "reply only answers questions before it",
+			messages: []Message{
+				msg("Q1", false, "Lunch?", 0),
+				msg("R1", true, "Yes", 26*time.Hour),
+				msg("Q2", false, "Noon?", 27*time.Hour),
+				msg("R2", true, "Yes", 28*time.Hour),
+			},
+			want:        []string{"Q1"},
+			wantReplied: []string{"Q1"},
+		},
+		{
+			name: "my own questions are not waiting",
+			messages: []Message{
+				msg("Q1", true, "Lunch?", 0),
+				msg("M1", false, "Yes", time.Hour),
+			},
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			questions := unansweredQuestions(tt.messages, 24*time.Hour, "trailing")
+
+			got := []string{}
+			var replied []string
+			for _, q := range questions {
+				got = append(got, q.Message.ID)
+				if q.RepliedAt != nil {
+					replied = append(replied, q.Message.ID)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("unanswered = %v, want %v", got, tt.want)
+			}
+			if !slices.Equal(replied, tt.wantReplied) {
+				t.Errorf("replied late = %v, want %v", replied, tt.wantReplied)
+			}
+		})
+	}
+}
+
+func TestFindUnansweredQuestions(t *testing.T) {
+	d := newTestDB(t)
+
+	storeMessages(t, d,
+		testMessage(testAliceJID, "Q1", testAliceJID, "Lunch?", 0),
+		testMessage(testAliceJID, "R1", "", "Sure", time.Hour),
+		testMessage(testAliceJID, "Q2", testAliceJID, "Did you get my email?", 2*time.Hour),
+		testMessage(testAliceJID, "Q3", testAliceJID, "Are you there? Call me", 3*time.Hour),
+		testMessage(testAliceJID, "Q4", testAliceJID, "Hello?", 4*time.Hour),
+	)
+
+	tests := []struct {
+		name      string
+		heuristic string
+		limit     int
+		want      []string
+		wantErr   bool
+	}{
+		{name: "trailing by default", want: []string{"Q2", "Q4"}},
+		{name: "contains", heuristic: "contains", want: []string{"Q2", "Q3", "Q4"}},
+		{name: "limit keeps the most recent", heuristic: "contains", limit: 2, want: []string{"Q3", "Q4"}},
+		{name: "invalid heuristic", heuristic: "fuzzy", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			questions, err := FindUnansweredQuestions(testAliceJID, 24*time.Hour, tt.heuristic, nil, tt.limit)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("FindUnansweredQuestions succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FindUnansweredQuestions: %v", err)
+			}
+
+			got := []string{}
+			for _, q := range questions {
+				got = append(got, q.Message.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("unanswered = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWasMessageRead(t *testing.T) {
+	d := newTestDB(t)
+	ctx := context.Background()
+
+	storeMessages(t, d,
+		testMessage(testAliceJID, "SENT", "", "no receipts yet", 0),
+		testMessage(testAliceJID, "DELIVERED", "", "on the phone", time.Minute),
+		testMessage(testAliceJID, "READ", "", "seen", 2*time.Minute),
+		testMessage(testGroupJID, "GROUP", "", "hello team", 3*time.Minute),
+		// Same ID in another chat, whose receipts must not leak into the direct chat
+		testMessage(testBobJID, "SENT", "", "colliding id", 4*time.Minute),
+	)
+
+	receipts := []models.Receipt{
+		{MessageID: "DELIVERED", ChatJID: testAliceJID, Participant: testAliceJID, Timestamp: testEpoch.Add(time.Hour)},
+		{MessageID: "READ", ChatJID: testAliceJID, Participant: testAliceJID, Timestamp: testEpoch.Add(time.Hour)},
+		{MessageID: "READ", ChatJID: testAliceJID, Participant: testAliceJID, Read: true, Timestamp: testEpoch.Add(2 * time.Hour)},
+		{MessageID: "GROUP", ChatJID: testGroupJID, Participant: testAliceJID, Timestamp: testEpoch.Add(time.Hour)},
+		{MessageID: "GROUP", ChatJID: testGroupJID, Participant: testAliceJID, Read: true, Timestamp: testEpoch.Add(3 * time.Hour)},
+		{MessageID: "GROUP", ChatJID: testGroupJID, Participant: testBobJID, Timestamp: testEpoch.Add(2 * time.Hour)},
+		{MessageID: "SENT", ChatJID: testBobJID, Participant: testBobJID, Read: true, Timestamp: testEpoch.Add(time.Hour)},
+	}
+	for _, receipt := range receipts {
+		if err := d.StoreReceipt(ctx, receipt); err != nil {
+			t.Fatalf("StoreReceipt: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name             string
+		chatJID          string
+		messageID        string
+		want             string
+		wantParticipants map[string]string
+	}{
+		{name: "sent", chatJID: testAliceJID, messageID: "SENT", want: "sent"},
+		{name: "delivered", chatJID: testAliceJID, messageID: "DELIVERED", want: "delivered"},
+		{name: "read", chatJID: testAliceJID, messageID: "READ", want: "read"},
+		{name: "colliding id", chatJID: testBobJID, messageID: "SENT", want: "read"},
+		{
+			name:             "per participant",
+			chatJID:          testGroupJID,
+			messageID:        "GROUP",
+			want:             "read",
+			wantParticipants: map[string]string{testAliceJID: "read", testBobJID: "delivered"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := WasMessageRead(tt.chatJID, tt.messageID)
+			if err != nil {
+				t.Fatalf("WasMessageRead: %v", err)
+			}
+			if status.ChatJID != tt.chatJID {
+				t.Errorf("chat = %s, want %s", status.ChatJID, tt.chatJID)
+			}
+			if status.Status != tt.want {
+				t.Errorf("status = %s, want %s", status.Status, tt.want)
+			}
+			if status.SentAt == nil {
+				t.Errorf("sent time missing")
+			}
+
+			participants := map[string]string{}
+			for _, participant := range status.Participants {
+				participants[participant.JID] = participant.Status
+			}
+			if tt.wantParticipants == nil {
+				if len(status.Participants) != 0 {
+					t.Errorf("participants = %v, want none outside groups", participants)
+				}
+				return
+			}
+			if len(participants) != len(tt.wantParticipants) {
+				t.Errorf("participants = %v, want %v", participants, tt.wantParticipants)
+			}
+			for jid, want := range tt.wantParticipants {
+				if participants[jid] != want {
+					t.Errorf("participant %s status = %s, want %s", jid, participants[jid], want)
+				}
+			}
+			// The group is read and delivered as soon as its first participant is
+			if !status.ReadAt.Equal(testEpoch.Add(3*time.Hour)) || !status.DeliveredAt.Equal(testEpoch.Add(time.Hour)) {
+				t.Errorf("read at %v, delivered at %v", status.ReadAt, status.DeliveredAt)
+			}
+		})
+	}
+
+	if _, err := WasMessageRead(testCarolJID, "SENT"); err == nil {
+		t.Errorf("WasMessageRead found a message in a chat it was not sent in")
+	}
+}
+
+func TestGetMessageReactions(t *testing.T) {
+	d := newTestDB(t)
+	ctx := context.Background()
+
+	storeMessages(t, d,
+		testMessage(testGroupJID, "M1", testAliceJID, "lunch?", 0),
+		testMessage(testBobJID, "M1", testBobJID, "same id elsewhere", time.Minute),
+	)
+
+	reactions := []models.Reaction{
+		{MessageID: "M1", ChatJID: testGroupJID, Sender: testAliceJID, Emoji: "👍", Timestamp: testEpoch.Add(time.Minute)},
+		{MessageID: "M1", ChatJID: testGroupJID, Sender: testBobJID, Emoji: "👍", Timestamp: testEpoch.Add(2 * time.Minute)},
+		{MessageID: "M1", ChatJID: testGroupJID, Sender: testCarolJID, Emoji: "😂", Timestamp: testEpoch.Add(3 * time.Minute)},
+		{MessageID: "M1", ChatJID: testBobJID, Sender: testBobJID, Emoji: "❤️", Timestamp: testEpoch.Add(4 * time.Minute)},
+	}
+	for _, reaction := range reactions {
+		if err := d.StoreReaction(ctx, reaction); err != nil {
+			t.Fatalf("StoreReaction: %v", err)
+		}
+	}
+
+	got, err := GetMessageReactions(testGroupJID, "M1")
+	if err != nil {
+		t.Fatalf("GetMessageReactions: %v", err)
+	}
+	if got.Total != 3 || len(got.Reactions) != 2 {
+		t.Fatalf("reactions = %+v, want 3 reactions with 2 emojis", got)
+	}
+	if got.Reactions[0].Emoji != "👍" || got.Reactions[0].Count != 2 || got.Reactions[1].Emoji != "😂" {
+		t.Errorf("reactions = %+v, want 👍 twice then 😂", got.Reactions)
+	}
+
+	got, err = GetMessageReactions(testBobJID, "M1")
+	if err != nil {
+		t.Fatalf("GetMessageReactions: %v", err)
+	}
+	if got.Total != 1 || got.Reactions[0].Emoji != "❤️" {
+		t.Errorf("reactions = %+v, want only the ❤️ of the direct chat", got.Reactions)
+	}
+}
+
+func TestDetectActivitySpikes(t *testing.T) {
+	day := func(n int) time.Time { return testEpoch.Truncate(24*time.Hour).AddDate(0, 0, n) }
+	series := func(counts ...int) []HistogramBucket {
+		buckets := make([]HistogramBucket, 0, len(counts))
+		for i, count := range counts {
+			buckets = append(buckets, HistogramBucket{Start: day(i), Count: count})
+		}
+		return buckets
+	}
+
+	tests := []struct {
+		name       string
+		buckets    []HistogramBucket
+		today      time.Time
+		wantMean   float64
+		wantStdDev float64
+		wantSpikes map[int]int
+		wantErr    bool
+	}{
+		{
+			// Ten days alternating 2 and 4 messages, then a burst, a busy day and a quiet today
+			name:       "spike",
+			buckets:    series(2, 4, 2, 4, 2, 4, 2, 4, 2, 4, 12, 5),
+			today:      day(12),
+			wantMean:   3,
+			wantStdDev: 1,
+			wantSpikes: map[int]int{10: 12},
+		},
+		{
+			// Without variation the deviation is floored at one message
+			name:       "regular chat",
+			buckets:    series(5, 5, 5, 5, 5, 5, 5, 5, 7, 8, 5),
+			today:      day(10),
+			wantMean:   5,
+			wantStdDev: 0,
+			wantSpikes: map[int]int{9: 8},
+		},
+		{
+			// Days after today are ignored and quiet days up to it are counted
+			name:       "quiet recent days",
+			buckets:    append(series(2, 4, 2, 4, 2, 4, 2, 4), HistogramBucket{Start: day(20), Count: 50}),
+			today:      day(10),
+			wantMean:   3,
+			wantStdDev: 1,
+			wantSpikes: map[int]int{},
+		},
+		{
+			name:    "short history",
+			buckets: series(2, 4, 2, 4, 2, 4, 30),
+			today:   day(6),
+			wantErr: true,
+		},
+		{
+			name:    "no messages",
+			today:   day(6),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := detectActivitySpikes(testAliceJID, tt.buckets, tt.today, 3, 3)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("detectActivitySpikes = %+v, want an error", report)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("detectActivitySpikes: %v", err)
+			}
+
+			if report.BaselineMean != tt.wantMean || report.BaselineStdDev != tt.wantStdDev {
+				t.Errorf("baseline mean %v, deviation %v, want %v and %v", report.BaselineMean, report.BaselineStdDev, tt.wantMean, tt.wantStdDev)
+			}
+
+			spikes := map[int]int{}
+			for _, spike := range report.Spikes {
+				spikes[int(spike.Day.Sub(day(0))/(24*time.Hour))] = spike.Count
+			}
+			if len(spikes) != len(tt.wantSpikes) {
+				t.Errorf("spikes on days %v, want %v", spikes, tt.wantSpikes)
+			}
+			for n, count := range tt.wantSpikes {
+				if spikes[n] != count {
+					t.Errorf("spikes on days %v, want %v", spikes, tt.wantSpikes)
+				}
+			}
+		})
+	}
+}
+
+func TestGetConversationBalance(t *testing.T) {
+	d := newTestDB(t)
+
+	storeMessages(t, d,
+		testMessage(testAliceJID, "M1", testAliceJID, "hi", 0),
+		testMessage(testAliceJID, "M2", testAliceJID, "how are you", time.Minute),
+		testMessage(testAliceJID, "M3", "", "fine thanks", 2*time.Minute),
+		testMessage(testAliceJID, "M4", testAliceJID, "ok", time.Hour),
+		testMessage(testBobJID, "M5", testBobJID, "not in this chat", time.Minute),
+	)
+
+	t.Run("known mix", func(t *testing.T) {
+		balance, err := GetConversationBalance(testAliceJID, nil)
+		if err != nil {
+			t.Fatalf("GetConversationBalance: %v", err)
+		}
+
+		want := ConversationBalance{
+			ChatJID:         testAliceJID,
+			TotalMessages:   4,
+			TotalCharacters: 26,
+			Me:              PartyBalance{Messages: 1, Characters: 11, MessagePercent: 25, CharacterPercent: 42.3},
+			Them:            PartyBalance{Messages: 3, Characters: 15, MessagePercent: 75, CharacterPercent: 57.7},
+		}
+		if *balance != want {
+			t.Errorf("balance = %+v, want %+v", *balance, want)
+		}
+	})
+
+	t.Run("date range", func(t *testing.T) {
+		dateRange := []time.Time{testEpoch, testEpoch.Add(2 * time.Minute)}
+		balance, err := GetConversationBalance(testAliceJID, dateRange)
+		if err != nil {
+			t.Fatalf("GetConversationBalance: %v", err)
+		}
+		if balance.Me.Messages != 1 || balance.Them.Messages != 2 {
+			t.Errorf("balance = %+v, want 1 message from me and 2 from them", balance)
+		}
+
+		// The range selects the same messages as the histogram over it
+		buckets, err := GetMessageHistogram(testAliceJID, dateRange, "day")
+		if err != nil {
+			t.Fatalf("GetMessageHistogram: %v", err)
+		}
+		histogramTotal := 0
+		for _, bucket := range buckets {
+			histogramTotal += bucket.Count
+		}
+		if histogramTotal != balance.TotalMessages {
+			t.Errorf("histogram counts %d messages in range, balance %d", histogramTotal, balance.TotalMessages)
+		}
+	})
+
+	t.Run("empty chat", func(t *testing.T) {
+		balance, err := GetConversationBalance(testCarolJID, nil)
+		if err != nil {
+			t.Fatalf("GetConversationBalance: %v", err)
+		}
+		if want := (ConversationBalance{ChatJID: testCarolJID}); *balance != want {
+			t.Errorf("balance = %+v, want %+v", *balance, want)
+		}
+	})
+
+	t.Run("group", func(t *testing.T) {
+		if _, err := GetConversationBalance(testGroupJID, nil); err == nil {
+			t.Errorf("GetConversationBalance succeeded on a group")
+		}
+	})
+}
+
+func TestListMessagesByChat(t *testing.T) {
+	d := newTestDB(t)
+
+	storeMessages(t, d,
+		testMessage(testGroupJID, "G1", testAliceJID, "lunch at noon?", 0),
+		testMessage(testAliceJID, "A1", testAliceJID, "see you at lunch", time.Minute),
+		testMessage(testGroupJID, "G2", testBobJID, "sure", 2*time.Minute),
+		testMessage(testGroupJID, "G3", "", "lunch it is", 3*time.Minute),
+		testMessage(testBobJID, "B1", testBobJID, "call me", 4*time.Minute),
+	)
+	for jid, name := range map[string]string{testGroupJID: "Team", testAliceJID: "Alice"} {
+		if err := d.StoreChat(context.Background(), models.Chat{JID: jid, Name: name, LastMessageTime: testEpoch}); err != nil {
+			t.Fatalf("StoreChat: %v", err)
+		}
+	}
+
+	groups, truncated, err := ListMessagesByChat(nil, "", "", "lunch", "", "", 20, 0, false, 0, 0, defaultMaxContext)
+	if err != nil {
+		t.Fatalf("ListMessagesByChat: %v", err)
+	}
+	if truncated {
+		t.Errorf("truncated without context")
+	}
+
+	type group struct {
+		jid, name string
+		count     int
+		ids       []string
+	}
+	// Chats come in order of their newest match, each holding its matches newest first
+	want := []group{
+		{jid: testGroupJID, name: "Team", count: 2, ids: []string{"G3", "G1"}},
+		{jid: testAliceJID, name: "Alice", count: 1, ids: []string{"A1"}},
+	}
+
+	if len(groups) != len(want) {
+		t.Fatalf("got %d groups, want %d: %+v", len(groups), len(want), groups)
+	}
+	for i, g := range groups {
+		var ids []string
+		for _, msg := range g.Messages {
+			ids = append(ids, msg.ID)
+			if msg.ChatJID != g.ChatJID {
+				t.Errorf("message %s of %s grouped under %s", msg.ID, msg.ChatJID, g.ChatJID)
+			}
+		}
+		got := group{jid: g.ChatJID, name: g.ChatName, count: g.MatchCount, ids: ids}
+		if got.jid != want[i].jid || got.name != want[i].name || got.count != want[i].count || !slices.Equal(got.ids, want[i].ids) {
+			t.Errorf("group %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestGetFirstInteraction(t *testing.T) {
+	d := newTestDB(t)
+
+	// Stored newest first, after an older message that does not involve Alice
+	storeMessages(t, d,
+		testMessage(testAliceJID, "A2", testAliceJID, "see you", 2*time.Hour),
+		testMessage(testGroupJID, "G1", testAliceJID, "hi all", time.Hour),
+		testMessage(testAliceJID, "A1", "", "hello Alice", 30*time.Minute),
+		testMessage(testBobJID, "B1", testBobJID, "hi", 0),
+	)
+
+	for _, jid := range []string{testAliceJID, "15550002222"} {
+		msg, err := GetFirstInteraction(jid)
+		if err != nil {
+			t.Fatalf("GetFirstInteraction(%s): %v", jid, err)
+		}
+		if msg.ID != "A1" || !msg.Timestamp.Equal(testEpoch.Add(30*time.Minute)) {
+			t.Errorf("GetFirstInteraction(%s) = %s at %v, want A1 at %v", jid, msg.ID, msg.Timestamp, testEpoch.Add(30*time.Minute))
+		}
+	}
+
+	if _, err := GetFirstInteraction(testCarolJID); err == nil {
+		t.Errorf("GetFirstInteraction found a message for a contact without any")
+	}
+}
+
+func TestGetChatLabels(t *testing.T) {
+	d := newTestDB(t)
+	ctx := context.Background()
+
+	storeMessages(t, d,
+		testMessage(testAliceJID, "M1", testAliceJID, "my order?", 0),
+		testMessage(testBobJID, "M2", testBobJID, "hi", time.Minute),
+	)
+	for _, label := range []models.Label{{ID: "1", Name: "Unpaid"}, {ID: "2", Name: "Customers"}} {
+		if err := d.StoreLabel(ctx, label); err != nil {
+			t.Fatalf("StoreLabel: %v", err)
+		}
+		if err := d.SetChatLabel(ctx, testAliceJID, label.ID, true); err != nil {
+			t.Fatalf("SetChatLabel: %v", err)
+		}
+	}
+
+	chat, err := GetChat(testAliceJID, false)
+	if err != nil {
+		t.Fatalf("GetChat: %v", err)
+	}
+	if want := []string{"Customers", "Unpaid"}; !slices.Equal(chat.Labels, want) {
+		t.Errorf("labels = %v, want %v", chat.Labels, want)
+	}
+
+	// Removing a label locally takes it off the chat
+	if err := d.SetChatLabel(ctx, testAliceJID, "1", false); err != nil {
+		t.Fatalf("SetChatLabel: %v", err)
+	}
+	chat, err = GetChat(testAliceJID, false)
+	if err != nil {
+		t.Fatalf("GetChat: %v", err)
+	}
+	if want := []string{"Customers"}; !slices.Equal(chat.Labels, want) {
+		t.Errorf("labels = %v, want %v", chat.Labels, want)
+	}
+
+	chat, err = GetChat(testBobJID, false)
+	if err != nil {
+		t.Fatalf("GetChat: %v", err)
+	}
+	if len(chat.Labels) != 0 {
+		t.Errorf("unlabeled chat has labels %v", chat.Labels)
+	}
+}
+
+func TestContextExpander(t *testing.T) {
+	d := newTestDB(t)
+
+	var messages []models.Message
+	for i := 1; i <= 10; i++ {
+		messages = append(messages, testMessage(testAliceJID, fmt.Sprintf("M%d", i), testAliceJID, fmt.Sprintf("message %d", i), time.Duration(i)*time.Minute))
+	}
+	storeMessages(t, d, messages...)
+
+	match := func(ids ...string) []Message {
+		matches := make([]Message, 0, len(ids))
+		for _, id := range ids {
+			matches = append(matches, Message{ID: id, ChatJID: testAliceJID})
+		}
+		return matches
+	}
+
+	tests := []struct {
+		name          string
+		matches       []Message
+		max           int
+		want          []string
+		wantTruncated bool
+	}{
+		{
+			name:    "separate windows",
+			matches: match("M3", "M7"),
+			max:     defaultMaxContext,
+			want:    []string{"M2", "M3", "M4", "M6", "M7", "M8"},
+		},
+		{
+			// M4 is emitted once, as context of M3, and M3 is not repeated as context of M4
+			name:    "overlapping windows",
+			matches: match("M3", "M4"),
+			max:     defaultMaxContext,
+			want:    []string{"M2", "M3", "M4", "M5"},
+		},
+		{
+			name:          "cap reached",
+			matches:       match("M3", "M7"),
+			max:           2,
+			want:          []string{"M2", "M3", "M4", "M7"},
+			wantTruncated: true,
+		},
+		{
+			name:          "cap reached within a window",
+			matches:       match("M3", "M7"),
+			max:           3,
+			want:          []string{"M2", "M3", "M4", "M6", "M7"},
+			wantTruncated: true,
+		},
+		{
+			name:    "cap exactly reached",
+			matches: match("M3", "M4"),
+			max:     3,
+			want:    []string{"M2", "M3", "M4", "M5"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expander := newContextExpander(1, 1, tt.max)
+			got := expander.expand(tt.matches)
+
+			ids := make([]string, 0, len(got))
+			for _, msg := range got {
+				ids = append(ids, msg.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("expanded to %v, want %v", ids, tt.want)
+			}
+			if expander.truncated != tt.wantTruncated {
+				t.Errorf("truncated = %v, want %v", expander.truncated, tt.wantTruncated)
+			}
+			if expander.added > tt.max {
+				t.Errorf("added %d context messages over the cap of %d", expander.added, tt.max)
+			}
+		})
+	}
+}
+
+func TestGetMessagesAroundTime(t *testing.T) {
+	d := newTestDB(t)
+
+	// Two bursts of messages with a gap of hours between them, and a quiet evening
+	storeMessages(t, d,
+		testMessage(testAliceJID, "M1", testAliceJID, "morning", 0),
+		testMessage(testAliceJID, "M2", "", "morning!", 5*time.Minute),
+		testMessage(testAliceJID, "M3", testAliceJID, "lunch?", 3*time.Hour),
+		testMessage(testAliceJID, "M4", "", "sure", 3*time.Hour+time.Minute),
+		testMessage(testAliceJID, "M5", testAliceJID, "good night", 9*time.Hour),
+		testMessage(testBobJID, "B1", testBobJID, "right on time", 2*time.Hour),
+	)
+
+	tests := []struct {
+		name       string
+		target     time.Duration
+		want       string
+		wantBefore []string
+		wantAfter  []string
+	}{
+		{name: "closer to the earlier burst", target: time.Hour, want: "M2", wantBefore: []string{"M1"}, wantAfter: []string{"M3"}},
+		{name: "closer to the later burst", target: 2 * time.Hour, want: "M3", wantBefore: []string{"M2"}, wantAfter: []string{"M4"}},
+		{name: "exact match", target: 3*time.Hour + time.Minute, want: "M4", wantBefore: []string{"M3"}, wantAfter: []string{"M5"}},
+		{name: "after the last message", target: 24 * time.Hour, want: "M5", wantBefore: []string{"M4"}},
+		{name: "before the first message", target: -24 * time.Hour, want: "M1", wantAfter: []string{"M2"}},
+	}
+
+	ids := func(messages []Message) []string {
+		var ids []string
+		for _, msg := range messages {
+			ids = append(ids, msg.ID)
+		}
+		return ids
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			around, err := GetMessagesAroundTime(testAliceJID, testEpoch.Add(tt.target), 1, 1)
+			if err != nil {
+				t.Fatalf("GetMessagesAroundTime: %v", err)
+			}
+			if around.Message.ID != tt.want || around.Message.ChatJID != testAliceJID {
+				t.Errorf("closest message = %s in %s, want %s in %s", around.Message.ID, around.Message.ChatJID, tt.want, testAliceJID)
+			}
+			if got := ids(around.Before); !slices.Equal(got, tt.wantBefore) {
+				t.Errorf("before = %v, want %v", got, tt.wantBefore)
+			}
+			if got := ids(around.After); !slices.Equal(got, tt.wantAfter) {
+				t.Errorf("after = %v, want %v", got, tt.wantAfter)
+			}
+		})
+	}
+
+	if _, err := GetMessagesAroundTime(testCarolJID, testEpoch, 1, 1); err == nil {
+		t.Errorf("GetMessagesAroundTime found a message in an empty chat")
+	}
+}
+
+func TestGetSharedChats(t *testing.T) {
+	d := newTestDB(t)
+	ctx := context.Background()
+
+	const bookClubJID = "120363000000000001@g.us"
+
+	storeMessages(t, d,
+		testMessage(testAliceJID, "A1", testAliceJID, "hi", 0),
+		testMessage(testGroupJID, "G1", testBobJID, "lunch?", time.Hour),
+	)
+	if err := d.StoreChat(ctx, models.Chat{JID: testGroupJID, Name: "Lunch crew", LastMessageTime: testEpoch.Add(time.Hour)}); err != nil {
+		t.Fatalf("StoreChat: %v", err)
+	}
+	// Alice is in both groups, and has not written in either; the book club has no messages at all
+	if err := d.SetGroupParticipants(ctx, testGroupJID, []string{testAliceJID, testBobJID}); err != nil {
+		t.Fatalf("SetGroupParticipants: %v", err)
+	}
+	if err := d.SetGroupParticipants(ctx, bookClubJID, []string{testAliceJID, testCarolJID}); err != nil {
+		t.Fatalf("SetGroupParticipants: %v", err)
+	}
+
+	for _, jid := range []string{testAliceJID, "+15550002222"} {
+		chats, err := GetSharedChats(jid)
+		if err != nil {
+			t.Fatalf("GetSharedChats(%s): %v", jid, err)
+		}
+
+		got := map[string]SharedChat{}
+		for _, chat := range chats {
+			got[chat.JID] = chat
+		}
+		want := map[string]SharedChat{
+			testAliceJID: {JID: testAliceJID, Type: "direct", LastMessageTime: testEpoch},
+			testGroupJID: {JID: testGroupJID, Name: "Lunch crew", Type: "group", LastMessageTime: testEpoch.Add(time.Hour)},
+			bookClubJID:  {JID: bookClubJID, Type: "group"},
+		}
+		if len(chats) != len(want) {
+			t.Errorf("GetSharedChats(%s) = %+v, want %d chats", jid, chats, len(want))
+		}
+		for jid, w := range want {
+			g := got[jid]
+			if g.JID != w.JID || g.Name != w.Name || g.Type != w.Type || !g.LastMessageTime.Equal(w.LastMessageTime) {
+				t.Errorf("chat %s = %+v, want %+v", jid, g, w)
+			}
+		}
+	}
+
+	chats, err := GetSharedChats(testBobJID)
+	if err != nil {
+		t.Fatalf("GetSharedChats: %v", err)
+	}
+	if len(chats) != 1 || chats[0].JID != testGroupJID {
+		t.Errorf("GetSharedChats(Bob) = %+v, want only the lunch group", chats)
+	}
+}
+
+func TestListLargeMedia(t *testing.T) {
+	d := newTestDB(t)
+
+	media := func(id string, mediaType string, size uint64, path string, offset time.Duration) models.Message {
+		msg := testMessage(testAliceJID, id, testAliceJID, "", offset)
+		msg.Type = mediaType
+		msg.MediaType = mediaType
+		msg.FileLength = size
+		msg.MediaPath = path
+		return msg
+	}
+	storeMessages(t, d,
+		testMessage(testAliceJID, "T1", testAliceJID, "plain text", 0),
+		media("S1", "document", 512*1024, "", time.Minute),
+		media("E1", "audio", 1024*1024, "", 2*time.Minute),
+		media("L1", "video", 2*1024*1024, "", 3*time.Minute),
+		media("L2", "image", 5*1024*1024, "/media/L2.jpg", 4*time.Minute),
+	)
+
+	tests := []struct {
+		name    string
+		minSize int64
+		limit   int
+		want    []string
+	}{
+		{"boundary is inclusive", 1024 * 1024, 0, []string{"L2", "L1", "E1"}},
+		{"small files excluded", 1024*1024 + 1, 0, []string{"L2", "L1"}},
+		{"no minimum skips text", 0, 0, []string{"L2", "L1", "E1", "S1"}},
+		{"limit keeps largest", 0, 1, []string{"L2"}},
+		{"nothing large enough", 10 * 1024 * 1024, 0, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ListLargeMedia(tt.minSize, tt.limit)
+			if err != nil {
+				t.Fatalf("ListLargeMedia: %v", err)
+			}
+			ids := []string{}
+			for _, item := range got {
+				ids = append(ids, item.MessageID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("ids = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+
+	got, err := ListLargeMedia(2*1024*1024, 0)
+	if err != nil {
+		t.Fatalf("ListLargeMedia: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d items, want 2", len(got))
+	}
+	if got[0].SizeBytes != 5*1024*1024 || got[0].MediaType != "image" || !got[0].Downloaded {
+		t.Errorf("largest item = %+v, want a downloaded 5 MiB image", got[0])
+	}
+	if got[1].Downloaded {
+		t.Errorf("%s is reported downloaded without a media path", got[1].MessageID)
+	}
+}
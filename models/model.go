@@ -4,13 +4,33 @@ import "time"
 
 // Message represents a chat message
 type Message struct {
-	ID        string    `json:"id"`
-	ChatJID   string    `json:"chat_jid"`
-	Sender    string    `json:"sender"`
-	Content   string    `json:"content"`
-	Timestamp time.Time `json:"timestamp"`
-	IsFromMe  bool      `json:"is_from_me"`
-	ChatName  string    `json:"chat_name"`
+	ID         string    `json:"id"`
+	ChatJID    string    `json:"chat_jid"`
+	Sender     string    `json:"sender"`
+	Content    string    `json:"content"`
+	Timestamp  time.Time `json:"timestamp"`
+	IsFromMe   bool      `json:"is_from_me"`
+	ChatName   string    `json:"chat_name"`
+	MentionsMe bool      `json:"mentions_me"`
+	Type       string    `json:"type"`
+	RawType    string    `json:"raw_type,omitempty"`
+	MediaType  string    `json:"media_type,omitempty"`
+	MimeType   string    `json:"mime_type,omitempty"`
+	MediaPath  string    `json:"media_path,omitempty"`
+	FileLength uint64    `json:"file_length,omitempty"`
+
+	// ForwardingScore counts how many times the content was forwarded before reaching us
+	ForwardingScore uint32 `json:"forwarding_score,omitempty"`
+	// EditedAt is when the sender last edited the message. Storing a message with EditedAt set
+	// updates the content of the stored original instead of replacing it.
+	EditedAt *time.Time `json:"edited_at,omitempty"`
+
+	// Media download metadata, kept so failed downloads can be retried
+	URL           string `json:"-"`
+	DirectPath    string `json:"-"`
+	MediaKey      []byte `json:"-"`
+	FileSHA256    []byte `json:"-"`
+	FileEncSHA256 []byte `json:"-"`
 }
 
 // Chat represents a WhatsApp chat
@@ -29,6 +49,7 @@ type Contact struct {
 	PhoneNumber string `json:"phone_number"`
 	Name        string `json:"name"`
 	JID         string `json:"jid"`
+	IsBusiness  bool   `json:"is_business"`
 }
 
 // Status represents the status of the WhatsApp client
@@ -36,4 +57,207 @@ type Status struct {
 	Connected bool   `json:"connected"`
 	LoggedIn  bool   `json:"logged_in"`
 	PushName  string `json:"push_name"`
+	// Condition explains why the client is not working normally, empty when it is
+	Condition        string `json:"condition,omitempty"`
+	ConditionMessage string `json:"condition_message,omitempty"`
+	// IngestionPaused is set while received messages are buffered instead of stored
+	IngestionPaused  bool `json:"ingestion_paused"`
+	BufferedMessages int  `json:"buffered_messages,omitempty"`
+	// InitialSyncAt is when history was synced on first login, nil until then
+	InitialSyncAt *time.Time `json:"initial_sync_at,omitempty"`
+}
+
+// Reaction represents an emoji reaction to a message
+type Reaction struct {
+	MessageID string    `json:"message_id"`
+	ChatJID   string    `json:"chat_jid"`
+	Sender    string    `json:"sender"`
+	Emoji     string    `json:"emoji"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// MediaDeleteResult represents the outcome of deleting the media file of a message
+type MediaDeleteResult struct {
+	MessageID string `json:"message_id"`
+	ChatJID   string `json:"chat_jid"`
+	Deleted   bool   `json:"deleted"`
+}
+
+// Receipt represents a delivery or read receipt for a sent message from one recipient
+type Receipt struct {
+	MessageID   string    `json:"message_id"`
+	ChatJID     string    `json:"chat_jid"`
+	Participant string    `json:"participant"`
+	Read        bool      `json:"read"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// MediaDownload reports an attachment downloaded in the background for a received message
+type MediaDownload struct {
+	ChatJID   string
+	MessageID string
+	Path      string
+}
+
+// MediaDownloadResult represents the outcome of a media download attempt for a message
+type MediaDownloadResult struct {
+	MessageID string `json:"message_id"`
+	ChatJID   string `json:"chat_jid"`
+	Status    string `json:"status"`
+	MediaPath string `json:"media_path,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SendOptions holds the optional settings for sending a message
+type SendOptions struct {
+	WithPreview     bool   `json:"with_preview"`
+	QuotedMessageID string `json:"quoted_message_id"`
+	// ExpireSeconds makes the message disappear after this many seconds, zero keeps it
+	ExpireSeconds uint32 `json:"expire_seconds"`
+	// RequireKnownContact refuses to send to recipients that are neither a contact nor an existing chat
+	RequireKnownContact bool `json:"require_known_contact"`
+	// MessageID is used as the ID of the sent message instead of a generated one
+	MessageID string `json:"message_id"`
+	// Quoted is the message being replied to, looked up from QuotedMessageID
+	Quoted *Message `json:"-"`
+}
+
+// SendResult represents the outcome of sending a message
+type SendResult struct {
+	Reconnected   bool   `json:"reconnected"`
+	QuotedSnippet string `json:"quoted_snippet,omitempty"`
+	QuotedSender  string `json:"quoted_sender,omitempty"`
+	RecipientName string `json:"recipient_name,omitempty"`
+	MessageID     string `json:"message_id,omitempty"`
+	// Delivery lists the devices the message did not reach, set only when it was partially delivered
+	Delivery *DeliveryReport `json:"delivery,omitempty"`
+}
+
+// DeliveryReport lists the devices a sent message could not be delivered to
+type DeliveryReport struct {
+	FailedDevices []DeviceFailure `json:"failed_devices,omitempty"`
+	// ParticipantsChanged is set when the group members changed during the send, so some devices may have missed it
+	ParticipantsChanged bool `json:"participants_changed,omitempty"`
+}
+
+// DeviceFailure represents a device a message could not be encrypted for
+type DeviceFailure struct {
+	JID   string `json:"jid"`
+	Error string `json:"error"`
+}
+
+// RecipientResult represents the outcome of sending a broadcast to one recipient
+type RecipientResult struct {
+	Recipient string    `json:"recipient"`
+	JID       string    `json:"jid,omitempty"`
+	Status    string    `json:"status"`
+	MessageID string    `json:"message_id,omitempty"`
+	Timestamp time.Time `json:"timestamp,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// BroadcastResult represents the outcome of a broadcast. Messages sent are recorded
+// only under the ChatJID pseudo-chat, attributed to our own JID.
+type BroadcastResult struct {
+	ChatJID    string            `json:"chat_jid"`
+	Sent       int               `json:"sent"`
+	Failed     int               `json:"failed"`
+	Recipients []RecipientResult `json:"recipients"`
+}
+
+// StorageUsage represents the disk space used by the bridge's store
+type StorageUsage struct {
+	MessagesDBBytes         int64 `json:"messages_db_bytes"`
+	SessionDBBytes          int64 `json:"session_db_bytes"`
+	MediaBytes              int64 `json:"media_bytes"`
+	TotalBytes              int64 `json:"total_bytes"`
+	MediaFiles              int   `json:"media_files"`
+	Messages                int   `json:"messages"`
+	MediaMessages           int   `json:"media_messages"`
+	DownloadedMediaMessages int   `json:"downloaded_media_messages"`
+}
+
+// ChatReadResult represents the outcome of marking a chat read
+type ChatReadResult struct {
+	ChatJID string `json:"chat_jid"`
+	Marked  int    `json:"marked"`
+	Batches int    `json:"batches"`
+}
+
+// Counts represents the number of contacts, chats and messages known to the bridge
+type Counts struct {
+	Contacts    int `json:"contacts"`
+	DirectChats int `json:"direct_chats"`
+	GroupChats  int `json:"group_chats"`
+	Messages    int `json:"messages"`
+}
+
+// IngestionState reports the effect of pausing or resuming message ingestion
+type IngestionState struct {
+	Paused           bool `json:"paused"`
+	BufferedMessages int  `json:"buffered_messages"`
+	// BufferLimit is the number of buffered messages at which ingestion resumes by itself
+	BufferLimit int `json:"buffer_limit,omitempty"`
+	Flushed     int `json:"flushed"`
+	// AutoResumed is set when the pause ended early because the buffer was full
+	AutoResumed bool `json:"auto_resumed,omitempty"`
+}
+
+// HistorySyncChunk describes a chunk of history received from WhatsApp
+type HistorySyncChunk struct {
+	SyncType      string
+	Progress      uint32
+	Conversations int
+	Messages      int
+}
+
+// HistorySyncStatus represents the progress of the current or last history sync.
+// State is "syncing" while chunks keep arriving and "idle" otherwise.
+type HistorySyncStatus struct {
+	State         string     `json:"state"`
+	SyncType      string     `json:"sync_type,omitempty"`
+	Progress      uint32     `json:"progress"`
+	Chunks        int        `json:"chunks"`
+	Conversations int        `json:"conversations"`
+	Messages      int        `json:"messages"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	LastChunkAt   *time.Time `json:"last_chunk_at,omitempty"`
+}
+
+// Label represents a chat label, synced to WhatsApp for business accounts
+type Label struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Color  int32  `json:"color"`
+	Synced bool   `json:"synced"`
+}
+
+// RawMessage holds the base64 encoded proto of a received message, kept for debugging.
+// Size is the length of the full proto, which is cut short when Truncated is set.
+type RawMessage struct {
+	MessageID  string    `json:"message_id"`
+	ChatJID    string    `json:"chat_jid"`
+	Raw        string    `json:"raw"`
+	Size       int       `json:"size"`
+	Truncated  bool      `json:"truncated"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// GroupUpdate represents a change of group membership received from WhatsApp. Participants
+// is set with the full member list when it is known, otherwise Joined and Left list the changes.
+type GroupUpdate struct {
+	GroupJID     string
+	Participants []string
+	Joined       []string
+	Left         []string
+}
+
+// LabelUpdate represents a label change received from WhatsApp. Label is set when a
+// label was edited or deleted, otherwise ChatJID was labeled or unlabeled with LabelID.
+type LabelUpdate struct {
+	Label    *Label
+	Deleted  bool
+	ChatJID  string
+	LabelID  string
+	Assigned bool
 }
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+)
+
+const (
+	// alertTimeout bounds how long a webhook may take to accept an alert
+	alertTimeout = 10 * time.Second
+	// alertQueueSize is the number of alerts that can wait for the webhook
+	alertQueueSize = 64
+)
+
+// forwardAlert is the payload POSTed to the forward alert webhook
+type forwardAlert struct {
+	Event     string         `json:"event"`
+	Threshold uint32         `json:"threshold"`
+	ChatName  string         `json:"chat_name"`
+	Message   models.Message `json:"message"`
+}
+
+// shouldAlertForward reports whether a received message was forwarded often enough to alert on
+func (o Options) shouldAlertForward(msg models.Message) bool {
+	if o.ForwardAlertThreshold == 0 || o.ForwardAlertWebhook == "" || msg.IsFromMe {
+		return false
+	}
+	return msg.ForwardingScore >= o.ForwardAlertThreshold
+}
+
+// alertForwarded queues the messages of the chat that reach the forward alert threshold.
+// The webhook is called by sendAlerts so a slow endpoint does not hold up storage; when
+// the queue is full the alert is dropped.
+func (s *service) alertForwarded(chat models.Chat) {
+	for _, msg := range chat.Messages {
+		if !s.opts.shouldAlertForward(msg) {
+			continue
+		}
+
+		alert := forwardAlert{
+			Event:     "forwarded_many_times",
+			Threshold: s.opts.ForwardAlertThreshold,
+			ChatName:  chat.Name,
+			Message:   msg,
+		}
+		select {
+		case s.alerts <- alert:
+		default:
+			fmt.Println("Forward alert queue is full, skipping alert for message", msg.ID)
+		}
+	}
+}
+
+// sendAlerts posts queued alerts to the forward alert webhook one at a time
+func (s *service) sendAlerts() {
+	for alert := range s.alerts {
+		if err := postAlert(s.opts.ForwardAlertWebhook, alert); err != nil {
+			fmt.Println("Error sending forward alert:", err)
+		}
+	}
+}
+
+// postAlert sends an alert to a webhook as JSON
+func postAlert(url string, alert any) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), alertTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call webhook: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+)
+
+func TestShouldAlertForward(t *testing.T) {
+	opts := Options{ForwardAlertThreshold: 5, ForwardAlertWebhook: "http://localhost/alerts"}
+
+	tests := []struct {
+		name string
+		opts Options
+		msg  models.Message
+		want bool
+	}{
+		{name: "below threshold", opts: opts, msg: models.Message{ForwardingScore: 4}, want: false},
+		{name: "at threshold", opts: opts, msg: models.Message{ForwardingScore: 5}, want: true},
+		{name: "above threshold", opts: opts, msg: models.Message{ForwardingScore: 6}, want: true},
+		{name: "sent by me", opts: opts, msg: models.Message{ForwardingScore: 5, IsFromMe: true}, want: false},
+		{name: "disabled", opts: Options{ForwardAlertWebhook: opts.ForwardAlertWebhook}, msg: models.Message{ForwardingScore: 5}, want: false},
+		{name: "no webhook", opts: Options{ForwardAlertThreshold: 5}, msg: models.Message{ForwardingScore: 5}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.shouldAlertForward(tt.msg); got != tt.want {
+				t.Errorf("shouldAlertForward(score %d) = %v, want %v", tt.msg.ForwardingScore, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlertForwarded(t *testing.T) {
+	received := make(chan forwardAlert)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert forwardAlert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Errorf("decoding alert: %v", err)
+		}
+		received <- alert
+	}))
+	defer server.Close()
+
+	s := &service{
+		opts:   Options{ForwardAlertThreshold: 5, ForwardAlertWebhook: server.URL},
+		alerts: make(chan forwardAlert, 1),
+	}
+
+	chat := models.Chat{JID: "15550002222@s.whatsapp.net", Name: "Alice", Messages: []models.Message{
+		{ID: "A1", ForwardingScore: 5},
+		{ID: "A2", ForwardingScore: 1},
+		{ID: "A3", ForwardingScore: 9},
+	}}
+
+	// Nothing drains the queue yet: A1 fills it and A3 is dropped instead of blocking
+	done := make(chan struct{})
+	go func() {
+		s.alertForwarded(chat)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("alertForwarded blocked on a full queue")
+	}
+
+	go s.sendAlerts()
+	defer close(s.alerts)
+
+	select {
+	case alert := <-received:
+		if alert.Message.ID != "A1" || alert.ChatName != "Alice" || alert.Threshold != 5 {
+			t.Errorf("alert = %+v, want A1 from Alice at threshold 5", alert)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("alert was not posted")
+	}
+
+	select {
+	case alert := <-received:
+		t.Errorf("unexpected alert for %s", alert.Message.ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
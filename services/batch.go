@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+)
+
+// consumeChats stores the chats received from WhatsApp until the service is closed.
+// When batching is enabled, chats are buffered and written in one transaction once
+// BatchSize messages are pending or BatchInterval has elapsed, whichever comes first.
+// While ingestion is paused, chats are held in memory and stored on resume or close,
+// or as soon as IngestionBufferLimit messages are held, which ends the pause.
+// Media paths of attachments downloaded in the background are written the same way.
+func (s *service) consumeChats(chats <-chan models.Chat, media <-chan models.MediaDownload) {
+	defer close(s.stopped)
+
+	var batch []models.Chat
+	var pending int
+	var deadline <-chan time.Time
+	var held []models.Chat
+	var heldMessages int
+	var heldMedia []models.MediaDownload
+	var autoResumed bool
+	bufferLimit := s.opts.ingestionBufferLimit()
+
+	flush := func() {
+		if len(batch) > 0 {
+			if err := s.db.StoreBatch(context.Background(), batch); err != nil {
+				fmt.Println("Error storing batch:", err)
+			}
+		}
+		batch = nil
+		pending = 0
+		deadline = nil
+	}
+
+	store := func(chat models.Chat) {
+		if s.opts.BatchSize <= 1 {
+			err := s.storeChatAndMessage(context.Background(), chat)
+			if err != nil {
+				fmt.Println("Error storing chat and message:", err)
+			}
+			return
+		}
+
+		if len(batch) == 0 {
+			deadline = time.After(s.opts.BatchInterval)
+		}
+
+		batch = append(batch, chat)
+		pending += max(len(chat.Messages), 1)
+
+		if pending >= s.opts.BatchSize {
+			flush()
+		}
+	}
+
+	release := func() int {
+		flushed := heldMessages
+		for _, chat := range held {
+			store(chat)
+		}
+		flush()
+		for _, download := range heldMedia {
+			s.storeMediaPath(download)
+		}
+		held = nil
+		heldMessages = 0
+		heldMedia = nil
+		s.heldMessages.Store(0)
+		s.paused.Store(false)
+		return flushed
+	}
+
+	for {
+		select {
+		case chat := <-chats:
+			chat = s.transformChat(chat)
+			s.alertForwarded(chat)
+
+			if s.paused.Load() {
+				held = append(held, chat)
+				heldMessages += len(chat.Messages)
+				s.heldMessages.Store(int64(heldMessages))
+
+				if heldMessages >= bufferLimit {
+					fmt.Printf("Ingestion buffer reached %d messages, resuming ingestion\n", heldMessages)
+					release()
+					autoResumed = true
+				}
+				continue
+			}
+
+			store(chat)
+		case download := <-media:
+			if s.paused.Load() {
+				heldMedia = append(heldMedia, download)
+				continue
+			}
+
+			// The message may still be waiting in the batch
+			flush()
+			s.storeMediaPath(download)
+		case req := <-s.ingestion:
+			if !req.pause {
+				req.reply <- models.IngestionState{Flushed: release(), AutoResumed: autoResumed}
+				autoResumed = false
+				continue
+			}
+
+			if !s.paused.Load() {
+				flush()
+				s.paused.Store(true)
+			}
+			autoResumed = false
+			req.reply <- models.IngestionState{Paused: true, BufferedMessages: heldMessages, BufferLimit: bufferLimit}
+		case <-deadline:
+			flush()
+		case <-s.done:
+			release()
+			return
+		}
+	}
+}
+
+// storeMediaPath records the file of an attachment downloaded in the background
+func (s *service) storeMediaPath(download models.MediaDownload) {
+	err := s.db.UpdateMediaPath(context.Background(), download.ChatJID, download.MessageID, download.Path)
+	if err != nil {
+		fmt.Println("Error storing media path:", err)
+	}
+}
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mbenaiss/whatsapp-mcp/db"
+	"github.com/mbenaiss/whatsapp-mcp/models"
+)
+
+// newTestService starts consumeChats on a fresh database in a temporary directory
+func newTestService(t *testing.T, opts Options) (*service, chan models.Chat, chan models.MediaDownload) {
+	t.Helper()
+
+	d, err := db.NewDB(context.Background(), t.TempDir(), db.Options{})
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	s := &service{
+		db:        d,
+		opts:      opts,
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+		ingestion: make(chan ingestionRequest),
+	}
+	chats := make(chan models.Chat)
+	media := make(chan models.MediaDownload)
+	go s.consumeChats(chats, media)
+
+	return s, chats, media
+}
+
+// testChat returns a chat holding one received message per ID
+func testChat(jid string, ids ...string) models.Chat {
+	chat := models.Chat{JID: jid, Name: "Alice", LastMessageTime: time.Now()}
+	for _, id := range ids {
+		chat.Messages = append(chat.Messages, models.Message{
+			ID:        id,
+			ChatJID:   jid,
+			Sender:    jid,
+			Content:   "message " + id,
+			Timestamp: time.Now(),
+			Type:      "text",
+		})
+	}
+	return chat
+}
+
+func TestConsumeChatsMediaPath(t *testing.T) {
+	const jid = "15550002222@s.whatsapp.net"
+
+	tests := []struct {
+		name   string
+		opts   Options
+		paused bool
+	}{
+		{name: "unbatched", opts: Options{BatchSize: 1}},
+		{name: "message still in the batch", opts: Options{BatchSize: 10, BatchInterval: time.Hour}},
+		{name: "paused", opts: Options{BatchSize: 10, BatchInterval: time.Hour}, paused: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, chats, media := newTestService(t, tt.opts)
+			ctx := context.Background()
+
+			if tt.paused {
+				if _, err := s.PauseIngestion(); err != nil {
+					t.Fatalf("PauseIngestion: %v", err)
+				}
+			}
+
+			chats <- testChat(jid, "A1")
+			media <- models.MediaDownload{ChatJID: jid, MessageID: "A1", Path: "/media/A1.jpg"}
+
+			if tt.paused {
+				// Sync with consumeChats before checking nothing was written
+				if _, err := s.PauseIngestion(); err != nil {
+					t.Fatalf("PauseIngestion: %v", err)
+				}
+				if msg, _ := s.db.GetMessage(ctx, jid, "A1"); msg != nil {
+					t.Fatalf("message stored while paused: %+v", msg)
+				}
+				if _, err := s.ResumeIngestion(); err != nil {
+					t.Fatalf("ResumeIngestion: %v", err)
+				}
+			}
+
+			if err := s.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			msg, err := s.db.GetMessage(ctx, jid, "A1")
+			if err != nil {
+				t.Fatalf("GetMessage: %v", err)
+			}
+			if msg.MediaPath != "/media/A1.jpg" {
+				t.Errorf("media path = %q, want %q", msg.MediaPath, "/media/A1.jpg")
+			}
+		})
+	}
+}
+
+func TestConsumeChatsFlush(t *testing.T) {
+	const jid = "15550002222@s.whatsapp.net"
+
+	tests := []struct {
+		name string
+		opts Options
+		// chats holds the message IDs of each chat sent
+		chats [][]string
+		// wait is how long to wait before checking what was stored
+		wait time.Duration
+		// close closes the service before checking
+		close      bool
+		wantStored int
+	}{
+		{
+			name:       "unbatched stores each chat",
+			opts:       Options{BatchSize: 1},
+			chats:      [][]string{{"A1"}, {"A2"}},
+			close:      true,
+			wantStored: 2,
+		},
+		{
+			name:       "below the size waits",
+			opts:       Options{BatchSize: 10, BatchInterval: time.Hour},
+			chats:      [][]string{{"A1"}, {"A2"}},
+			wantStored: 0,
+		},
+		{
+			name:       "size reached",
+			opts:       Options{BatchSize: 3, BatchInterval: time.Hour},
+			chats:      [][]string{{"A1"}, {"A2"}, {"A3"}},
+			wantStored: 3,
+		},
+		{
+			name:       "size counts messages, not chats",
+			opts:       Options{BatchSize: 3, BatchInterval: time.Hour},
+			chats:      [][]string{{"A1", "A2", "A3"}},
+			wantStored: 3,
+		},
+		{
+			name:       "interval elapsed",
+			opts:       Options{BatchSize: 100, BatchInterval: 20 * time.Millisecond},
+			chats:      [][]string{{"A1"}, {"A2"}},
+			wait:       200 * time.Millisecond,
+			wantStored: 2,
+		},
+		{
+			name:       "shutdown",
+			opts:       Options{BatchSize: 100, BatchInterval: time.Hour},
+			chats:      [][]string{{"A1"}, {"A2"}},
+			close:      true,
+			wantStored: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, chats, _ := newTestService(t, tt.opts)
+			ctx := context.Background()
+
+			for _, ids := range tt.chats {
+				chats <- testChat(jid, ids...)
+			}
+
+			time.Sleep(tt.wait)
+			if tt.close {
+				if err := s.Close(); err != nil {
+					t.Fatalf("Close: %v", err)
+				}
+			} else {
+				// Once another chat is received, consumeChats is done with the previous ones
+				chats <- models.Chat{JID: "15550009999@s.whatsapp.net"}
+			}
+
+			messages, err := s.db.GetMessages(ctx, jid, 100)
+			if err != nil {
+				t.Fatalf("GetMessages: %v", err)
+			}
+			if len(messages) != tt.wantStored {
+				t.Errorf("stored %d messages, want %d", len(messages), tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestIngestionBufferLimit(t *testing.T) {
+	const jid = "15550002222@s.whatsapp.net"
+
+	tests := []struct {
+		name string
+		// chats holds the message IDs of each chat received while paused
+		chats           [][]string
+		wantStored      int
+		wantPaused      bool
+		wantAutoResumed bool
+	}{
+		{
+			name:       "below the limit stays paused",
+			chats:      [][]string{{"A1"}, {"A2"}},
+			wantPaused: true,
+		},
+		{
+			name:            "limit reached",
+			chats:           [][]string{{"A1"}, {"A2"}, {"A3"}},
+			wantStored:      3,
+			wantAutoResumed: true,
+		},
+		{
+			name:            "limit passed by a chat with several messages",
+			chats:           [][]string{{"A1"}, {"A2", "A3", "A4"}},
+			wantStored:      4,
+			wantAutoResumed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, chats, _ := newTestService(t, Options{BatchSize: 1, IngestionBufferLimit: 3})
+			ctx := context.Background()
+
+			state, err := s.PauseIngestion()
+			if err != nil {
+				t.Fatalf("PauseIngestion: %v", err)
+			}
+			if state.BufferLimit != 3 {
+				t.Errorf("buffer limit = %d, want 3", state.BufferLimit)
+			}
+
+			for _, ids := range tt.chats {
+				chats <- testChat(jid, ids...)
+			}
+			// Once another chat is received, consumeChats is done with the previous ones
+			chats <- models.Chat{JID: "15550009999@s.whatsapp.net"}
+
+			messages, err := s.db.GetMessages(ctx, jid, 100)
+			if err != nil {
+				t.Fatalf("GetMessages: %v", err)
+			}
+			if len(messages) != tt.wantStored {
+				t.Errorf("stored %d messages while paused, want %d", len(messages), tt.wantStored)
+			}
+			if paused := s.paused.Load(); paused != tt.wantPaused {
+				t.Errorf("paused = %v, want %v", paused, tt.wantPaused)
+			}
+
+			state, err = s.ResumeIngestion()
+			if err != nil {
+				t.Fatalf("ResumeIngestion: %v", err)
+			}
+			if state.AutoResumed != tt.wantAutoResumed {
+				t.Errorf("auto resumed = %v, want %v", state.AutoResumed, tt.wantAutoResumed)
+			}
+
+			// A new pause starts over
+			if _, err := s.PauseIngestion(); err != nil {
+				t.Fatalf("PauseIngestion: %v", err)
+			}
+			if state, _ := s.ResumeIngestion(); state.AutoResumed {
+				t.Error("auto resume reported again after a new pause")
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+)
+
+// historySyncIdleTimeout is how long after the last chunk a history sync is considered finished
+const historySyncIdleTimeout = 2 * time.Minute
+
+// historySyncTracker accumulates the progress of history syncs as their chunks arrive
+type historySyncTracker struct {
+	mu     sync.Mutex
+	status models.HistorySyncStatus
+}
+
+// start marks a new sync as started at now, resetting the progress of any previous sync
+func (t *historySyncTracker) start(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.status = models.HistorySyncStatus{
+		StartedAt:   &now,
+		LastChunkAt: &now,
+	}
+}
+
+// add records a chunk received at now. A chunk arriving after the previous sync went idle starts a new sync.
+func (t *historySyncTracker) add(chunk models.HistorySyncChunk, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.syncing(now) {
+		t.status = models.HistorySyncStatus{StartedAt: &now}
+	}
+
+	t.status.SyncType = chunk.SyncType
+	t.status.Progress = chunk.Progress
+	t.status.Chunks++
+	t.status.Conversations += chunk.Conversations
+	t.status.Messages += chunk.Messages
+	t.status.LastChunkAt = &now
+}
+
+// get returns the status of the current or last sync as of now
+func (t *historySyncTracker) get(now time.Time) models.HistorySyncStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	status := t.status
+	status.State = "idle"
+	if t.syncing(now) {
+		status.State = "syncing"
+	}
+	return status
+}
+
+// syncing reports whether a sync is in progress as of now. The caller must hold mu.
+func (t *historySyncTracker) syncing(now time.Time) bool {
+	if t.status.LastChunkAt == nil || t.status.Progress >= 100 {
+		return false
+	}
+	return now.Sub(*t.status.LastChunkAt) < historySyncIdleTimeout
+}
+
+// GetHistorySyncStatus reports the progress of the current or last history sync
+func (s *service) GetHistorySyncStatus() models.HistorySyncStatus {
+	return s.historySync.get(time.Now())
+}
+
+// RequestHistorySync asks WhatsApp to send message history and starts tracking its progress
+func (s *service) RequestHistorySync(ctx context.Context) error {
+	if err := s.whatsapp.BuildHistorySync(ctx); err != nil {
+		return err
+	}
+
+	s.historySync.start(time.Now())
+	return nil
+}
+
+// syncOnFirstLogin requests a history sync the first time the client connects after pairing
+// a new device. Connecting with a restored session marks the store as synced without a
+// request, so restarts and upgrades of existing installs do not sync again. A failed
+// request is retried on the next connection.
+func (s *service) syncOnFirstLogin(ctx context.Context, freshlyPaired bool) error {
+	syncedAt, err := s.db.GetInitialSync(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get initial sync: %v", err)
+	}
+	if syncedAt != nil {
+		return nil
+	}
+
+	if freshlyPaired {
+		if err := s.RequestHistorySync(ctx); err != nil {
+			return err
+		}
+	}
+
+	return s.db.SetInitialSync(ctx, time.Now())
+}
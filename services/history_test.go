@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+	"github.com/mbenaiss/whatsapp-mcp/whatsapp"
+)
+
+func TestSyncOnFirstLogin(t *testing.T) {
+	const jid = "15550002222@s.whatsapp.net"
+	syncedAt := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		synced        bool
+		hasMessages   bool
+		freshlyPaired bool
+		// wantRequest is set when a history sync is requested, which fails without a connection
+		wantRequest bool
+		wantSynced  bool
+	}{
+		{
+			name:          "already synced",
+			synced:        true,
+			freshlyPaired: true,
+			wantSynced:    true,
+		},
+		{
+			name:       "restored session with an empty store",
+			wantSynced: true,
+		},
+		{
+			name:        "restored session with messages",
+			hasMessages: true,
+			wantSynced:  true,
+		},
+		{
+			name:          "freshly paired with an empty store",
+			freshlyPaired: true,
+			wantRequest:   true,
+		},
+		{
+			name:          "freshly paired with messages from a previous pairing",
+			hasMessages:   true,
+			freshlyPaired: true,
+			wantRequest:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, chats, _ := newTestService(t, Options{BatchSize: 1})
+			s.whatsapp = &whatsapp.Whatsapp{}
+			ctx := context.Background()
+
+			if tt.synced {
+				if err := s.db.SetInitialSync(ctx, syncedAt); err != nil {
+					t.Fatalf("SetInitialSync: %v", err)
+				}
+			}
+			if tt.hasMessages {
+				chats <- testChat(jid, "A1")
+				if err := s.Close(); err != nil {
+					t.Fatalf("Close: %v", err)
+				}
+			}
+
+			err := s.syncOnFirstLogin(ctx, tt.freshlyPaired)
+			if tt.wantRequest != (err != nil) {
+				t.Fatalf("syncOnFirstLogin error = %v, want a request %v", err, tt.wantRequest)
+			}
+
+			got, err := s.db.GetInitialSync(ctx)
+			if err != nil {
+				t.Fatalf("GetInitialSync: %v", err)
+			}
+			if (got != nil) != tt.wantSynced {
+				t.Fatalf("initial sync = %v, want recorded %v", got, tt.wantSynced)
+			}
+			if tt.synced && !got.Equal(syncedAt) {
+				t.Errorf("initial sync = %v, want it kept at %v", got, syncedAt)
+			}
+		})
+	}
+}
+
+func TestHistorySyncTracker(t *testing.T) {
+	start := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	var tracker historySyncTracker
+
+	if status := tracker.get(start); status.State != "idle" || status.Chunks != 0 {
+		t.Fatalf("status before any sync = %+v, want idle", status)
+	}
+
+	tracker.start(start)
+	if status := tracker.get(start.Add(time.Second)); status.State != "syncing" || status.Chunks != 0 {
+		t.Errorf("status after requesting a sync = %+v, want syncing without chunks", status)
+	}
+
+	chunks := []models.HistorySyncChunk{
+		{SyncType: "initial_bootstrap", Progress: 20, Conversations: 3, Messages: 40},
+		{SyncType: "initial_bootstrap", Progress: 55, Conversations: 2, Messages: 25},
+		{SyncType: "initial_bootstrap", Progress: 80, Conversations: 1, Messages: 5},
+	}
+	for i, chunk := range chunks {
+		tracker.add(chunk, start.Add(time.Duration(i+1)*time.Minute))
+	}
+
+	status := tracker.get(start.Add(4 * time.Minute))
+	want := models.HistorySyncStatus{
+		State:         "syncing",
+		SyncType:      "initial_bootstrap",
+		Progress:      80,
+		Chunks:        3,
+		Conversations: 6,
+		Messages:      70,
+	}
+	if status.StartedAt == nil || !status.StartedAt.Equal(start) || status.LastChunkAt == nil || !status.LastChunkAt.Equal(start.Add(3*time.Minute)) {
+		t.Errorf("started at %v, last chunk at %v", status.StartedAt, status.LastChunkAt)
+	}
+	status.StartedAt, status.LastChunkAt = nil, nil
+	if status != want {
+		t.Errorf("status = %+v, want %+v", status, want)
+	}
+
+	// Without chunks for a while the sync is over
+	if status := tracker.get(start.Add(3*time.Minute + historySyncIdleTimeout)); status.State != "idle" || status.Progress != 80 {
+		t.Errorf("status after going quiet = %+v, want idle at 80%%", status)
+	}
+
+	// A chunk arriving later starts a new sync, which completes at 100%
+	later := start.Add(time.Hour)
+	tracker.add(models.HistorySyncChunk{SyncType: "recent", Progress: 100, Conversations: 1, Messages: 2}, later)
+	status = tracker.get(later)
+	if status.State != "idle" || status.SyncType != "recent" || status.Chunks != 1 || status.Messages != 2 || !status.StartedAt.Equal(later) {
+		t.Errorf("status after a new complete sync = %+v", status)
+	}
+}
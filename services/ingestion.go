@@ -0,0 +1,47 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+)
+
+// ingestionRequest asks consumeChats to pause or resume writing received chats.
+// The resulting state is sent back on reply.
+type ingestionRequest struct {
+	pause bool
+	reply chan models.IngestionState
+}
+
+// PauseIngestion stops writing received messages to the database, buffering them in memory
+// until ResumeIngestion, so the database can be vacuumed or backed up. Messages already
+// buffered for a batch are written before it returns. Reactions, receipts and other
+// updates are still written. Once the buffer holds IngestionBufferLimit messages,
+// they are written and ingestion resumes by itself.
+func (s *service) PauseIngestion() (models.IngestionState, error) {
+	return s.requestIngestion(true)
+}
+
+// ResumeIngestion writes the messages buffered while paused and resumes writing as they arrive.
+// AutoResumed is set when the pause had already ended because the buffer was full.
+func (s *service) ResumeIngestion() (models.IngestionState, error) {
+	return s.requestIngestion(false)
+}
+
+func (s *service) requestIngestion(pause bool) (models.IngestionState, error) {
+	reply := make(chan models.IngestionState, 1)
+	select {
+	case s.ingestion <- ingestionRequest{pause: pause, reply: reply}:
+		return <-reply, nil
+	case <-s.stopped:
+		return models.IngestionState{}, errors.New("service is closed")
+	}
+}
+
+// ingestionBufferLimit returns the configured buffer limit, or the default when unset
+func (o Options) ingestionBufferLimit() int {
+	if o.IngestionBufferLimit <= 0 {
+		return defaultIngestionBufferLimit
+	}
+	return o.IngestionBufferLimit
+}
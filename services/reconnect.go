@@ -0,0 +1,45 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mbenaiss/whatsapp-mcp/whatsapp"
+)
+
+// connection is the part of the WhatsApp client sends reconnect through
+type connection interface {
+	IsConnected() bool
+	Condition() whatsapp.Condition
+	Reconnect(timeout time.Duration) error
+}
+
+// reconnector lets sends bring a dropped connection back, at most once per reconnectInterval
+type reconnector struct {
+	conn connection
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// reconnect makes a single reconnect attempt, refusing if another attempt was made recently
+func (r *reconnector) reconnect() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn.IsConnected() {
+		return nil
+	}
+
+	if condition := r.conn.Condition(); condition.NeedsAction {
+		return fmt.Errorf("not connected to WhatsApp: %s", condition.Message)
+	}
+
+	if since := time.Since(r.last); since < reconnectInterval {
+		return fmt.Errorf("not connected to WhatsApp, last reconnect attempt was %s ago", since.Round(time.Second))
+	}
+	r.last = time.Now()
+
+	return r.conn.Reconnect(reconnectTimeout)
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mbenaiss/whatsapp-mcp/whatsapp"
+)
+
+// fakeConnection counts reconnect attempts, connecting when reconnectErr is nil
+type fakeConnection struct {
+	connected    bool
+	condition    whatsapp.Condition
+	reconnectErr error
+	attempts     int
+}
+
+func (c *fakeConnection) IsConnected() bool { return c.connected }
+
+func (c *fakeConnection) Condition() whatsapp.Condition { return c.condition }
+
+func (c *fakeConnection) Reconnect(timeout time.Duration) error {
+	c.attempts++
+	if c.reconnectErr != nil {
+		return c.reconnectErr
+	}
+	c.connected = true
+	return nil
+}
+
+func TestReconnect(t *testing.T) {
+	tests := []struct {
+		name         string
+		conn         fakeConnection
+		lastAttempt  time.Duration
+		wantAttempts int
+		wantErr      bool
+	}{
+		{
+			name: "already connected",
+			conn: fakeConnection{connected: true},
+		},
+		{
+			name:         "dropped connection",
+			wantAttempts: 1,
+		},
+		{
+			name:         "attempt long ago",
+			lastAttempt:  2 * reconnectInterval,
+			wantAttempts: 1,
+		},
+		{
+			name:        "attempt too recent",
+			lastAttempt: reconnectInterval / 2,
+			wantErr:     true,
+		},
+		{
+			name:    "logged out",
+			conn:    fakeConnection{condition: whatsapp.Condition{Code: "logged_out", Message: "device logged out", NeedsAction: true}},
+			wantErr: true,
+		},
+		{
+			name:         "reconnect fails",
+			conn:         fakeConnection{reconnectErr: errors.New("timed out")},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := reconnector{conn: &tt.conn}
+			if tt.lastAttempt > 0 {
+				r.last = time.Now().Add(-tt.lastAttempt)
+			}
+
+			err := r.reconnect()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reconnect error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.conn.attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", tt.conn.attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestReconnectThrottlesFailedAttempts(t *testing.T) {
+	conn := &fakeConnection{reconnectErr: errors.New("timed out")}
+	r := reconnector{conn: conn}
+
+	if err := r.reconnect(); err == nil {
+		t.Fatal("first reconnect succeeded, want the connection error")
+	}
+	if err := r.reconnect(); err == nil {
+		t.Fatal("second reconnect succeeded, want it throttled")
+	}
+	if conn.attempts != 1 {
+		t.Errorf("attempts = %d, want 1", conn.attempts)
+	}
+
+	// Once connected, sends go through without another attempt
+	conn.connected = true
+	if err := r.reconnect(); err != nil {
+		t.Errorf("reconnect while connected: %v", err)
+	}
+}
@@ -3,9 +3,14 @@ package services
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"image/png"
 	"log"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/mbenaiss/whatsapp-mcp/db"
 	"github.com/mbenaiss/whatsapp-mcp/models"
@@ -13,30 +18,211 @@ import (
 	"github.com/skip2/go-qrcode"
 )
 
+var (
+	// ErrChatNotFound is returned when a chat does not exist
+	ErrChatNotFound = errors.New("chat not found")
+	// ErrMessageNotFound is returned when a message does not exist
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrNotMP4 is returned when a video to send is not an MP4 file
+	ErrNotMP4 = whatsapp.ErrNotMP4
+	// ErrPathNotAllowed is returned when a file to send is outside the send media directory
+	ErrPathNotAllowed = whatsapp.ErrPathNotAllowed
+	// ErrInvalidExpiration is returned when a message expiration is not one WhatsApp supports
+	ErrInvalidExpiration = errors.New("expiration must be 86400 (24 hours), 604800 (7 days) or 7776000 (90 days) seconds")
+	// ErrUnknownRecipient is returned when a send requires a known recipient and the recipient is neither a contact nor a chat
+	ErrUnknownRecipient = errors.New("recipient is not a known contact")
+	// ErrInvalidMessageID is returned when a custom message ID does not look like a WhatsApp message ID
+	ErrInvalidMessageID = errors.New("message ID must be 16 to 64 uppercase hexadecimal characters")
+	// ErrDuplicateMessageID is returned when a custom message ID was already used in the chat
+	ErrDuplicateMessageID = whatsapp.ErrDuplicateMessageID
+	// ErrGroupSendForbidden is returned when WhatsApp refuses a message to a group
+	ErrGroupSendForbidden = whatsapp.ErrGroupSendForbidden
+)
+
 type Service interface {
 	GetStatus() (models.Status, error)
-	SendMessage(ctx context.Context, recipient string, message string) error
+	SendMessage(ctx context.Context, recipient string, message string, opts models.SendOptions) (models.SendResult, error)
+	SendVideo(ctx context.Context, recipient string, path string, caption string, asGIF bool) (models.SendResult, error)
+	SendBroadcast(ctx context.Context, recipients []string, message string) (models.BroadcastResult, error)
+	SendReaction(ctx context.Context, chatJID string, messageID string, emoji string) error
 	GetChats(ctx context.Context) ([]models.Chat, error)
+	GetContacts(ctx context.Context) ([]models.Contact, error)
 	GetMessages(ctx context.Context, chatJID string, limit int) ([]models.Message, error)
 	GetQR(ctx context.Context) ([]byte, error)
 	IsConnected() bool
+	RetryMediaDownload(ctx context.Context, chatJID string, limit int) ([]models.MediaDownloadResult, error)
+	DeleteMedia(ctx context.Context, chatJID string, messageID string) (models.MediaDeleteResult, error)
+	GetStorageUsage(ctx context.Context) (models.StorageUsage, error)
+	GetCounts(ctx context.Context) (models.Counts, error)
+	GetHistorySyncStatus() models.HistorySyncStatus
+	RequestHistorySync(ctx context.Context) error
 	Login(ctx context.Context) error
+	ResyncChat(ctx context.Context, chatJID string) (*models.Chat, error)
+	MarkChatRead(ctx context.Context, chatJID string) (models.ChatReadResult, error)
+	GetLabels(ctx context.Context) ([]models.Label, error)
+	CreateLabel(ctx context.Context, name string, color int32) (models.Label, error)
+	AssignLabel(ctx context.Context, chatJID string, labelID string, assigned bool) error
+	PauseIngestion() (models.IngestionState, error)
+	ResumeIngestion() (models.IngestionState, error)
+	Close() error
+}
+
+// Options configures optional behavior of the service
+type Options struct {
+	// BatchSize is the number of messages buffered before they are written in a single
+	// transaction. Values of 1 or less store every message as soon as it arrives.
+	BatchSize int
+	// BatchInterval is the longest time a buffered message waits before being written
+	BatchInterval time.Duration
+	// StoreDir is the directory holding the message and session databases
+	StoreDir string
+	// StripZeroWidth removes invisible zero width characters from message content
+	StripZeroWidth bool
+	// NormalizeUnicode converts message content to Unicode NFC
+	NormalizeUnicode bool
+	// TrimWhitespace removes leading and trailing whitespace from message content
+	TrimWhitespace bool
+	// QRTerminalOutput renders login QR codes on the terminal as well as through the API
+	QRTerminalOutput bool
+	// ForwardAlertThreshold is the forwarding score at or above which a received message is
+	// reported to ForwardAlertWebhook. WhatsApp shows "forwarded many times" from 5. Zero disables alerts.
+	ForwardAlertThreshold uint32
+	// ForwardAlertWebhook is the URL forward alerts are POSTed to
+	ForwardAlertWebhook string
+	// SyncOnLogin requests a history sync once connected after pairing a new device
+	SyncOnLogin bool
+	// IngestionBufferLimit is the number of messages buffered while ingestion is paused at
+	// which it resumes by itself, so a forgotten pause can not exhaust memory.
+	// Zero uses defaultIngestionBufferLimit.
+	IngestionBufferLimit int
 }
 
 type service struct {
-	whatsapp *whatsapp.Whatsapp
-	db       db.DB
+	whatsapp   *whatsapp.Whatsapp
+	db         db.DB
+	opts       Options
+	transforms []transform
+	done       chan struct{}
+	stopped    chan struct{}
+
+	ingestion    chan ingestionRequest
+	paused       atomic.Bool
+	heldMessages atomic.Int64
+
+	reconnector reconnector
+	// reader sends read receipts, the WhatsApp client outside of tests
+	reader readMarker
+	// sender sends text messages, the WhatsApp client outside of tests
+	sender textSender
+	// contacts looks up session contacts, the WhatsApp client outside of tests
+	contacts contactNamer
+	// media manages downloaded media files, the WhatsApp client outside of tests
+	media mediaFiles
+	// chatNames fetches current chat names, the WhatsApp client outside of tests
+	chatNames chatNamer
+	// labeler syncs labels to WhatsApp Business, the WhatsApp client outside of tests
+	labeler labeler
+
+	historySync historySyncTracker
+
+	// alerts holds forward alerts waiting for the webhook
+	alerts chan forwardAlert
 }
 
+const (
+	// reconnectTimeout bounds how long a send waits for a dropped connection to come back
+	reconnectTimeout = 5 * time.Second
+	// reconnectInterval is the minimum time between reconnect attempts made by sends
+	reconnectInterval = 30 * time.Second
+	// maxBroadcastRecipients matches the size limit of WhatsApp broadcast lists
+	maxBroadcastRecipients = 256
+	// markReadBatchSize is the number of messages acknowledged per read receipt
+	markReadBatchSize = 50
+	// defaultIngestionBufferLimit is the IngestionBufferLimit used when none is configured
+	defaultIngestionBufferLimit = 10000
+)
+
 // NewService creates a new Service instance with the provided WhatsApp client
-func NewService(whatsapp *whatsapp.Whatsapp, db db.DB) Service {
-	s := &service{whatsapp: whatsapp, db: db}
+func NewService(whatsapp *whatsapp.Whatsapp, db db.DB, opts Options) Service {
+	s := &service{
+		whatsapp:   whatsapp,
+		db:         db,
+		opts:       opts,
+		transforms: opts.transforms(),
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+		ingestion:  make(chan ingestionRequest),
+		alerts:     make(chan forwardAlert, alertQueueSize),
+	}
+	s.reconnector.conn = whatsapp
+	s.reader = whatsapp
+	s.sender = whatsapp
+	s.contacts = whatsapp
+	s.media = whatsapp
+	s.chatNames = whatsapp
+	s.labeler = whatsapp
+
+	go s.consumeChats(whatsapp.ChatChan, whatsapp.MediaChan)
+	go s.sendAlerts()
+
+	go func() {
+		for reaction := range whatsapp.ReactionChan {
+			err := s.db.StoreReaction(context.Background(), reaction)
+			if err != nil {
+				fmt.Println("Error storing reaction:", err)
+			}
+		}
+	}()
+
+	go func() {
+		for chunk := range whatsapp.HistorySyncChan {
+			s.historySync.add(chunk, time.Now())
+		}
+	}()
+
+	go func() {
+		for receipt := range whatsapp.ReceiptChan {
+			err := s.db.StoreReceipt(context.Background(), receipt)
+			if err != nil {
+				fmt.Println("Error storing receipt:", err)
+			}
+		}
+	}()
+
+	go func() {
+		for update := range whatsapp.LabelChan {
+			err := s.storeLabelUpdate(context.Background(), update)
+			if err != nil {
+				fmt.Println("Error storing label update:", err)
+			}
+		}
+	}()
+
+	go func() {
+		for raw := range whatsapp.RawChan {
+			err := s.db.StoreRawMessage(context.Background(), raw)
+			if err != nil {
+				fmt.Println("Error storing raw message:", err)
+			}
+		}
+	}()
 
 	go func() {
-		for chat := range whatsapp.ChatChan {
-			err := s.storeChatAndMessage(context.Background(), chat)
+		for range whatsapp.ConnectedChan {
+			if !s.opts.SyncOnLogin {
+				continue
+			}
+			if err := s.syncOnFirstLogin(context.Background(), whatsapp.FreshlyPaired()); err != nil {
+				fmt.Println("Error syncing history on first login:", err)
+			}
+		}
+	}()
+
+	go func() {
+		for update := range whatsapp.GroupChan {
+			err := s.storeGroupUpdate(context.Background(), update)
 			if err != nil {
-				fmt.Println("Error storing chat and message:", err)
+				fmt.Println("Error storing group update:", err)
 			}
 		}
 	}()
@@ -51,7 +237,7 @@ func (s *service) GetQR(ctx context.Context) ([]byte, error) {
 		return nil, nil
 	}
 
-	qr, err := s.whatsapp.GetQR(ctx)
+	qr, err := s.whatsapp.GetQR(ctx, s.opts.QRTerminalOutput)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get QR code: %v", err)
 	}
@@ -78,22 +264,225 @@ func (s *service) Login(ctx context.Context) error {
 		return fmt.Errorf("failed to connect: %v", err)
 	}
 
-	// err = s.whatsapp.BuildHistorySync(ctx)
-	// if err != nil {
-	// 	return fmt.Errorf("failed to build history sync: %v", err)
-	// }
-
 	return nil
 }
 
 // GetStatus returns the current status of the WhatsApp client
 func (s *service) GetStatus() (models.Status, error) {
-	return s.whatsapp.GetStatus()
+	status, err := s.whatsapp.GetStatus()
+	if err != nil {
+		return status, err
+	}
+
+	status.IngestionPaused = s.paused.Load()
+	status.BufferedMessages = int(s.heldMessages.Load())
+
+	status.InitialSyncAt, err = s.db.GetInitialSync(context.Background())
+	if err != nil {
+		return status, fmt.Errorf("failed to get initial sync: %v", err)
+	}
+
+	return status, nil
+}
+
+// SendMessage sends a message to the specified recipient, reconnecting first if the connection dropped
+func (s *service) SendMessage(ctx context.Context, recipient string, message string, opts models.SendOptions) (models.SendResult, error) {
+	var result models.SendResult
+
+	if opts.ExpireSeconds != 0 && !whatsapp.ValidExpiration(opts.ExpireSeconds) {
+		return result, ErrInvalidExpiration
+	}
+
+	if opts.MessageID != "" {
+		if !whatsapp.ValidMessageID(opts.MessageID) {
+			return result, ErrInvalidMessageID
+		}
+
+		chatJID, err := whatsapp.RecipientChatJID(recipient)
+		if err != nil {
+			return result, err
+		}
+
+		existing, err := s.db.GetMessage(ctx, chatJID, opts.MessageID)
+		if err != nil {
+			return result, fmt.Errorf("failed to get message: %v", err)
+		}
+		if existing != nil {
+			return result, ErrDuplicateMessageID
+		}
+	}
+
+	if opts.RequireKnownContact {
+		name, err := s.knownRecipientName(ctx, recipient)
+		if err != nil {
+			return result, err
+		}
+		result.RecipientName = name
+	}
+
+	if opts.QuotedMessageID != "" {
+		chatJID, err := whatsapp.RecipientChatJID(recipient)
+		if err != nil {
+			return result, err
+		}
+
+		quoted, err := s.db.GetMessage(ctx, chatJID, opts.QuotedMessageID)
+		if err != nil {
+			return result, fmt.Errorf("failed to get quoted message: %v", err)
+		}
+		if quoted == nil {
+			return result, ErrMessageNotFound
+		}
+
+		opts.Quoted = quoted
+		result.QuotedSnippet = snippet(quoted.Content, quotedSnippetLength)
+		result.QuotedSender = quoted.Sender
+	}
+
+	if !s.reconnector.conn.IsConnected() {
+		if err := s.reconnector.reconnect(); err != nil {
+			return result, err
+		}
+		result.Reconnected = true
+	}
+
+	var err error
+	result.MessageID, result.Delivery, err = s.sender.SendMessage(ctx, recipient, message, opts)
+	return result, err
+}
+
+// textSender is the part of the WhatsApp client text messages are sent through
+type textSender interface {
+	SendMessage(ctx context.Context, recipient string, message string, opts models.SendOptions) (string, *models.DeliveryReport, error)
+}
+
+// contactNamer is the part of the WhatsApp client session contacts are looked up through
+type contactNamer interface {
+	ContactName(chatJID string) (string, bool, error)
+}
+
+// knownRecipientName resolves the name of a recipient from the session contacts, falling back
+// to stored chats so groups and people messaged before count as known. It returns
+// ErrUnknownRecipient if the recipient is found in neither.
+func (s *service) knownRecipientName(ctx context.Context, recipient string) (string, error) {
+	chatJID, err := whatsapp.RecipientChatJID(recipient)
+	if err != nil {
+		return "", err
+	}
+
+	name, found, err := s.contacts.ContactName(chatJID)
+	if err != nil {
+		return "", err
+	}
+	if found {
+		return name, nil
+	}
+
+	chat, err := s.db.GetChat(ctx, chatJID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get chat: %v", err)
+	}
+	if chat == nil {
+		return "", ErrUnknownRecipient
+	}
+
+	return chat.Name, nil
+}
+
+// quotedSnippetLength is the number of characters of a quoted message echoed in send results
+const quotedSnippetLength = 100
+
+// snippet shortens content to at most n characters, marking the cut with an ellipsis
+func snippet(content string, n int) string {
+	runes := []rune(content)
+	if len(runes) <= n {
+		return content
+	}
+	return strings.TrimSpace(string(runes[:n])) + "…"
+}
+
+// SendVideo sends an MP4 video to the specified recipient, optionally as a GIF, reconnecting
+// first if the connection dropped
+func (s *service) SendVideo(ctx context.Context, recipient string, path string, caption string, asGIF bool) (models.SendResult, error) {
+	var result models.SendResult
+
+	if !s.whatsapp.IsConnected() {
+		if err := s.reconnector.reconnect(); err != nil {
+			return result, err
+		}
+		result.Reconnected = true
+	}
+
+	var err error
+	result.MessageID, result.Delivery, err = s.whatsapp.SendVideo(ctx, recipient, path, caption, asGIF)
+	return result, err
+}
+
+// SendBroadcast sends a message to each recipient as a direct message and records the
+// sent messages under a broadcast pseudo-chat
+func (s *service) SendBroadcast(ctx context.Context, recipients []string, message string) (models.BroadcastResult, error) {
+	recipients = uniqueRecipients(recipients)
+	if len(recipients) == 0 {
+		return models.BroadcastResult{}, errors.New("at least one recipient is required")
+	}
+	if len(recipients) > maxBroadcastRecipients {
+		return models.BroadcastResult{}, fmt.Errorf("a broadcast can have at most %d recipients", maxBroadcastRecipients)
+	}
+
+	if !s.whatsapp.IsConnected() {
+		if err := s.reconnector.reconnect(); err != nil {
+			return models.BroadcastResult{}, err
+		}
+	}
+
+	chatJID, recipientResults := s.whatsapp.SendBroadcast(ctx, recipients, message)
+	return broadcastResult(chatJID, recipientResults), nil
+}
+
+// broadcastResult totals the outcome of a broadcast from the result of each recipient
+func broadcastResult(chatJID string, recipients []models.RecipientResult) models.BroadcastResult {
+	result := models.BroadcastResult{ChatJID: chatJID, Recipients: recipients}
+	for _, r := range recipients {
+		if r.Status != "sent" {
+			result.Failed++
+			continue
+		}
+		result.Sent++
+	}
+	return result
+}
+
+// uniqueRecipients drops blank and repeated recipients, keeping the first occurrence
+func uniqueRecipients(recipients []string) []string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, r := range recipients {
+		r = strings.TrimSpace(r)
+		if r == "" || seen[r] {
+			continue
+		}
+		seen[r] = true
+		unique = append(unique, r)
+	}
+	return unique
 }
 
-// SendMessage sends a message to the specified recipient
-func (s *service) SendMessage(ctx context.Context, recipient string, message string) error {
-	return s.whatsapp.SendMessage(ctx, recipient, message)
+// SendReaction reacts to a stored message with the given emoji
+func (s *service) SendReaction(ctx context.Context, chatJID string, messageID string, emoji string) error {
+	msg, err := s.db.GetMessage(ctx, chatJID, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to get message: %v", err)
+	}
+	if msg == nil {
+		return fmt.Errorf("message %s not found in chat %s", messageID, chatJID)
+	}
+
+	sender := msg.Sender
+	if msg.IsFromMe {
+		sender = ""
+	}
+
+	return s.whatsapp.SendReaction(ctx, chatJID, sender, messageID, emoji)
 }
 
 // GetChats retrieves all available chats
@@ -106,11 +495,299 @@ func (s *service) GetChats(ctx context.Context) ([]models.Chat, error) {
 	return chats, nil
 }
 
+// GetContacts retrieves all contacts known to the WhatsApp session
+func (s *service) GetContacts(ctx context.Context) ([]models.Contact, error) {
+	return s.whatsapp.GetContacts()
+}
+
+// GetCounts counts contacts, direct and group chats, and stored messages
+func (s *service) GetCounts(ctx context.Context) (models.Counts, error) {
+	counts, err := s.db.CountChatsAndMessages(ctx)
+	if err != nil {
+		return counts, fmt.Errorf("failed to count chats and messages: %v", err)
+	}
+
+	counts.Contacts, err = s.whatsapp.CountContacts()
+	if err != nil {
+		return counts, err
+	}
+
+	return counts, nil
+}
+
 // GetMessages retrieves messages from a specific chat with the given limit
 func (s *service) GetMessages(ctx context.Context, chatJID string, limit int) ([]models.Message, error) {
 	return s.db.GetMessages(ctx, chatJID, limit)
 }
 
+// RetryMediaDownload attempts to download media again for messages that are missing their file
+func (s *service) RetryMediaDownload(ctx context.Context, chatJID string, limit int) ([]models.MediaDownloadResult, error) {
+	messages, err := s.db.GetMessagesMissingMedia(ctx, chatJID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages missing media: %v", err)
+	}
+
+	results := make([]models.MediaDownloadResult, 0, len(messages))
+	for _, msg := range messages {
+		result := models.MediaDownloadResult{
+			MessageID: msg.ID,
+			ChatJID:   msg.ChatJID,
+		}
+
+		path, err := s.whatsapp.DownloadMedia(msg)
+		switch {
+		case errors.Is(err, whatsapp.ErrMediaExpired):
+			result.Status = "expired"
+			result.Error = err.Error()
+		case err != nil:
+			result.Status = "failed"
+			result.Error = err.Error()
+		default:
+			if err := s.db.UpdateMediaPath(ctx, msg.ChatJID, msg.ID, path); err != nil {
+				result.Status = "failed"
+				result.Error = fmt.Sprintf("failed to update media path: %v", err)
+			} else {
+				result.Status = "downloaded"
+				result.MediaPath = path
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// mediaFiles is the part of the WhatsApp client downloaded media files are managed through
+type mediaFiles interface {
+	MediaDir() string
+	DeleteMedia(path string) (bool, error)
+}
+
+// DeleteMedia removes the downloaded media file of a message and clears its media path,
+// keeping the message itself. A file that is already gone is not an error.
+func (s *service) DeleteMedia(ctx context.Context, chatJID string, messageID string) (models.MediaDeleteResult, error) {
+	result := models.MediaDeleteResult{
+		MessageID: messageID,
+		ChatJID:   chatJID,
+	}
+
+	msg, err := s.db.GetMessage(ctx, chatJID, messageID)
+	if err != nil {
+		return result, fmt.Errorf("failed to get message: %v", err)
+	}
+	if msg == nil {
+		return result, ErrMessageNotFound
+	}
+	if msg.MediaPath == "" {
+		return result, nil
+	}
+
+	result.Deleted, err = s.media.DeleteMedia(msg.MediaPath)
+	if err != nil {
+		return result, err
+	}
+
+	if err := s.db.UpdateMediaPath(ctx, chatJID, messageID, ""); err != nil {
+		return result, fmt.Errorf("failed to update media path: %v", err)
+	}
+
+	return result, nil
+}
+
+// chatNamer is the part of the WhatsApp client current chat names are fetched through
+type chatNamer interface {
+	GetChatName(chatJID string) (string, error)
+}
+
+// ResyncChat refreshes the stored name of a chat from WhatsApp. It returns
+// ErrChatNotFound if the chat is unknown locally or on WhatsApp.
+func (s *service) ResyncChat(ctx context.Context, chatJID string) (*models.Chat, error) {
+	chat, err := s.db.GetChat(ctx, chatJID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat: %v", err)
+	}
+	if chat == nil {
+		return nil, ErrChatNotFound
+	}
+
+	name, err := s.chatNames.GetChatName(chatJID)
+	if errors.Is(err, whatsapp.ErrChatNotFound) {
+		return nil, ErrChatNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if name != "" && name != chat.Name {
+		chat.Name = name
+		if err := s.db.StoreChat(ctx, *chat); err != nil {
+			return nil, fmt.Errorf("failed to store chat: %v", err)
+		}
+	}
+
+	return chat, nil
+}
+
+// MarkChatRead sends read receipts for every unread received message of a chat and flags them
+// read locally. Receipts are grouped by sender, as WhatsApp requires, and sent in batches of
+// markReadBatchSize. It returns ErrChatNotFound if the chat is unknown.
+func (s *service) MarkChatRead(ctx context.Context, chatJID string) (models.ChatReadResult, error) {
+	result := models.ChatReadResult{ChatJID: chatJID}
+
+	chat, err := s.db.GetChat(ctx, chatJID)
+	if err != nil {
+		return result, fmt.Errorf("failed to get chat: %v", err)
+	}
+	if chat == nil {
+		return result, ErrChatNotFound
+	}
+
+	unread, err := s.db.GetUnreadMessages(ctx, chatJID)
+	if err != nil {
+		return result, fmt.Errorf("failed to get unread messages: %v", err)
+	}
+
+	for _, batch := range readBatches(unread, markReadBatchSize) {
+		if err := s.reader.MarkRead(chatJID, batch.sender, batch.ids); err != nil {
+			return result, err
+		}
+		if err := s.db.MarkMessagesRead(ctx, chatJID, batch.ids); err != nil {
+			return result, fmt.Errorf("failed to mark messages read: %v", err)
+		}
+		result.Marked += len(batch.ids)
+		result.Batches++
+	}
+
+	return result, nil
+}
+
+// readMarker is the part of the WhatsApp client read receipts are sent through
+type readMarker interface {
+	MarkRead(chatJID string, sender string, ids []string) error
+}
+
+// readBatch is a group of messages from one sender acknowledged by a single read receipt
+type readBatch struct {
+	sender string
+	ids    []string
+}
+
+// readBatches groups messages by sender, in order of each sender's first message,
+// and splits each group into batches of at most size messages
+func readBatches(messages []models.Message, size int) []readBatch {
+	var senders []string
+	bySender := map[string][]string{}
+	for _, msg := range messages {
+		if _, ok := bySender[msg.Sender]; !ok {
+			senders = append(senders, msg.Sender)
+		}
+		bySender[msg.Sender] = append(bySender[msg.Sender], msg.ID)
+	}
+
+	var batches []readBatch
+	for _, sender := range senders {
+		ids := bySender[sender]
+		for len(ids) > 0 {
+			n := min(size, len(ids))
+			batches = append(batches, readBatch{sender: sender, ids: ids[:n]})
+			ids = ids[n:]
+		}
+	}
+	return batches
+}
+
+// GetLabels retrieves all labels
+func (s *service) GetLabels(ctx context.Context) ([]models.Label, error) {
+	return s.db.GetLabels(ctx)
+}
+
+// labeler is the part of the WhatsApp client labels are synced through
+type labeler interface {
+	IsBusiness() bool
+	EditLabel(label models.Label) error
+	LabelChat(chatJID string, labelID string, assigned bool) error
+}
+
+// CreateLabel creates a label, synced to WhatsApp for business accounts and local-only otherwise
+func (s *service) CreateLabel(ctx context.Context, name string, color int32) (models.Label, error) {
+	id, err := s.db.NextLabelID(ctx)
+	if err != nil {
+		return models.Label{}, fmt.Errorf("failed to allocate label ID: %v", err)
+	}
+
+	label := models.Label{
+		ID:     id,
+		Name:   name,
+		Color:  color,
+		Synced: s.labeler.IsBusiness(),
+	}
+
+	if label.Synced {
+		if err := s.labeler.EditLabel(label); err != nil {
+			return models.Label{}, err
+		}
+	}
+
+	if err := s.db.StoreLabel(ctx, label); err != nil {
+		return models.Label{}, fmt.Errorf("failed to store label: %v", err)
+	}
+
+	return label, nil
+}
+
+// AssignLabel assigns a label to a chat or removes it, syncing to WhatsApp if the label is synced
+func (s *service) AssignLabel(ctx context.Context, chatJID string, labelID string, assigned bool) error {
+	labels, err := s.db.GetLabels(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get labels: %v", err)
+	}
+
+	idx := slices.IndexFunc(labels, func(l models.Label) bool { return l.ID == labelID })
+	if idx < 0 {
+		return fmt.Errorf("label %s not found", labelID)
+	}
+
+	if labels[idx].Synced && s.labeler.IsBusiness() {
+		if err := s.labeler.LabelChat(chatJID, labelID, assigned); err != nil {
+			return err
+		}
+	}
+
+	if err := s.db.SetChatLabel(ctx, chatJID, labelID, assigned); err != nil {
+		return fmt.Errorf("failed to store chat label: %v", err)
+	}
+
+	return nil
+}
+
+func (s *service) storeLabelUpdate(ctx context.Context, update models.LabelUpdate) error {
+	if update.Label == nil {
+		return s.db.SetChatLabel(ctx, update.ChatJID, update.LabelID, update.Assigned)
+	}
+
+	if update.Deleted {
+		return s.db.DeleteLabel(ctx, update.Label.ID)
+	}
+
+	return s.db.StoreLabel(ctx, *update.Label)
+}
+
+func (s *service) storeGroupUpdate(ctx context.Context, update models.GroupUpdate) error {
+	if update.Participants != nil {
+		return s.db.SetGroupParticipants(ctx, update.GroupJID, update.Participants)
+	}
+
+	return s.db.UpdateGroupParticipants(ctx, update.GroupJID, update.Joined, update.Left)
+}
+
+// Close flushes any buffered messages and stops storing new ones
+func (s *service) Close() error {
+	close(s.done)
+	<-s.stopped
+	return nil
+}
+
 // IsConnected checks if the WhatsApp client is connected
 func (s *service) IsConnected() bool {
 	return s.whatsapp.IsConnected()
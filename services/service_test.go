@@ -0,0 +1,630 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+	"github.com/mbenaiss/whatsapp-mcp/whatsapp"
+)
+
+func TestReadBatches(t *testing.T) {
+	const (
+		alice = "15550002222@s.whatsapp.net"
+		bob   = "15550003333@s.whatsapp.net"
+	)
+
+	// messages returns n messages, alternating between the given senders
+	messages := func(n int, senders ...string) []models.Message {
+		msgs := make([]models.Message, n)
+		for i := range msgs {
+			msgs[i] = models.Message{ID: fmt.Sprintf("M%03d", i), Sender: senders[i%len(senders)]}
+		}
+		return msgs
+	}
+
+	type batch struct {
+		sender string
+		size   int
+	}
+
+	tests := []struct {
+		name     string
+		messages []models.Message
+		want     []batch
+	}{
+		{
+			name: "no messages",
+		},
+		{
+			name:     "one message",
+			messages: messages(1, alice),
+			want:     []batch{{alice, 1}},
+		},
+		{
+			name:     "exactly one batch",
+			messages: messages(50, alice),
+			want:     []batch{{alice, 50}},
+		},
+		{
+			name:     "one over a batch",
+			messages: messages(51, alice),
+			want:     []batch{{alice, 50}, {alice, 1}},
+		},
+		{
+			name:     "several batches",
+			messages: messages(120, alice),
+			want:     []batch{{alice, 50}, {alice, 50}, {alice, 20}},
+		},
+		{
+			name:     "interleaved senders",
+			messages: messages(120, bob, alice),
+			want:     []batch{{bob, 50}, {bob, 10}, {alice, 50}, {alice, 10}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batches := readBatches(tt.messages, markReadBatchSize)
+
+			got := make([]batch, 0, len(batches))
+			var ids []string
+			for _, b := range batches {
+				got = append(got, batch{b.sender, len(b.ids)})
+				ids = append(ids, b.ids...)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("batches = %v, want %v", got, tt.want)
+			}
+
+			// Every message is acknowledged exactly once
+			want := make([]string, 0, len(tt.messages))
+			for _, msg := range tt.messages {
+				want = append(want, msg.ID)
+			}
+			slices.Sort(ids)
+			if !slices.Equal(ids, want) {
+				t.Errorf("acknowledged %v, want %v", ids, want)
+			}
+		})
+	}
+}
+
+func TestBroadcastResult(t *testing.T) {
+	const chatJID = "1741597200000@broadcast"
+
+	sent := func(recipient string) models.RecipientResult {
+		return models.RecipientResult{Recipient: recipient, JID: recipient + "@s.whatsapp.net", Status: "sent", MessageID: "3EB0" + recipient}
+	}
+	failed := func(recipient string) models.RecipientResult {
+		return models.RecipientResult{Recipient: recipient, Status: "failed", Error: "failed to send message"}
+	}
+
+	tests := []struct {
+		name       string
+		recipients []models.RecipientResult
+		wantSent   int
+		wantFailed int
+	}{
+		{
+			name:       "all sent",
+			recipients: []models.RecipientResult{sent("15550002222"), sent("15550003333")},
+			wantSent:   2,
+		},
+		{
+			name:       "all failed",
+			recipients: []models.RecipientResult{failed("15550002222"), failed("15550003333")},
+			wantFailed: 2,
+		},
+		{
+			name:       "mixed",
+			recipients: []models.RecipientResult{sent("15550002222"), failed("15550003333"), sent("15550004444")},
+			wantSent:   2,
+			wantFailed: 1,
+		},
+		{
+			name:       "unknown status counts as failed",
+			recipients: []models.RecipientResult{{Recipient: "15550002222"}},
+			wantFailed: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := broadcastResult(chatJID, tt.recipients)
+
+			if result.ChatJID != chatJID {
+				t.Errorf("chat JID = %q, want %q", result.ChatJID, chatJID)
+			}
+			if result.Sent != tt.wantSent || result.Failed != tt.wantFailed {
+				t.Errorf("sent %d, failed %d, want sent %d, failed %d", result.Sent, result.Failed, tt.wantSent, tt.wantFailed)
+			}
+			// Each recipient keeps its own result, in order
+			if !slices.Equal(result.Recipients, tt.recipients) {
+				t.Errorf("recipients = %+v, want %+v", result.Recipients, tt.recipients)
+			}
+		})
+	}
+}
+
+// fakeReader records the read receipts sent, failing from the call numbered failAt (1-based)
+type fakeReader struct {
+	calls  []readBatch
+	failAt int
+}
+
+func (r *fakeReader) MarkRead(chatJID string, sender string, ids []string) error {
+	if r.failAt > 0 && len(r.calls)+1 >= r.failAt {
+		return errors.New("connection lost")
+	}
+	r.calls = append(r.calls, readBatch{sender: sender, ids: ids})
+	return nil
+}
+
+func TestMarkChatRead(t *testing.T) {
+	const (
+		chatJID = "120363000000000000@g.us"
+		alice   = "15550002222@s.whatsapp.net"
+		bob     = "15550003333@s.whatsapp.net"
+	)
+
+	// unread holds the received messages of the chat: 70 from Alice then 30 from Bob,
+	// followed by one of our own that is never acknowledged
+	chat := models.Chat{JID: chatJID, Name: "Team", LastMessageTime: time.Now()}
+	start := time.Now().Add(-time.Hour)
+	for i := range 100 {
+		sender := alice
+		if i >= 70 {
+			sender = bob
+		}
+		chat.Messages = append(chat.Messages, models.Message{
+			ID:        fmt.Sprintf("M%03d", i),
+			ChatJID:   chatJID,
+			Sender:    sender,
+			Content:   fmt.Sprintf("message %d", i),
+			Timestamp: start.Add(time.Duration(i) * time.Second),
+		})
+	}
+	chat.Messages = append(chat.Messages, models.Message{
+		ID: "M100", ChatJID: chatJID, Content: "reply", Timestamp: start.Add(time.Minute), IsFromMe: true,
+	})
+
+	type batch struct {
+		sender string
+		size   int
+	}
+
+	tests := []struct {
+		name        string
+		chat        *models.Chat
+		failAt      int
+		wantErr     bool
+		wantBatches []batch
+		wantUnread  int
+	}{
+		{
+			name:        "many unread messages",
+			chat:        &chat,
+			wantBatches: []batch{{alice, 50}, {alice, 20}, {bob, 30}},
+		},
+		{
+			name:        "receipt failure keeps the rest unread",
+			chat:        &chat,
+			failAt:      2,
+			wantErr:     true,
+			wantBatches: []batch{{alice, 50}},
+			wantUnread:  50,
+		},
+		{
+			name: "no unread messages",
+			chat: &models.Chat{JID: chatJID, Name: "Team", LastMessageTime: time.Now()},
+		},
+		{
+			name:    "unknown chat",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, _ := newTestService(t, Options{})
+			reader := &fakeReader{failAt: tt.failAt}
+			s.reader = reader
+			ctx := context.Background()
+
+			if tt.chat != nil {
+				if err := s.db.StoreBatch(ctx, []models.Chat{*tt.chat}); err != nil {
+					t.Fatalf("StoreBatch: %v", err)
+				}
+			}
+
+			result, err := s.MarkChatRead(ctx, chatJID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MarkChatRead error = %v, want error %v", err, tt.wantErr)
+			}
+
+			got := make([]batch, 0, len(reader.calls))
+			marked := 0
+			for _, call := range reader.calls {
+				got = append(got, batch{call.sender, len(call.ids)})
+				marked += len(call.ids)
+			}
+			if !slices.Equal(got, tt.wantBatches) {
+				t.Errorf("read receipts = %v, want %v", got, tt.wantBatches)
+			}
+			if result.Batches != len(tt.wantBatches) || result.Marked != marked {
+				t.Errorf("result = %d messages in %d batches, want %d in %d", result.Marked, result.Batches, marked, len(tt.wantBatches))
+			}
+
+			unread, err := s.db.GetUnreadMessages(ctx, chatJID)
+			if err != nil {
+				t.Fatalf("GetUnreadMessages: %v", err)
+			}
+			if len(unread) != tt.wantUnread {
+				t.Errorf("%d messages left unread, want %d", len(unread), tt.wantUnread)
+			}
+		})
+	}
+}
+
+// fakeContacts holds the session contacts by JID
+type fakeContacts map[string]string
+
+func (c fakeContacts) ContactName(chatJID string) (string, bool, error) {
+	name, found := c[chatJID]
+	return name, found, nil
+}
+
+func TestRequireKnownContact(t *testing.T) {
+	const (
+		contact  = "15550002222@s.whatsapp.net"
+		chatOnly = "15550003333@s.whatsapp.net"
+		stranger = "15550004444@s.whatsapp.net"
+	)
+
+	s, _, _ := newTestService(t, Options{})
+	s.contacts = fakeContacts{contact: "Alice"}
+	ctx := context.Background()
+
+	// Bob is not a contact but was messaged before
+	if err := s.db.StoreChat(ctx, models.Chat{JID: chatOnly, Name: "Bob", LastMessageTime: time.Now()}); err != nil {
+		t.Fatalf("StoreChat: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		recipient string
+		wantName  string
+		wantErr   error
+	}{
+		{name: "contact", recipient: contact, wantName: "Alice"},
+		{name: "contact by phone number", recipient: "15550002222", wantName: "Alice"},
+		{name: "known chat", recipient: chatOnly, wantName: "Bob"},
+		{name: "unknown", recipient: stranger, wantErr: ErrUnknownRecipient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, err := s.knownRecipientName(ctx, tt.recipient)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("knownRecipientName error = %v, want %v", err, tt.wantErr)
+			}
+			if name != tt.wantName {
+				t.Errorf("name = %q, want %q", name, tt.wantName)
+			}
+		})
+	}
+
+	// The send is refused before anything reaches WhatsApp
+	_, err := s.SendMessage(ctx, stranger, "hi", models.SendOptions{RequireKnownContact: true})
+	if !errors.Is(err, ErrUnknownRecipient) {
+		t.Errorf("SendMessage error = %v, want %v", err, ErrUnknownRecipient)
+	}
+}
+
+func TestSendMessageCustomID(t *testing.T) {
+	const recipient = "15550002222@s.whatsapp.net"
+
+	s, chats, _ := newTestService(t, Options{})
+	chats <- testChat(recipient, "3EB0C0FFEE0123456789")
+	chats <- models.Chat{JID: "15550009999@s.whatsapp.net"}
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		recipient string
+		messageID string
+		wantErr   error
+	}{
+		{name: "duplicate", recipient: recipient, messageID: "3EB0C0FFEE0123456789", wantErr: ErrDuplicateMessageID},
+		{name: "duplicate by phone number", recipient: "15550002222", messageID: "3EB0C0FFEE0123456789", wantErr: ErrDuplicateMessageID},
+		{name: "lowercase", recipient: recipient, messageID: "3eb0c0ffee0123456789", wantErr: ErrInvalidMessageID},
+		{name: "too short", recipient: recipient, messageID: "3EB0", wantErr: ErrInvalidMessageID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.SendMessage(ctx, tt.recipient, "hello again", models.SendOptions{MessageID: tt.messageID})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("SendMessage error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fakeLabeler records the label changes synced to WhatsApp
+type fakeLabeler struct {
+	business bool
+	edited   []string
+	assigned []string
+}
+
+func (l *fakeLabeler) IsBusiness() bool {
+	return l.business
+}
+
+func (l *fakeLabeler) EditLabel(label models.Label) error {
+	l.edited = append(l.edited, label.ID)
+	return nil
+}
+
+func (l *fakeLabeler) LabelChat(chatJID string, labelID string, assigned bool) error {
+	l.assigned = append(l.assigned, fmt.Sprintf("%s %s %v", chatJID, labelID, assigned))
+	return nil
+}
+
+func TestLabels(t *testing.T) {
+	const chatJID = "15550002222@s.whatsapp.net"
+
+	for _, business := range []bool{false, true} {
+		t.Run(fmt.Sprintf("business %v", business), func(t *testing.T) {
+			s, _, _ := newTestService(t, Options{})
+			labeler := &fakeLabeler{business: business}
+			s.labeler = labeler
+			ctx := context.Background()
+
+			customers, err := s.CreateLabel(ctx, "Customers", 1)
+			if err != nil {
+				t.Fatalf("CreateLabel: %v", err)
+			}
+			unpaid, err := s.CreateLabel(ctx, "Unpaid", 2)
+			if err != nil {
+				t.Fatalf("CreateLabel: %v", err)
+			}
+			if customers.ID == unpaid.ID {
+				t.Errorf("both labels got ID %s", customers.ID)
+			}
+			if customers.Synced != business || unpaid.Synced != business {
+				t.Errorf("labels synced %v and %v, want %v", customers.Synced, unpaid.Synced, business)
+			}
+
+			labels, err := s.GetLabels(ctx)
+			if err != nil {
+				t.Fatalf("GetLabels: %v", err)
+			}
+			if !slices.Equal(labels, []models.Label{customers, unpaid}) {
+				t.Errorf("labels = %+v, want %+v", labels, []models.Label{customers, unpaid})
+			}
+
+			if err := s.AssignLabel(ctx, chatJID, customers.ID, true); err != nil {
+				t.Fatalf("AssignLabel: %v", err)
+			}
+			if err := s.AssignLabel(ctx, chatJID, "999", true); err == nil {
+				t.Errorf("assigned a label that does not exist")
+			}
+
+			// Only business accounts reach WhatsApp, local labels stay in the database
+			var wantEdited, wantAssigned []string
+			if business {
+				wantEdited = []string{customers.ID, unpaid.ID}
+				wantAssigned = []string{chatJID + " " + customers.ID + " true"}
+			}
+			if !slices.Equal(labeler.edited, wantEdited) || !slices.Equal(labeler.assigned, wantAssigned) {
+				t.Errorf("synced labels %v and assignments %v, want %v and %v", labeler.edited, labeler.assigned, wantEdited, wantAssigned)
+			}
+		})
+	}
+}
+
+// fakeChatNames holds the current chat names on WhatsApp by JID
+type fakeChatNames map[string]string
+
+func (n fakeChatNames) GetChatName(chatJID string) (string, error) {
+	name, ok := n[chatJID]
+	if !ok {
+		return "", whatsapp.ErrChatNotFound
+	}
+	return name, nil
+}
+
+func TestResyncChat(t *testing.T) {
+	const (
+		renamed   = "120363000000000001@g.us"
+		unchanged = "120363000000000002@g.us"
+		nameless  = "15550002222@s.whatsapp.net"
+		left      = "120363000000000003@g.us"
+	)
+
+	s, _, _ := newTestService(t, Options{})
+	s.chatNames = fakeChatNames{renamed: "Lunch crew", unchanged: "Book club", nameless: ""}
+	ctx := context.Background()
+
+	for jid, name := range map[string]string{renamed: "Lunch", unchanged: "Book club", nameless: "Alice", left: "Old group"} {
+		if err := s.db.StoreChat(ctx, models.Chat{JID: jid, Name: name, LastMessageTime: time.Now()}); err != nil {
+			t.Fatalf("StoreChat: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		chatJID  string
+		wantName string
+		wantErr  error
+	}{
+		{name: "renamed group", chatJID: renamed, wantName: "Lunch crew"},
+		{name: "unchanged group", chatJID: unchanged, wantName: "Book club"},
+		{name: "no name on WhatsApp", chatJID: nameless, wantName: "Alice"},
+		{name: "unknown on WhatsApp", chatJID: left, wantErr: ErrChatNotFound},
+		{name: "unknown locally", chatJID: "120363000000000004@g.us", wantErr: ErrChatNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chat, err := s.ResyncChat(ctx, tt.chatJID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ResyncChat error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if chat.Name != tt.wantName {
+				t.Errorf("returned name = %q, want %q", chat.Name, tt.wantName)
+			}
+
+			stored, err := s.db.GetChat(ctx, tt.chatJID)
+			if err != nil {
+				t.Fatalf("GetChat: %v", err)
+			}
+			if stored.Name != tt.wantName {
+				t.Errorf("stored name = %q, want %q", stored.Name, tt.wantName)
+			}
+		})
+	}
+}
+
+// fakeMedia keeps media in dir and records the media files deleted
+type fakeMedia struct {
+	dir     string
+	deleted []string
+}
+
+func (m *fakeMedia) MediaDir() string {
+	return m.dir
+}
+
+func (m *fakeMedia) DeleteMedia(path string) (bool, error) {
+	m.deleted = append(m.deleted, path)
+	return true, nil
+}
+
+func TestDeleteMedia(t *testing.T) {
+	const chatJID = "15550002222@s.whatsapp.net"
+
+	s, _, _ := newTestService(t, Options{})
+	media := &fakeMedia{}
+	s.media = media
+	ctx := context.Background()
+
+	chat := testChat(chatJID, "PHOTO", "TEXT")
+	chat.Messages[0].MediaType = "image"
+	chat.Messages[0].MediaPath = "/store/media/15550002222@s.whatsapp.net/PHOTO.jpg"
+	chat.Messages[0].DirectPath = "/v/t62.7118-24/photo.enc"
+	if err := s.db.StoreBatch(ctx, []models.Chat{chat}); err != nil {
+		t.Fatalf("StoreBatch: %v", err)
+	}
+
+	result, err := s.DeleteMedia(ctx, chatJID, "PHOTO")
+	if err != nil {
+		t.Fatalf("DeleteMedia: %v", err)
+	}
+	if !result.Deleted || !slices.Equal(media.deleted, []string{chat.Messages[0].MediaPath}) {
+		t.Errorf("result = %+v after deleting %v, want the photo deleted", result, media.deleted)
+	}
+
+	// The message stays, only its file is gone
+	msg, err := s.db.GetMessage(ctx, chatJID, "PHOTO")
+	if err != nil {
+		t.Fatalf("GetMessage: %v", err)
+	}
+	if msg == nil || msg.Content != "message PHOTO" || msg.MediaType != "image" || msg.MediaPath != "" {
+		t.Errorf("message = %+v, want it kept without a media path", msg)
+	}
+	missing, err := s.db.GetMessagesMissingMedia(ctx, chatJID, 10)
+	if err != nil {
+		t.Fatalf("GetMessagesMissingMedia: %v", err)
+	}
+	if len(missing) != 1 || missing[0].ID != "PHOTO" {
+		t.Errorf("messages missing media = %v, want PHOTO with a NULL media path", missing)
+	}
+
+	// Messages without a file have nothing to delete
+	result, err = s.DeleteMedia(ctx, chatJID, "TEXT")
+	if err != nil || result.Deleted || len(media.deleted) != 1 {
+		t.Errorf("DeleteMedia(TEXT) = %+v, %v, want nothing deleted", result, err)
+	}
+
+	if _, err := s.DeleteMedia(ctx, chatJID, "GONE"); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("DeleteMedia error = %v, want %v", err, ErrMessageNotFound)
+	}
+}
+
+// fakeSender records the options of the messages sent
+type fakeSender struct {
+	sent []models.SendOptions
+}
+
+func (f *fakeSender) SendMessage(ctx context.Context, recipient string, message string, opts models.SendOptions) (string, *models.DeliveryReport, error) {
+	f.sent = append(f.sent, opts)
+	return fmt.Sprintf("3EB0%016X", len(f.sent)), nil, nil
+}
+
+func TestSendMessageQuotedSnippet(t *testing.T) {
+	const chatJID = "15550002222@s.whatsapp.net"
+	long := strings.Repeat("very long message ", 10)
+
+	s, _, _ := newTestService(t, Options{})
+	s.reconnector.conn = &fakeConnection{connected: true}
+	sender := &fakeSender{}
+	s.sender = sender
+	ctx := context.Background()
+
+	chat := testChat(chatJID, "SHORT", "LONG")
+	chat.Messages[0].Content = "lunch at noon?"
+	chat.Messages[1].Content = long
+	if err := s.db.StoreBatch(ctx, []models.Chat{chat}); err != nil {
+		t.Fatalf("StoreBatch: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		quotedID    string
+		wantSnippet string
+		wantErr     error
+	}{
+		{name: "short", quotedID: "SHORT", wantSnippet: "lunch at noon?"},
+		{name: "long", quotedID: "LONG", wantSnippet: strings.TrimSpace(long[:quotedSnippetLength]) + "…"},
+		{name: "unknown", quotedID: "GONE", wantErr: ErrMessageNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender.sent = nil
+			result, err := s.SendMessage(ctx, chatJID, "yes", models.SendOptions{QuotedMessageID: tt.quotedID})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SendMessage error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(sender.sent) != 0 {
+					t.Errorf("sent a reply to a message that does not exist")
+				}
+				return
+			}
+
+			if result.QuotedSnippet != tt.wantSnippet || result.QuotedSender != chatJID {
+				t.Errorf("quoted %q from %s, want %q from %s", result.QuotedSnippet, result.QuotedSender, tt.wantSnippet, chatJID)
+			}
+			// The snippet comes from the same message the reply quotes
+			if len(sender.sent) != 1 || sender.sent[0].Quoted == nil || sender.sent[0].Quoted.ID != tt.quotedID {
+				t.Fatalf("sent %+v, want one reply quoting %s", sender.sent, tt.quotedID)
+			}
+			if !strings.HasPrefix(sender.sent[0].Quoted.Content, strings.TrimSuffix(result.QuotedSnippet, "…")) {
+				t.Errorf("snippet %q does not match the quoted content %q", result.QuotedSnippet, sender.sent[0].Quoted.Content)
+			}
+		})
+	}
+}
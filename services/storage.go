@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+)
+
+// GetStorageUsage reports the disk space used by the databases and downloaded media
+func (s *service) GetStorageUsage(ctx context.Context) (models.StorageUsage, error) {
+	var usage models.StorageUsage
+	var err error
+
+	usage.MessagesDBBytes, err = databaseSize(filepath.Join(s.opts.StoreDir, "messages.db"))
+	if err != nil {
+		return usage, err
+	}
+
+	usage.SessionDBBytes, err = databaseSize(filepath.Join(s.opts.StoreDir, "whatsapp.db"))
+	if err != nil {
+		return usage, err
+	}
+
+	usage.MediaBytes, usage.MediaFiles, err = directorySize(s.media.MediaDir())
+	if err != nil {
+		return usage, err
+	}
+
+	usage.TotalBytes = usage.MessagesDBBytes + usage.SessionDBBytes + usage.MediaBytes
+
+	usage.Messages, usage.MediaMessages, usage.DownloadedMediaMessages, err = s.db.CountMessages(ctx)
+	if err != nil {
+		return usage, fmt.Errorf("failed to count messages: %v", err)
+	}
+
+	return usage, nil
+}
+
+// databaseSize returns the size of a SQLite database including its WAL and shared memory files
+func databaseSize(path string) (int64, error) {
+	var size int64
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		info, err := os.Stat(path + suffix)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to stat %s: %v", path+suffix, err)
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
+
+// directorySize walks a directory and returns the total size and number of regular files in it.
+// A missing directory is empty.
+func directorySize(dir string) (int64, int, error) {
+	var size int64
+	var files int
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == dir {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		files++
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to walk %s: %v", dir, err)
+	}
+
+	return size, files, nil
+}
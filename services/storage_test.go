@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+)
+
+// writeFile creates a file of size bytes, along with its directory
+func writeFile(t *testing.T, path string, size int) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+func TestGetStorageUsage(t *testing.T) {
+	const chatJID = "15550002222@s.whatsapp.net"
+
+	storeDir := t.TempDir()
+	writeFile(t, filepath.Join(storeDir, "messages.db"), 4096)
+	writeFile(t, filepath.Join(storeDir, "messages.db-wal"), 1024)
+	writeFile(t, filepath.Join(storeDir, "whatsapp.db"), 2048)
+
+	mediaDir := filepath.Join(storeDir, "media")
+	writeFile(t, filepath.Join(mediaDir, chatJID, "PHOTO.jpg"), 300)
+	writeFile(t, filepath.Join(mediaDir, chatJID, "VIDEO.mp4"), 700)
+	writeFile(t, filepath.Join(mediaDir, "120363000000000000@g.us", "VOICE.ogg"), 50)
+	if err := os.Symlink(filepath.Join(mediaDir, chatJID, "PHOTO.jpg"), filepath.Join(mediaDir, "link.jpg")); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+
+	s, _, _ := newTestService(t, Options{StoreDir: storeDir})
+	s.media = &fakeMedia{dir: mediaDir}
+	ctx := context.Background()
+
+	chat := testChat(chatJID, "PHOTO", "VIDEO", "TEXT")
+	chat.Messages[0].MediaType = "image"
+	chat.Messages[0].MediaPath = filepath.Join(mediaDir, chatJID, "PHOTO.jpg")
+	chat.Messages[1].MediaType = "video"
+	if err := s.db.StoreBatch(ctx, []models.Chat{chat}); err != nil {
+		t.Fatalf("StoreBatch: %v", err)
+	}
+
+	usage, err := s.GetStorageUsage(ctx)
+	if err != nil {
+		t.Fatalf("GetStorageUsage: %v", err)
+	}
+
+	// The symlink is not counted as a file of its own
+	want := models.StorageUsage{
+		MessagesDBBytes:         5120,
+		SessionDBBytes:          2048,
+		MediaBytes:              1050,
+		MediaFiles:              3,
+		TotalBytes:              8218,
+		Messages:                3,
+		MediaMessages:           2,
+		DownloadedMediaMessages: 1,
+	}
+	if usage != want {
+		t.Errorf("usage = %+v, want %+v", usage, want)
+	}
+
+	// Before anything is downloaded the media directory may not exist
+	s.media = &fakeMedia{dir: filepath.Join(storeDir, "missing")}
+	usage, err = s.GetStorageUsage(ctx)
+	if err != nil {
+		t.Fatalf("GetStorageUsage: %v", err)
+	}
+	if usage.MediaBytes != 0 || usage.MediaFiles != 0 || usage.TotalBytes != 7168 {
+		t.Errorf("usage = %+v, want no media", usage)
+	}
+}
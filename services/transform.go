@@ -0,0 +1,72 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+	"golang.org/x/text/unicode/norm"
+)
+
+// transform rewrites message content before it is stored
+type transform func(string) string
+
+// transforms returns the content transformations enabled in the options, in the order they apply
+func (o Options) transforms() []transform {
+	var transforms []transform
+	if o.StripZeroWidth {
+		transforms = append(transforms, stripZeroWidth)
+	}
+	if o.NormalizeUnicode {
+		transforms = append(transforms, normalizeUnicode)
+	}
+	if o.TrimWhitespace {
+		transforms = append(transforms, trimWhitespace)
+	}
+	return transforms
+}
+
+// transformChat applies the enabled transformations to the content of every message in the chat
+func (s *service) transformChat(chat models.Chat) models.Chat {
+	if len(s.transforms) == 0 || len(chat.Messages) == 0 {
+		return chat
+	}
+
+	messages := make([]models.Message, len(chat.Messages))
+	for i, msg := range chat.Messages {
+		for _, t := range s.transforms {
+			msg.Content = t(msg.Content)
+		}
+		messages[i] = msg
+	}
+	chat.Messages = messages
+
+	return chat
+}
+
+// trimWhitespace removes leading and trailing whitespace
+func trimWhitespace(content string) string {
+	return strings.TrimSpace(content)
+}
+
+// normalizeUnicode converts content to NFC so visually identical text compares equal
+func normalizeUnicode(content string) string {
+	return norm.NFC.String(content)
+}
+
+// stripZeroWidth removes invisible characters used to defeat text matching. The zero
+// width joiner is kept since it is part of emoji sequences.
+func stripZeroWidth(content string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '\u200b', // zero width space
+			'\u200c', // zero width non-joiner
+			'\u200e', // left-to-right mark
+			'\u200f', // right-to-left mark
+			'\u2060', // word joiner
+			'\u180e', // mongolian vowel separator
+			'\ufeff': // zero width no-break space
+			return -1
+		}
+		return r
+	}, content)
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+)
+
+func TestNormalizeUnicode(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "decomposed accent", content: "cafe\u0301", want: "caf\u00e9"},
+		{name: "already composed", content: "caf\u00e9", want: "caf\u00e9"},
+		{name: "hangul jamo", content: "\u1112\u1161\u11ab", want: "\ud55c"},
+		{name: "ascii", content: "hello", want: "hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeUnicode(tt.content); got != tt.want {
+				t.Errorf("normalizeUnicode(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripZeroWidth(t *testing.T) {
+	const family = "\U0001F468\u200d\U0001F469\u200d\U0001F467"
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "zero width space", content: "free\u200bmoney", want: "freemoney"},
+		{name: "every invisible character", content: "\ufeffa\u200cb\u200ec\u200fd\u2060e\u180ef", want: "abcdef"},
+		{name: "emoji joiner kept", content: family, want: family},
+		{name: "plain text", content: "hello", want: "hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripZeroWidth(tt.content); got != tt.want {
+				t.Errorf("stripZeroWidth(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformChat(t *testing.T) {
+	const content = "  cafe\u0301\u200b  "
+
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{name: "disabled", want: content},
+		{name: "strip zero width", opts: Options{StripZeroWidth: true}, want: "  cafe\u0301  "},
+		{name: "normalize", opts: Options{NormalizeUnicode: true}, want: "  caf\u00e9\u200b  "},
+		{name: "all", opts: Options{StripZeroWidth: true, NormalizeUnicode: true, TrimWhitespace: true}, want: "caf\u00e9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &service{transforms: tt.opts.transforms()}
+			chat := models.Chat{JID: "15550002222@s.whatsapp.net", Messages: []models.Message{{ID: "A1", Content: content}}}
+
+			got := s.transformChat(chat)
+			if got.Messages[0].Content != tt.want {
+				t.Errorf("content = %q, want %q", got.Messages[0].Content, tt.want)
+			}
+			// The chat handed in is left untouched
+			if chat.Messages[0].Content != content {
+				t.Errorf("original content changed to %q", chat.Messages[0].Content)
+			}
+		})
+	}
+}
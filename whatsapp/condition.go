@@ -0,0 +1,110 @@
+package whatsapp
+
+import (
+	"fmt"
+	"sync"
+
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// Condition explains why the client is not working normally, in terms an operator can act on
+type Condition struct {
+	Code    string
+	Message string
+	// NeedsAction is set when reconnecting cannot help until the operator intervenes
+	NeedsAction bool
+}
+
+// conditionTracker keeps the condition reported by the latest connection event
+type conditionTracker struct {
+	mu        sync.Mutex
+	condition Condition
+}
+
+// update records the condition signalled by evt, clearing it once connected again
+func (t *conditionTracker) update(evt any) {
+	condition, ok := connectionCondition(evt)
+	if !ok {
+		return
+	}
+
+	t.mu.Lock()
+	t.condition = condition
+	t.mu.Unlock()
+}
+
+func (t *conditionTracker) get() Condition {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.condition
+}
+
+// connectionCondition maps connection events to a condition. It reports false for events
+// that do not affect the connection, and an empty condition for those that restore it.
+func connectionCondition(evt any) (Condition, bool) {
+	switch v := evt.(type) {
+	case *events.Connected, *events.KeepAliveRestored:
+		return Condition{}, true
+	case *events.LoggedOut:
+		switch v.Reason {
+		case events.ConnectFailureUnknownLogout:
+			return Condition{
+				Code:        "banned",
+				Message:     "This number appears to be banned from WhatsApp",
+				NeedsAction: true,
+			}, true
+		case events.ConnectFailureMainDeviceGone:
+			return Condition{
+				Code:        "locked",
+				Message:     "The account is locked, open WhatsApp on the phone to unlock it, then log in again",
+				NeedsAction: true,
+			}, true
+		}
+		return Condition{
+			Code:        "logged_out",
+			Message:     "This device was logged out from the phone, scan a new QR code to log in again",
+			NeedsAction: true,
+		}, true
+	case *events.TemporaryBan:
+		return Condition{
+			Code:        "temporarily_banned",
+			Message:     fmt.Sprintf("This number is temporarily banned from WhatsApp for %s: %s", v.Expire, v.Code),
+			NeedsAction: true,
+		}, true
+	case *events.StreamReplaced:
+		return Condition{
+			Code:        "session_replaced",
+			Message:     "The session was replaced by another client using the same credentials, stop the other client before reconnecting",
+			NeedsAction: true,
+		}, true
+	case *events.ClientOutdated:
+		return Condition{
+			Code:        "client_outdated",
+			Message:     "WhatsApp rejected this client version as outdated, update the bridge",
+			NeedsAction: true,
+		}, true
+	case *events.ConnectFailure:
+		switch v.Reason {
+		case events.ConnectFailureServiceUnavailable, events.ConnectFailureInternalServerError:
+			return Condition{
+				Code:    "service_unavailable",
+				Message: "WhatsApp servers are unavailable, try again later",
+			}, true
+		}
+		return Condition{
+			Code:    "connect_failed",
+			Message: fmt.Sprintf("Connecting to WhatsApp failed: %s (%d)", v.Reason, int(v.Reason)),
+		}, true
+	case *events.KeepAliveTimeout:
+		return Condition{
+			Code:    "keepalive_timeout",
+			Message: "WhatsApp stopped answering keepalives, the connection may be unstable",
+		}, true
+	}
+	return Condition{}, false
+}
+
+// Condition returns the condition reported by the latest connection event, if any
+func (w *Whatsapp) Condition() Condition {
+	return w.condition.get()
+}
@@ -0,0 +1,81 @@
+package whatsapp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func TestConnectionCondition(t *testing.T) {
+	tests := []struct {
+		name        string
+		evt         any
+		wantOK      bool
+		wantCode    string
+		needsAction bool
+	}{
+		{"connected", &events.Connected{}, true, "", false},
+		{"keepalive restored", &events.KeepAliveRestored{}, true, "", false},
+		{"banned", &events.LoggedOut{Reason: events.ConnectFailureUnknownLogout}, true, "banned", true},
+		{"locked", &events.LoggedOut{Reason: events.ConnectFailureMainDeviceGone}, true, "locked", true},
+		{"logged out from phone", &events.LoggedOut{OnConnect: false}, true, "logged_out", true},
+		{"logged out on connect", &events.LoggedOut{OnConnect: true, Reason: events.ConnectFailureLoggedOut}, true, "logged_out", true},
+		{"temporary ban", &events.TemporaryBan{Code: events.TempBanBlockedByUsers, Expire: time.Hour}, true, "temporarily_banned", true},
+		{"stream replaced", &events.StreamReplaced{}, true, "session_replaced", true},
+		{"client outdated", &events.ClientOutdated{}, true, "client_outdated", true},
+		{"service unavailable", &events.ConnectFailure{Reason: events.ConnectFailureServiceUnavailable}, true, "service_unavailable", false},
+		{"internal server error", &events.ConnectFailure{Reason: events.ConnectFailureInternalServerError}, true, "service_unavailable", false},
+		{"other connect failure", &events.ConnectFailure{Reason: events.ConnectFailureBadUserAgent}, true, "connect_failed", false},
+		{"keepalive timeout", &events.KeepAliveTimeout{ErrorCount: 3}, true, "keepalive_timeout", false},
+		{"unrelated event", &events.Message{}, false, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := connectionCondition(tt.evt)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if got.Code != tt.wantCode || got.NeedsAction != tt.needsAction {
+				t.Errorf("condition = %+v, want code %q and NeedsAction %v", got, tt.wantCode, tt.needsAction)
+			}
+			if (got.Code == "") != (got.Message == "") {
+				t.Errorf("condition = %+v, want a message exactly when there is a code", got)
+			}
+		})
+	}
+}
+
+func TestConnectionConditionMessages(t *testing.T) {
+	ban, _ := connectionCondition(&events.TemporaryBan{Code: events.TempBanBlockedByUsers, Expire: 2 * time.Hour})
+	if !strings.Contains(ban.Message, "2h0m0s") || !strings.Contains(ban.Message, events.TempBanBlockedByUsers.String()) {
+		t.Errorf("temporary ban message %q does not name the duration and reason", ban.Message)
+	}
+
+	failure, _ := connectionCondition(&events.ConnectFailure{Reason: events.ConnectFailureBadUserAgent})
+	if !strings.Contains(failure.Message, "(409)") {
+		t.Errorf("connect failure message %q does not include the reason code", failure.Message)
+	}
+}
+
+func TestConditionTracker(t *testing.T) {
+	var tracker conditionTracker
+
+	tracker.update(&events.StreamReplaced{})
+	if got := tracker.get().Code; got != "session_replaced" {
+		t.Fatalf("code = %q, want session_replaced", got)
+	}
+
+	// Events unrelated to the connection keep the current condition
+	tracker.update(&events.Message{})
+	if got := tracker.get().Code; got != "session_replaced" {
+		t.Errorf("code after an unrelated event = %q, want session_replaced", got)
+	}
+
+	tracker.update(&events.Connected{})
+	if got := tracker.get(); got != (Condition{}) {
+		t.Errorf("condition after reconnecting = %+v, want none", got)
+	}
+}
@@ -0,0 +1,200 @@
+package whatsapp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// ErrGroupSendForbidden is returned when WhatsApp refuses a message to a group
+var ErrGroupSendForbidden = errors.New("not allowed to send to this group, only admins can send or you are no longer a participant")
+
+// Warnings logged by whatsmeow when a message cannot be encrypted for some devices.
+// whatsmeow skips those devices and reports the send as successful. They are matched
+// exactly, and TestWhatsmeowWarnings fails if a whatsmeow upgrade changes them.
+const (
+	encryptFailedWarning      = "Failed to encrypt %s for %s: %v"
+	encryptRetryFailedWarning = "Failed to encrypt %s for %s (retry): %v"
+	prekeyFailedWarning       = "Failed to fetch prekey for %s: %v"
+	prekeysFailedWarning      = "Failed to fetch prekeys for %v to retry encryption: %v"
+	participantHashWarning    = "Server returned different participant list hash when sending to %s. Some devices may not have received the message."
+)
+
+// deliveryTracker collects the devices messages could not be delivered to while they are sent.
+// Several sends can be tracked at once. Encryption warnings name the message, and participant
+// list warnings the chat, so they are attributed exactly. Prekey warnings only name the device,
+// so they are recorded for every send in progress and each send keeps those for devices of the
+// users it was addressed to; two sends to the same user at the same time may both report them.
+type deliveryTracker struct {
+	mu    sync.Mutex
+	sends map[string]*trackedSend
+}
+
+// trackedSend holds the delivery failures of a message being sent
+type trackedSend struct {
+	chat           string
+	report         models.DeliveryReport
+	prekeyFailures []models.DeviceFailure
+}
+
+// track runs send for the message id to chat and returns the devices it did not reach,
+// or nil if it reached all of them. recipients returns the users, without device, the message
+// was addressed to; it is only called to scope prekey failures.
+func (t *deliveryTracker) track(id, chat string, recipients func() (map[string]bool, error), send func() error) (*models.DeliveryReport, error) {
+	tracked := &trackedSend{chat: chat}
+
+	t.mu.Lock()
+	if t.sends == nil {
+		t.sends = map[string]*trackedSend{}
+	}
+	t.sends[id] = tracked
+	t.mu.Unlock()
+
+	err := send()
+
+	t.mu.Lock()
+	delete(t.sends, id)
+	report, prekeyFailures := tracked.report, tracked.prekeyFailures
+	t.mu.Unlock()
+
+	if len(prekeyFailures) > 0 {
+		users, rerr := recipients()
+		if rerr != nil {
+			fmt.Println("Error resolving recipients to report prekey failures of message", id+":", rerr)
+		}
+		for _, failure := range prekeyFailures {
+			if jid, perr := types.ParseJID(failure.JID); perr == nil && users[jid.ToNonAD().String()] {
+				report.FailedDevices = append(report.FailedDevices, failure)
+			}
+		}
+	}
+
+	if len(report.FailedDevices) == 0 && !report.ParticipantsChanged {
+		return nil, err
+	}
+	return &report, err
+}
+
+// observe records the delivery failures reported by a whatsmeow warning
+func (t *deliveryTracker) observe(msg string, args []interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch msg {
+	case encryptFailedWarning, encryptRetryFailedWarning:
+		if len(args) == 3 {
+			if tracked, ok := t.sends[fmt.Sprint(args[0])]; ok {
+				tracked.report.FailedDevices = append(tracked.report.FailedDevices, deviceFailure(fmt.Sprint(args[1]), args[2]))
+			}
+		}
+	case prekeyFailedWarning:
+		if len(args) == 2 {
+			t.failPrekey(fmt.Sprint(args[0]), args[1])
+		}
+	case prekeysFailedWarning:
+		if len(args) == 2 {
+			if jids, ok := args[0].([]types.JID); ok {
+				for _, jid := range jids {
+					t.failPrekey(jid.String(), args[1])
+				}
+			}
+		}
+	case participantHashWarning:
+		if len(args) == 1 {
+			for _, tracked := range t.sends {
+				if tracked.chat == fmt.Sprint(args[0]) {
+					tracked.report.ParticipantsChanged = true
+				}
+			}
+		}
+	}
+}
+
+// failPrekey records a device whose prekey could not be fetched for every send in progress,
+// pending scoping to the recipients of each. The caller must hold mu.
+func (t *deliveryTracker) failPrekey(jid string, err interface{}) {
+	for _, tracked := range t.sends {
+		tracked.prekeyFailures = append(tracked.prekeyFailures, deviceFailure(jid, err))
+	}
+}
+
+func deviceFailure(jid string, err interface{}) models.DeviceFailure {
+	return models.DeviceFailure{JID: jid, Error: fmt.Sprint(err)}
+}
+
+// deliveryLogger passes whatsmeow's client log through to the tracker before writing it
+type deliveryLogger struct {
+	waLog.Logger
+	tracker *deliveryTracker
+}
+
+func (l deliveryLogger) Warnf(msg string, args ...interface{}) {
+	l.tracker.observe(msg, args)
+	l.Logger.Warnf(msg, args...)
+}
+
+// sendRecipients returns the users, without device, a message to jid is encrypted for:
+// our own account, whose other devices get a copy, and the recipient or group participants
+func (w *Whatsapp) sendRecipients(jid types.JID) (map[string]bool, error) {
+	users := map[string]bool{}
+	if w.client.Store.ID != nil {
+		users[w.client.Store.ID.ToNonAD().String()] = true
+	}
+	if !w.client.Store.LID.IsEmpty() {
+		users[w.client.Store.LID.ToNonAD().String()] = true
+	}
+
+	if jid.Server != types.GroupServer {
+		users[jid.ToNonAD().String()] = true
+		return users, nil
+	}
+
+	info, err := w.client.GetGroupInfo(jid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group participants: %w", err)
+	}
+	for _, participant := range info.Participants {
+		users[participant.JID.ToNonAD().String()] = true
+		if !participant.LID.IsEmpty() {
+			users[participant.LID.ToNonAD().String()] = true
+		}
+	}
+	return users, nil
+}
+
+// groupSendError explains a send to a group rejected by WhatsApp, either when looking up its
+// members or in the server's answer to the message
+func groupSendError(jid types.JID, err error) error {
+	if jid.Server != types.GroupServer {
+		return err
+	}
+	if errors.Is(err, whatsmeow.ErrIQForbidden) {
+		return ErrGroupSendForbidden
+	}
+	if code, ok := serverErrorCode(err); ok && code == http.StatusForbidden {
+		return ErrGroupSendForbidden
+	}
+	return err
+}
+
+// serverErrorCode returns the code of an error the server answered a message with. whatsmeow
+// has no error type for it: it wraps ErrServerReturnedError as "%w %d", which
+// TestServerErrorCode pins, so the code is read back from that wrapping error.
+func serverErrorCode(err error) (int, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if errors.Unwrap(err) != whatsmeow.ErrServerReturnedError {
+			continue
+		}
+		code, convErr := strconv.Atoi(strings.TrimPrefix(err.Error(), whatsmeow.ErrServerReturnedError.Error()+" "))
+		return code, convErr == nil
+	}
+	return 0, false
+}
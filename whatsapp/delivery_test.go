@@ -0,0 +1,303 @@
+package whatsapp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// whatsmeowSource returns the source of a file of the whatsmeow module in use
+func whatsmeowSource(t *testing.T, name string) string {
+	t.Helper()
+
+	out, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", "go.mau.fi/whatsmeow").Output()
+	if err != nil {
+		t.Fatalf("failed to locate the whatsmeow module: %v", err)
+	}
+	source, err := os.ReadFile(filepath.Join(strings.TrimSpace(string(out)), name))
+	if err != nil {
+		t.Fatalf("failed to read whatsmeow %s: %v", name, err)
+	}
+	return string(source)
+}
+
+// TestWhatsmeowWarnings pins the warnings deliveryTracker matches to the whatsmeow source,
+// so an upgrade that rewords them fails here instead of silently reporting full delivery
+func TestWhatsmeowWarnings(t *testing.T) {
+	source := whatsmeowSource(t, "send.go")
+
+	warnings := []string{
+		encryptFailedWarning,
+		encryptRetryFailedWarning,
+		prekeyFailedWarning,
+		prekeysFailedWarning,
+		participantHashWarning,
+	}
+	for _, warning := range warnings {
+		if call := "cli.Log.Warnf(" + strconv.Quote(warning); !strings.Contains(source, call) {
+			t.Errorf("whatsmeow no longer logs %q", warning)
+		}
+	}
+}
+
+func TestDeliveryTracker(t *testing.T) {
+	const id = "3EB0A1"
+	chat := types.NewJID("15550002222", types.DefaultUserServer)
+	aliceDevice := types.NewADJID("15550002222", 0, 3)
+	ownDevice := types.NewADJID("15550001111", 0, 2)
+	otherDevice := types.NewADJID("15550003333", 0, 1)
+	users := map[string]bool{chat.String(): true, testOwnJID.String(): true}
+
+	type warning struct {
+		msg  string
+		args []interface{}
+	}
+	timeout := errors.New("timeout")
+
+	tests := []struct {
+		name          string
+		warnings      []warning
+		recipientsErr error
+		want          []string
+		wantChanged   bool
+	}{
+		{
+			name: "no warnings",
+		},
+		{
+			name: "encryption failure of this message",
+			warnings: []warning{
+				{encryptFailedWarning, []interface{}{id, aliceDevice, timeout}},
+				{encryptRetryFailedWarning, []interface{}{id, ownDevice, timeout}},
+			},
+			want: []string{aliceDevice.String(), ownDevice.String()},
+		},
+		{
+			name: "encryption failure of another message",
+			warnings: []warning{
+				{encryptFailedWarning, []interface{}{"3EB0B2", aliceDevice, timeout}},
+			},
+		},
+		{
+			name: "prekey failures scoped to the recipients",
+			warnings: []warning{
+				{prekeyFailedWarning, []interface{}{aliceDevice, timeout}},
+				{prekeyFailedWarning, []interface{}{otherDevice, timeout}},
+				{prekeysFailedWarning, []interface{}{[]types.JID{ownDevice, otherDevice}, timeout}},
+			},
+			want: []string{aliceDevice.String(), ownDevice.String()},
+		},
+		{
+			name: "prekey failures dropped when recipients are unknown",
+			warnings: []warning{
+				{prekeyFailedWarning, []interface{}{aliceDevice, timeout}},
+			},
+			recipientsErr: errors.New("group not found"),
+		},
+		{
+			name: "participant list changed",
+			warnings: []warning{
+				{participantHashWarning, []interface{}{chat}},
+			},
+			wantChanged: true,
+		},
+		{
+			name: "participant list of another chat changed",
+			warnings: []warning{
+				{participantHashWarning, []interface{}{otherDevice.ToNonAD()}},
+			},
+		},
+		{
+			name: "unrelated warning",
+			warnings: []warning{
+				{"Failed to send %s: %v", []interface{}{id, timeout}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := &deliveryTracker{}
+			recipients := func() (map[string]bool, error) {
+				if tt.recipientsErr != nil {
+					return nil, tt.recipientsErr
+				}
+				return users, nil
+			}
+
+			report, err := tracker.track(id, chat.String(), recipients, func() error {
+				for _, w := range tt.warnings {
+					tracker.observe(w.msg, w.args)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("track: %v", err)
+			}
+
+			if len(tt.want) == 0 && !tt.wantChanged {
+				if report != nil {
+					t.Fatalf("report = %+v, want nil", report)
+				}
+				return
+			}
+			if report == nil {
+				t.Fatal("report = nil, want failures")
+			}
+
+			var got []string
+			for _, failure := range report.FailedDevices {
+				got = append(got, failure.JID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("failed devices = %v, want %v", got, tt.want)
+			}
+			if report.ParticipantsChanged != tt.wantChanged {
+				t.Errorf("participants changed = %v, want %v", report.ParticipantsChanged, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestDeliveryTrackerIgnoresUntrackedSends(t *testing.T) {
+	tracker := &deliveryTracker{}
+	tracker.observe(prekeyFailedWarning, []interface{}{types.NewADJID("15550002222", 0, 3), "timeout"})
+
+	report, err := tracker.track("3EB0A1", "15550002222@s.whatsapp.net", func() (map[string]bool, error) {
+		return map[string]bool{"15550002222@s.whatsapp.net": true}, nil
+	}, func() error { return nil })
+	if err != nil || report != nil {
+		t.Errorf("track = %+v, %v, want a warning from before the send ignored", report, err)
+	}
+}
+
+func TestDeliveryTrackerConcurrentSends(t *testing.T) {
+	tracker := &deliveryTracker{}
+	alice := types.NewJID("15550002222", types.DefaultUserServer)
+	bob := types.NewJID("15550003333", types.DefaultUserServer)
+	aliceDevice := types.NewADJID(alice.User, 0, 3)
+	bobDevice := types.NewADJID(bob.User, 0, 1)
+
+	recipients := func(jid types.JID) func() (map[string]bool, error) {
+		return func() (map[string]bool, error) { return map[string]bool{jid.String(): true}, nil }
+	}
+
+	// The send to Alice stays in progress until the send to Bob has logged its warnings,
+	// which deadlocks if tracked sends are serialized
+	bobDone := make(chan struct{})
+	type result struct {
+		report *models.DeliveryReport
+		err    error
+	}
+	aliceResult := make(chan result, 1)
+	aliceStarted := make(chan struct{})
+	go func() {
+		report, err := tracker.track("3EB0A1", alice.String(), recipients(alice), func() error {
+			close(aliceStarted)
+			<-bobDone
+			tracker.observe(encryptFailedWarning, []interface{}{"3EB0A1", aliceDevice, "timeout"})
+			return nil
+		})
+		aliceResult <- result{report, err}
+	}()
+
+	<-aliceStarted
+	bobReport, err := tracker.track("3EB0B2", bob.String(), recipients(bob), func() error {
+		tracker.observe(prekeyFailedWarning, []interface{}{bobDevice, "timeout"})
+		tracker.observe(participantHashWarning, []interface{}{bob})
+		return nil
+	})
+	close(bobDone)
+	if err != nil {
+		t.Fatalf("track Bob: %v", err)
+	}
+
+	select {
+	case r := <-aliceResult:
+		if r.err != nil {
+			t.Fatalf("track Alice: %v", r.err)
+		}
+		if r.report == nil || len(r.report.FailedDevices) != 1 || r.report.FailedDevices[0].JID != aliceDevice.String() || r.report.ParticipantsChanged {
+			t.Errorf("Alice report = %+v, want only %s failed", r.report, aliceDevice)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("tracked sends are serialized")
+	}
+
+	if bobReport == nil || len(bobReport.FailedDevices) != 1 || bobReport.FailedDevices[0].JID != bobDevice.String() || !bobReport.ParticipantsChanged {
+		t.Errorf("Bob report = %+v, want only %s failed and the participants changed", bobReport, bobDevice)
+	}
+}
+
+func TestGroupSendError(t *testing.T) {
+	group := types.NewJID("120363000000000000", types.GroupServer)
+	user := types.NewJID("15550002222", types.DefaultUserServer)
+	forbidden := fmt.Errorf("%w %d", whatsmeow.ErrServerReturnedError, 403)
+	notParticipant := fmt.Errorf("failed to get group members: %w", &whatsmeow.IQError{Code: 403, Text: "forbidden"})
+
+	tests := []struct {
+		name string
+		jid  types.JID
+		err  error
+		want error
+	}{
+		{name: "group forbidden", jid: group, err: forbidden, want: ErrGroupSendForbidden},
+		{name: "group forbidden, wrapped", jid: group, err: fmt.Errorf("failed to send message node: %w", forbidden), want: ErrGroupSendForbidden},
+		{name: "group members forbidden", jid: group, err: notParticipant, want: ErrGroupSendForbidden},
+		{name: "user forbidden", jid: user, err: forbidden, want: forbidden},
+		{name: "group other status", jid: group, err: fmt.Errorf("%w %d", whatsmeow.ErrServerReturnedError, 500)},
+		{name: "group members not found", jid: group, err: fmt.Errorf("failed to get group members: %w", whatsmeow.ErrIQNotFound)},
+		{name: "group other error", jid: group, err: errors.New("timeout 403")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			if want == nil {
+				want = tt.err
+			}
+			if got := groupSendError(tt.jid, tt.err); !errors.Is(got, want) {
+				t.Errorf("groupSendError = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestServerErrorCode(t *testing.T) {
+	// serverErrorCode reads the code back from the error whatsmeow builds this way
+	if source := whatsmeowSource(t, "send.go"); !strings.Contains(source, `fmt.Errorf("%w %d", ErrServerReturnedError, errorCode)`) {
+		t.Error("whatsmeow no longer reports server errors as ErrServerReturnedError followed by the code")
+	}
+
+	tests := []struct {
+		name   string
+		err    error
+		want   int
+		wantOK bool
+	}{
+		{name: "server error", err: fmt.Errorf("%w %d", whatsmeow.ErrServerReturnedError, 403), want: 403, wantOK: true},
+		{name: "wrapped", err: fmt.Errorf("failed to send: %w", fmt.Errorf("%w %d", whatsmeow.ErrServerReturnedError, 479)), want: 479, wantOK: true},
+		{name: "bare", err: whatsmeow.ErrServerReturnedError},
+		{name: "other error", err: errors.New("server returned error 403")},
+		{name: "nil"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, ok := serverErrorCode(tt.err)
+			if code != tt.want || ok != tt.wantOK {
+				t.Errorf("serverErrorCode = %d, %v, want %d, %v", code, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package whatsapp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// sentIDTTL is how long the ID of a message sent by the bridge is remembered to
+// recognize its echo from another device
+const sentIDTTL = 10 * time.Minute
+
+// sentIDs tracks the IDs of messages recently sent by the bridge
+type sentIDs struct {
+	mu  sync.Mutex
+	ids map[string]time.Time
+}
+
+// add remembers a sent message ID, forgetting IDs older than sentIDTTL
+func (s *sentIDs) add(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.ids == nil {
+		s.ids = map[string]time.Time{}
+	}
+	for sentID, sentAt := range s.ids {
+		if now.Sub(sentAt) > sentIDTTL {
+			delete(s.ids, sentID)
+		}
+	}
+	s.ids[id] = now
+}
+
+// contains reports whether a message ID was sent by the bridge within sentIDTTL
+func (s *sentIDs) contains(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sentAt, ok := s.ids[id]
+	return ok && time.Since(sentAt) <= sentIDTTL
+}
+
+// recordSent stores a message sent by the bridge, since WhatsApp does not deliver it back
+// as an event, and remembers its ID so an echo from another device is not stored twice
+func (w *Whatsapp) recordSent(msg models.Message) {
+	w.recordSentChat(models.Chat{
+		JID:             msg.ChatJID,
+		LastMessageTime: msg.Timestamp,
+		Messages:        []models.Message{msg},
+	})
+}
+
+// recordSentChat stores messages sent by the bridge in a chat, attributed to our own JID
+func (w *Whatsapp) recordSentChat(chat models.Chat) {
+	var sender string
+	if w.client.Store.ID != nil {
+		sender = w.client.Store.ID.ToNonAD().String()
+	}
+
+	for i := range chat.Messages {
+		w.sent.add(chat.Messages[i].ID)
+		chat.Messages[i].IsFromMe = true
+		if sender != "" {
+			chat.Messages[i].Sender = sender
+		}
+	}
+
+	w.ChatChan <- chat
+}
+
+// isEcho reports whether a message event is our own message sent by the bridge
+func (w *Whatsapp) isEcho(msg *events.Message) bool {
+	return msg.Info.IsFromMe && w.sent.contains(msg.Info.ID)
+}
@@ -0,0 +1,117 @@
+package whatsapp
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func TestRecordSent(t *testing.T) {
+	w := newTestWhatsapp(t)
+	w.ChatChan = make(chan models.Chat, 1)
+
+	recipient := types.NewJID("15550002222", types.DefaultUserServer)
+	w.recordSent(models.Message{
+		ID:        "3EB0A1",
+		ChatJID:   recipient.String(),
+		Sender:    recipient.String(),
+		Content:   "hello",
+		Timestamp: time.Now(),
+		Type:      "text",
+	})
+
+	chat := <-w.ChatChan
+	if chat.JID != recipient.String() {
+		t.Errorf("stored in chat %s, want %s", chat.JID, recipient)
+	}
+	if len(chat.Messages) != 1 {
+		t.Fatalf("stored %d messages, want 1", len(chat.Messages))
+	}
+	if msg := chat.Messages[0]; msg.Sender != testOwnJID.String() || !msg.IsFromMe {
+		t.Errorf("message sender = %s, from me %v, want %s from me", msg.Sender, msg.IsFromMe, testOwnJID)
+	}
+}
+
+func TestRecordSentChatBroadcast(t *testing.T) {
+	w := newTestWhatsapp(t)
+	w.ChatChan = make(chan models.Chat, 1)
+
+	const broadcastJID = "1741597200000@broadcast"
+	w.recordSentChat(models.Chat{
+		JID:  broadcastJID,
+		Name: "Broadcast to 2 recipients",
+		Messages: []models.Message{
+			{ID: "3EB0A1", ChatJID: broadcastJID, Content: "hello", Type: "text"},
+			{ID: "3EB0B2", ChatJID: broadcastJID, Content: "hello", Type: "text"},
+		},
+	})
+
+	chat := <-w.ChatChan
+	if chat.JID != broadcastJID || len(chat.Messages) != 2 {
+		t.Fatalf("stored %d messages in chat %s, want 2 in %s", len(chat.Messages), chat.JID, broadcastJID)
+	}
+	for _, msg := range chat.Messages {
+		if msg.Sender != testOwnJID.String() || !msg.IsFromMe {
+			t.Errorf("message %s sender = %s, from me %v, want %s from me", msg.ID, msg.Sender, msg.IsFromMe, testOwnJID)
+		}
+		// The echo in the recipient's chat must not be stored a second time
+		if !w.sent.contains(msg.ID) {
+			t.Errorf("message %s not remembered as sent", msg.ID)
+		}
+	}
+}
+
+func TestIsEcho(t *testing.T) {
+	w := newTestWhatsapp(t)
+	w.sent.add("3EB0A1")
+
+	tests := []struct {
+		name     string
+		id       string
+		isFromMe bool
+		want     bool
+	}{
+		{name: "sent by the bridge", id: "3EB0A1", isFromMe: true, want: true},
+		{name: "sent from another device", id: "3EB0B2", isFromMe: true},
+		{name: "received with a reused ID", id: "3EB0A1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &events.Message{Info: types.MessageInfo{
+				ID:            tt.id,
+				MessageSource: types.MessageSource{IsFromMe: tt.isFromMe},
+			}}
+			if got := w.isEcho(msg); got != tt.want {
+				t.Errorf("isEcho = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendID(t *testing.T) {
+	w := newTestWhatsapp(t)
+	w.ChatChan = make(chan models.Chat, 1)
+
+	generated, err := w.sendID(models.SendOptions{})
+	if err != nil || generated == "" {
+		t.Fatalf("sendID = %q, %v, want a generated ID", generated, err)
+	}
+
+	custom := models.SendOptions{MessageID: "3EB0C0FFEE0123456789"}
+	id, err := w.sendID(custom)
+	if err != nil || id != custom.MessageID {
+		t.Fatalf("sendID = %q, %v, want %s", id, err, custom.MessageID)
+	}
+
+	// Once sent, the ID can not be reused
+	w.recordSent(models.Message{ID: id, ChatJID: "15550002222@s.whatsapp.net", Content: "hello", Timestamp: time.Now(), Type: "text"})
+	<-w.ChatChan
+	if _, err := w.sendID(custom); !errors.Is(err, ErrDuplicateMessageID) {
+		t.Errorf("sendID error = %v, want %v", err, ErrDuplicateMessageID)
+	}
+}
@@ -0,0 +1,112 @@
+package whatsapp
+
+import "unicode/utf8"
+
+const (
+	zeroWidthJoiner    = 0x200D
+	variationSelector  = 0xFE0F
+	textPresentation   = 0xFE0E
+	combiningKeycap    = 0x20E3
+	blackFlag          = 0x1F3F4
+	cancelTag          = 0xE007F
+	regionalIndicatorA = 0x1F1E6
+	regionalIndicatorZ = 0x1F1FF
+)
+
+// isSingleEmoji reports whether s is exactly one emoji grapheme. Multi-codepoint
+// sequences such as flags, keycaps, skin tone variants and ZWJ sequences are accepted.
+func isSingleEmoji(s string) bool {
+	if s == "" || !utf8.ValidString(s) {
+		return false
+	}
+
+	runes := []rune(s)
+
+	// Flags are a pair of regional indicators
+	if isRegionalIndicator(runes[0]) {
+		return len(runes) == 2 && isRegionalIndicator(runes[1])
+	}
+
+	// Keycaps are a digit, '#' or '*' optionally followed by VS16, then the combining keycap
+	if isKeycapBase(runes[0]) {
+		rest := runes[1:]
+		if len(rest) > 0 && rest[0] == variationSelector {
+			rest = rest[1:]
+		}
+		return len(rest) == 1 && rest[0] == combiningKeycap
+	}
+
+	// Subdivision flags are a black flag followed by tag characters ending with a cancel tag
+	if runes[0] == blackFlag && len(runes) > 1 && isTag(runes[1]) {
+		for i, r := range runes[1:] {
+			if !isTag(r) {
+				return false
+			}
+			if r == cancelTag {
+				return i == len(runes)-2
+			}
+		}
+		return false
+	}
+
+	// Everything else is a ZWJ-joined sequence of pictographs, each optionally
+	// followed by a presentation selector and a skin tone modifier
+	i := 0
+	for {
+		if i >= len(runes) || !isPictographic(runes[i]) {
+			return false
+		}
+		i++
+
+		if i < len(runes) && (runes[i] == variationSelector || runes[i] == textPresentation) {
+			i++
+		}
+		if i < len(runes) && isSkinToneModifier(runes[i]) {
+			i++
+		}
+
+		if i == len(runes) {
+			return true
+		}
+		if runes[i] != zeroWidthJoiner {
+			return false
+		}
+		i++
+	}
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= regionalIndicatorA && r <= regionalIndicatorZ
+}
+
+func isKeycapBase(r rune) bool {
+	return (r >= '0' && r <= '9') || r == '#' || r == '*'
+}
+
+func isTag(r rune) bool {
+	return r >= 0xE0020 && r <= cancelTag
+}
+
+func isSkinToneModifier(r rune) bool {
+	return r >= 0x1F3FB && r <= 0x1F3FF
+}
+
+func isPictographic(r rune) bool {
+	switch {
+	case r == 0x00A9, r == 0x00AE, r == 0x203C, r == 0x2049, r == 0x2122, r == 0x2139:
+		return true
+	case r >= 0x2194 && r <= 0x21AA:
+		return true
+	case r >= 0x231A && r <= 0x23FF:
+		return true
+	case r == 0x24C2, r == 0x2934, r == 0x2935, r == 0x3030, r == 0x303D, r == 0x3297, r == 0x3299:
+		return true
+	case r >= 0x25AA && r <= 0x27BF:
+		return true
+	case r >= 0x2B05 && r <= 0x2B55:
+		return true
+	case r >= 0x1F000 && r <= 0x1FAFF && !isSkinToneModifier(r) && !isRegionalIndicator(r):
+		return true
+	}
+	return false
+}
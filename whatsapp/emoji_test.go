@@ -0,0 +1,72 @@
+package whatsapp
+
+import "testing"
+
+func TestIsSingleEmoji(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{"empty", "", false},
+		{"plain text", "a", false},
+		{"single emoji", "👍", true},
+		{"presentation selector", "❤️", true},
+		{"skin tone", "👍🏽", true},
+		{"zwj family", "👨‍👩‍👧‍👦", true},
+		{"zwj with skin tones", "🧑🏻‍🤝‍🧑🏿", true},
+		{"flag", "🇫🇷", true},
+		{"keycap", "1️⃣", true},
+		{"keycap without selector", "#⃣", true},
+		{"subdivision flag", "🏴󠁧󠁢󠁳󠁣󠁴󠁿", true},
+		{"two emojis", "👍👍", false},
+		{"half a flag", "🇫", false},
+		{"three regional indicators", "🇫🇷🇫", false},
+		{"emoji and text", "👍ok", false},
+		{"trailing joiner", "👍‍", false},
+		{"digit alone", "1", false},
+		{"invalid utf8", "\xff", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isSingleEmoji(tt.input); got != tt.want {
+				t.Errorf("isSingleEmoji(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReactionSender(t *testing.T) {
+	tests := []struct {
+		name    string
+		sender  string
+		want    string
+		wantErr bool
+	}{
+		{"own message", "", "", false},
+		{"user", "123@s.whatsapp.net", "123@s.whatsapp.net", false},
+		{"device suffix", "123:12@s.whatsapp.net", "123@s.whatsapp.net", false},
+		{"lid with device", "456:3@lid", "456@lid", false},
+		{"invalid", "1:2:3@s.whatsapp.net", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jid, err := reactionSender(tt.sender)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reactionSender(%q) error = %v, wantErr %v", tt.sender, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := ""
+			if !jid.IsEmpty() {
+				got = jid.String()
+			}
+			if got != tt.want {
+				t.Errorf("reactionSender(%q) = %q, want %q", tt.sender, got, tt.want)
+			}
+		})
+	}
+}
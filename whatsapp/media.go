@@ -0,0 +1,263 @@
+package whatsapp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+)
+
+// ErrMediaExpired is returned when media is no longer available on the WhatsApp servers
+var ErrMediaExpired = errors.New("media is no longer available on WhatsApp servers")
+
+const (
+	// mediaQueueSize is the number of received attachments that can wait for a download worker
+	mediaQueueSize = 64
+	// mediaWorkers is the number of attachments downloaded concurrently
+	mediaWorkers = 2
+)
+
+// plainFileName matches names that can be used as a path element as they are
+var plainFileName = regexp.MustCompile(`^[A-Za-z0-9@._-]+$`)
+
+// mediaBreaker turns automatic media downloads off once the media directory proves
+// unwritable, so incoming messages are stored without media instead of each failing
+// and logging the same error. A successful manual retry turns downloads back on.
+type mediaBreaker struct {
+	open atomic.Bool
+}
+
+// trip disables automatic downloads, logging only on the first failure
+func (b *mediaBreaker) trip(err error) {
+	if b.open.CompareAndSwap(false, true) {
+		fmt.Println("Media directory is not writable, disabling media downloads until a retry succeeds:", err)
+	}
+}
+
+// reset enables automatic downloads again
+func (b *mediaBreaker) reset() {
+	if b.open.CompareAndSwap(true, false) {
+		fmt.Println("Media directory is writable again, media downloads enabled")
+	}
+}
+
+// checkWritable verifies that files can be created in dir, creating it if needed
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// mediaMessage is implemented by all downloadable message types
+type mediaMessage interface {
+	GetURL() string
+	GetDirectPath() string
+	GetMediaKey() []byte
+	GetFileSHA256() []byte
+	GetFileEncSHA256() []byte
+	GetFileLength() uint64
+	GetMimetype() string
+	GetContextInfo() *waProto.ContextInfo
+}
+
+var mediaTypes = map[string]whatsmeow.MediaType{
+	"image":    whatsmeow.MediaImage,
+	"sticker":  whatsmeow.MediaImage,
+	"video":    whatsmeow.MediaVideo,
+	"audio":    whatsmeow.MediaAudio,
+	"document": whatsmeow.MediaDocument,
+}
+
+// extractMedia returns the media type, attachment and caption of a message, if any
+func extractMedia(msg *waProto.Message) (string, mediaMessage, string) {
+	switch {
+	case msg.GetImageMessage() != nil:
+		return "image", msg.GetImageMessage(), msg.GetImageMessage().GetCaption()
+	case msg.GetVideoMessage() != nil:
+		return "video", msg.GetVideoMessage(), msg.GetVideoMessage().GetCaption()
+	case msg.GetAudioMessage() != nil:
+		return "audio", msg.GetAudioMessage(), ""
+	case msg.GetDocumentMessage() != nil:
+		return "document", msg.GetDocumentMessage(), msg.GetDocumentMessage().GetCaption()
+	case msg.GetStickerMessage() != nil:
+		return "sticker", msg.GetStickerMessage(), ""
+	}
+	return "", nil, ""
+}
+
+// setMedia copies the attachment metadata onto the message
+func setMedia(message *models.Message, mediaType string, media mediaMessage) {
+	message.MediaType = mediaType
+	message.MimeType = media.GetMimetype()
+	message.FileLength = media.GetFileLength()
+	message.URL = media.GetURL()
+	message.DirectPath = media.GetDirectPath()
+	message.MediaKey = media.GetMediaKey()
+	message.FileSHA256 = media.GetFileSHA256()
+	message.FileEncSHA256 = media.GetFileEncSHA256()
+}
+
+// DownloadMedia downloads the attachment of a message into the media directory and returns its path
+func (w *Whatsapp) DownloadMedia(msg models.Message) (string, error) {
+	mediaType, ok := mediaTypes[msg.MediaType]
+	if !ok {
+		return "", fmt.Errorf("unsupported media type: %q", msg.MediaType)
+	}
+
+	if msg.DirectPath == "" {
+		return "", ErrMediaExpired
+	}
+
+	path, err := w.mediaFilePath(msg)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := w.client.DownloadMediaWithPath(msg.DirectPath, msg.FileEncSHA256, msg.FileSHA256, msg.MediaKey, int(msg.FileLength), mediaType, "")
+	if errors.Is(err, whatsmeow.ErrMediaDownloadFailedWith403) ||
+		errors.Is(err, whatsmeow.ErrMediaDownloadFailedWith404) ||
+		errors.Is(err, whatsmeow.ErrMediaDownloadFailedWith410) {
+		return "", ErrMediaExpired
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to download media: %w", err)
+	}
+
+	if err := w.writeMedia(path, data); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// writeMedia writes a media file, turning automatic downloads off if it can not be written
+// and back on once it can
+func (w *Whatsapp) writeMedia(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		w.media.trip(err)
+		return fmt.Errorf("failed to create media directory: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		w.media.trip(err)
+		return fmt.Errorf("failed to write media file: %w", err)
+	}
+
+	w.media.reset()
+	return nil
+}
+
+// mediaFilePath returns the path the attachment of a message is stored at. The chat JID and
+// message ID come from the network, so they are only used as they are when they are plain
+// file names, and the result is checked to stay inside the media directory.
+func (w *Whatsapp) mediaFilePath(msg models.Message) (string, error) {
+	path := filepath.Join(w.mediaDir, safeFileName(msg.ChatJID), safeFileName(msg.ID)+mediaExtension(msg.MimeType, msg.MediaType))
+	if !withinDir(w.mediaDir, path) {
+		return "", fmt.Errorf("media file %s is outside the media directory", path)
+	}
+	return path, nil
+}
+
+// safeFileName returns name unchanged when it is a plain file name, and its SHA-256 otherwise
+func safeFileName(name string) string {
+	if plainFileName.MatchString(name) && strings.Trim(name, ".") != "" {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:])
+}
+
+// withinDir reports whether path is inside dir, dir itself excluded
+func withinDir(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// queueMedia schedules the download of a message attachment without blocking the caller.
+// When the queue is full the message keeps only its metadata, for a later retry.
+func (w *Whatsapp) queueMedia(msg models.Message) {
+	select {
+	case w.mediaQueue <- msg:
+	default:
+		fmt.Println("Media download queue is full, skipping media of message", msg.ID)
+	}
+}
+
+// downloadQueuedMedia downloads queued attachments and reports them on MediaChan
+func (w *Whatsapp) downloadQueuedMedia() {
+	for msg := range w.mediaQueue {
+		// Attachments queued before the directory became unwritable are left for a retry
+		if w.media.open.Load() {
+			continue
+		}
+
+		path, err := w.DownloadMedia(msg)
+		if err != nil {
+			fmt.Println("Error downloading media:", err)
+			continue
+		}
+
+		w.MediaChan <- models.MediaDownload{
+			ChatJID:   msg.ChatJID,
+			MessageID: msg.ID,
+			Path:      path,
+		}
+	}
+}
+
+// MediaDir returns the directory downloaded media is stored in
+func (w *Whatsapp) MediaDir() string {
+	return w.mediaDir
+}
+
+// DeleteMedia removes a downloaded media file. It reports whether a file was deleted,
+// and refuses to remove files outside the media directory.
+func (w *Whatsapp) DeleteMedia(path string) (bool, error) {
+	if !withinDir(w.mediaDir, path) {
+		return false, fmt.Errorf("media file %s is outside the media directory", path)
+	}
+
+	err := os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to delete media file: %w", err)
+	}
+
+	return true, nil
+}
+
+func mediaExtension(mimeType string, mediaType string) string {
+	if exts, err := mime.ExtensionsByType(mimeType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+
+	switch mediaType {
+	case "image":
+		return ".jpg"
+	case "video":
+		return ".mp4"
+	case "audio":
+		return ".ogg"
+	case "sticker":
+		return ".webp"
+	}
+	return ".bin"
+}
@@ -0,0 +1,157 @@
+package whatsapp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+)
+
+func TestMediaFilePath(t *testing.T) {
+	mediaDir := filepath.Join(t.TempDir(), "media")
+	w := &Whatsapp{mediaDir: mediaDir}
+
+	tests := []struct {
+		name     string
+		chatJID  string
+		id       string
+		wantChat string
+		wantFile string
+	}{
+		{
+			name:     "plain names are kept",
+			chatJID:  "15550002222@s.whatsapp.net",
+			id:       "3EB0C4A1B2C3D4E5F6",
+			wantChat: "15550002222@s.whatsapp.net",
+			wantFile: "3EB0C4A1B2C3D4E5F6.jpg",
+		},
+		{
+			name:     "traversal in the ID",
+			chatJID:  "15550002222@s.whatsapp.net",
+			id:       "../../../etc/passwd",
+			wantChat: "15550002222@s.whatsapp.net",
+			wantFile: safeFileName("../../../etc/passwd") + ".jpg",
+		},
+		{
+			name:     "traversal in the chat JID",
+			chatJID:  "../..",
+			id:       "3EB0",
+			wantChat: safeFileName("../.."),
+			wantFile: "3EB0.jpg",
+		},
+		{
+			name:     "dot names",
+			chatJID:  "..",
+			id:       ".",
+			wantChat: safeFileName(".."),
+			wantFile: safeFileName(".") + ".jpg",
+		},
+		{
+			name:     "absolute ID",
+			chatJID:  "15550002222@s.whatsapp.net",
+			id:       "/tmp/x",
+			wantChat: "15550002222@s.whatsapp.net",
+			wantFile: safeFileName("/tmp/x") + ".jpg",
+		},
+		{
+			name:     "backslashes",
+			chatJID:  `..\..`,
+			id:       `a\b`,
+			wantChat: safeFileName(`..\..`),
+			wantFile: safeFileName(`a\b`) + ".jpg",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := models.Message{ChatJID: tt.chatJID, ID: tt.id, MediaType: "image", MimeType: "image/jpeg"}
+			path, err := w.mediaFilePath(msg)
+			if err != nil {
+				t.Fatalf("mediaFilePath: %v", err)
+			}
+
+			// mime may map image/jpeg to any of its extensions
+			want := filepath.Join(mediaDir, tt.wantChat, strings.TrimSuffix(tt.wantFile, ".jpg"))
+			if strings.TrimSuffix(path, filepath.Ext(path)) != want {
+				t.Errorf("path = %q, want %q with an extension", path, want)
+			}
+			if !withinDir(mediaDir, path) {
+				t.Errorf("path %q is outside %q", path, mediaDir)
+			}
+		})
+	}
+}
+
+func TestWithinDir(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "/data/media/chat/file.jpg", want: true},
+		{path: "/data/media/..file.jpg", want: true},
+		{path: "/data/media"},
+		{path: "/data/media/.."},
+		{path: "/data/media/../whatsapp.db"},
+		{path: "/data/mediafile.jpg"},
+		{path: "/etc/passwd"},
+	}
+
+	for _, tt := range tests {
+		if got := withinDir("/data/media", tt.path); got != tt.want {
+			t.Errorf("withinDir(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestQueueMediaDoesNotBlock(t *testing.T) {
+	w := &Whatsapp{mediaQueue: make(chan models.Message, 1)}
+
+	w.queueMedia(models.Message{ID: "A1"})
+	w.queueMedia(models.Message{ID: "A2"})
+
+	if got := (<-w.mediaQueue).ID; got != "A1" {
+		t.Errorf("queued %q, want A1", got)
+	}
+	if len(w.mediaQueue) != 0 {
+		t.Errorf("queue holds %d messages after overflow, want 0", len(w.mediaQueue))
+	}
+}
+
+func TestMediaBreakerUnwritableDir(t *testing.T) {
+	// A file in place of the chat directory makes it unwritable even when running as root
+	mediaDir := t.TempDir()
+	blocker := filepath.Join(mediaDir, "123@s.whatsapp.net")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	w := &Whatsapp{mediaDir: mediaDir}
+	path := filepath.Join(blocker, "A1.jpg")
+
+	if err := w.writeMedia(path, []byte("data")); err == nil {
+		t.Fatal("writeMedia succeeded in an unwritable directory")
+	}
+	if !w.media.open.Load() {
+		t.Fatal("breaker is closed after a failed write, want open")
+	}
+	if err := checkWritable(blocker); err == nil {
+		t.Error("checkWritable succeeded on a file")
+	}
+
+	// Queued attachments are skipped without a download while the breaker is open
+	w.mediaQueue = make(chan models.Message, 1)
+	w.queueMedia(models.Message{ID: "A1", ChatJID: "123@s.whatsapp.net", MediaType: "image"})
+	close(w.mediaQueue)
+	w.downloadQueuedMedia()
+
+	if err := os.Remove(blocker); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.writeMedia(path, []byte("data")); err != nil {
+		t.Fatalf("writeMedia after the directory became writable: %v", err)
+	}
+	if w.media.open.Load() {
+		t.Error("breaker is open after a successful write, want closed")
+	}
+}
@@ -0,0 +1,291 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	previewTimeout       = 5 * time.Second
+	previewMaxPageBytes  = 2 * 1024 * 1024
+	previewMaxImageBytes = 1024 * 1024
+	// previewMaxImagePixels bounds the decoded size of the og:image, as a small file can
+	// declare huge dimensions
+	previewMaxImagePixels = 4096 * 4096
+	// previewThumbSize is the longest side of the thumbnail sent with the preview
+	previewThumbSize    = 100
+	previewThumbQuality = 75
+)
+
+// errBodyTooLarge is returned when a fetched body exceeds its size limit
+var errBodyTooLarge = errors.New("response body is too large")
+
+var (
+	urlPattern       = regexp.MustCompile(`https?://[^\s<>"]+`)
+	metaTagPattern   = regexp.MustCompile(`(?is)<meta\s+[^>]*>`)
+	metaAttrPattern  = regexp.MustCompile(`(?is)(property|name|content)\s*=\s*("[^"]*"|'[^']*')`)
+	titleTagPattern  = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	previewUserAgent = "Mozilla/5.0 (compatible; WhatsAppLinkPreview/1.0)"
+
+	// carrierGradeNAT is the shared address space of RFC 6598, not covered by netip.Addr.IsPrivate
+	carrierGradeNAT = netip.MustParsePrefix("100.64.0.0/10")
+
+	// previewClient only connects to public addresses, so a link in a message can not be
+	// used to reach the bridge host or its network. The check runs on the resolved address
+	// of every connection, redirects included.
+	previewClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{Control: refusePrivateAddress}).DialContext,
+		},
+	}
+)
+
+// linkPreview holds the metadata shown in a link preview
+type linkPreview struct {
+	URL         string
+	Title       string
+	Description string
+	Thumbnail   []byte
+}
+
+// findURL returns the first URL in text, if any, without trailing punctuation
+func findURL(text string) string {
+	return strings.TrimRight(urlPattern.FindString(text), ".,;:!?)")
+}
+
+// fetchLinkPreview fetches the page at rawURL and extracts its title, description and thumbnail
+func fetchLinkPreview(ctx context.Context, rawURL string) (*linkPreview, error) {
+	ctx, cancel := context.WithTimeout(ctx, previewTimeout)
+	defer cancel()
+
+	page, err := fetch(ctx, rawURL, previewMaxPageBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := parseLinkPreview(rawURL, string(page))
+	if preview.Title == "" {
+		return nil, fmt.Errorf("no title found for %s", rawURL)
+	}
+
+	if imageURL := parseMetaTags(string(page))["og:image"]; imageURL != "" {
+		if resolved, err := resolveURL(rawURL, imageURL); err == nil {
+			// The thumbnail is optional, a preview without it is still useful
+			if data, err := fetch(ctx, resolved, previewMaxImageBytes); err == nil {
+				preview.Thumbnail, _ = thumbnail(data)
+			}
+		}
+	}
+
+	return preview, nil
+}
+
+// parseLinkPreview extracts the preview metadata from an HTML page
+func parseLinkPreview(rawURL string, page string) *linkPreview {
+	meta := parseMetaTags(page)
+
+	preview := &linkPreview{
+		URL:         rawURL,
+		Title:       meta["og:title"],
+		Description: meta["og:description"],
+	}
+
+	if preview.Title == "" {
+		if match := titleTagPattern.FindStringSubmatch(page); match != nil {
+			preview.Title = strings.TrimSpace(html.UnescapeString(match[1]))
+		}
+	}
+
+	if preview.Description == "" {
+		preview.Description = meta["description"]
+	}
+
+	return preview
+}
+
+// parseMetaTags maps meta tag property or name attributes to their content
+func parseMetaTags(page string) map[string]string {
+	tags := map[string]string{}
+
+	for _, tag := range metaTagPattern.FindAllString(page, -1) {
+		var key, content string
+		for _, attr := range metaAttrPattern.FindAllStringSubmatch(tag, -1) {
+			value := html.UnescapeString(strings.Trim(attr[2], `"'`))
+			switch strings.ToLower(attr[1]) {
+			case "property", "name":
+				key = strings.ToLower(value)
+			case "content":
+				content = strings.TrimSpace(value)
+			}
+		}
+
+		if _, exists := tags[key]; key != "" && content != "" && !exists {
+			tags[key] = content
+		}
+	}
+
+	return tags
+}
+
+// buildPreviewMessage builds an extended text message with the link preview populated
+func buildPreviewMessage(text string, preview *linkPreview) *waProto.Message {
+	return &waProto.Message{
+		ExtendedTextMessage: &waProto.ExtendedTextMessage{
+			Text:          proto.String(text),
+			MatchedText:   proto.String(preview.URL),
+			Title:         proto.String(preview.Title),
+			Description:   proto.String(preview.Description),
+			JPEGThumbnail: preview.Thumbnail,
+			PreviewType:   waProto.ExtendedTextMessage_NONE.Enum(),
+		},
+	}
+}
+
+// thumbnail decodes an image and re-encodes it as a JPEG no larger than previewThumbSize
+func thumbnail(data []byte) ([]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > previewMaxImagePixels {
+		return nil, fmt.Errorf("image of %dx%d pixels is too large", config.Width, config.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, resize(src, previewThumbSize), &jpeg.Options{Quality: previewThumbQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// resize scales src down so its longest side is at most size, averaging the source pixels
+// covered by each thumbnail pixel. Images already small enough keep their size.
+func resize(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if longest := max(width, height); longest > size {
+		width = max(width*size/longest, 1)
+		height = max(height*size/longest, 1)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(bounds.Min.Y+(y+1)*bounds.Dy()/height, y0+1)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(bounds.Min.X+(x+1)*bounds.Dx()/width, x0+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8((r / n) >> 8)
+			dst.Pix[i+1] = uint8((g / n) >> 8)
+			dst.Pix[i+2] = uint8((b / n) >> 8)
+			dst.Pix[i+3] = uint8((a / n) >> 8)
+		}
+	}
+	return dst
+}
+
+// refusePrivateAddress is a dialer control function rejecting connections to loopback,
+// link-local, private and other non-public addresses
+func refusePrivateAddress(network string, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid address %s: %w", address, err)
+	}
+	if !isPublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("refusing to connect to non-public address %s", addrPort.Addr())
+	}
+	return nil
+}
+
+// isPublicAddr reports whether addr is a globally routable unicast address
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !carrierGradeNAT.Contains(addr)
+}
+
+func fetch(ctx context.Context, rawURL string, maxBytes int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	req.Header.Set("User-Agent", previewUserAgent)
+
+	resp, err := previewClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: HTTP %d", rawURL, resp.StatusCode)
+	}
+
+	if resp.ContentLength > maxBytes {
+		return nil, fmt.Errorf("failed to fetch %s: %w", rawURL, errBodyTooLarge)
+	}
+
+	data, err := readLimited(resp.Body, maxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", rawURL, err)
+	}
+	return data, nil
+}
+
+// readLimited reads r to the end, failing instead of truncating when it holds more than maxBytes
+func readLimited(r io.Reader, maxBytes int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, errBodyTooLarge
+	}
+	return data, nil
+}
+
+func resolveURL(base string, ref string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return baseURL.ResolveReference(refURL).String(), nil
+}
@@ -0,0 +1,146 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "93.184.216.34", want: true},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", want: true},
+		{addr: "127.0.0.1"},
+		{addr: "::1"},
+		{addr: "10.1.2.3"},
+		{addr: "172.16.0.1"},
+		{addr: "192.168.1.1"},
+		{addr: "169.254.169.254"},
+		{addr: "fe80::1"},
+		{addr: "fd00::1"},
+		{addr: "100.64.0.1"},
+		{addr: "0.0.0.0"},
+		{addr: "::"},
+		{addr: "224.0.0.1"},
+		{addr: "::ffff:127.0.0.1"},
+		{addr: "::ffff:93.184.216.34", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := isPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("isPublicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestFetchRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<title>internal</title>"))
+	}))
+	defer server.Close()
+
+	if _, err := fetch(context.Background(), server.URL, previewMaxPageBytes); err == nil {
+		t.Fatal("fetch of a loopback address succeeded")
+	}
+}
+
+func TestReadLimited(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		wantErr bool
+	}{
+		{name: "empty"},
+		{name: "under the limit", size: 9},
+		{name: "at the limit", size: 10},
+		{name: "over the limit", size: 11, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := readLimited(strings.NewReader(strings.Repeat("x", tt.size)), 10)
+			if tt.wantErr {
+				if !errors.Is(err, errBodyTooLarge) {
+					t.Fatalf("err = %v, want errBodyTooLarge", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readLimited: %v", err)
+			}
+			if len(data) != tt.size {
+				t.Errorf("read %d bytes, want %d", len(data), tt.size)
+			}
+		})
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	encodePNG := func(width, height int) []byte {
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				img.Set(x, y, color.RGBA{R: 200, G: 40, B: 40, A: 255})
+			}
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatalf("png.Encode: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		wantWidth  int
+		wantHeight int
+		wantErr    bool
+	}{
+		{name: "landscape", data: encodePNG(800, 400), wantWidth: 100, wantHeight: 50},
+		{name: "portrait", data: encodePNG(300, 600), wantWidth: 50, wantHeight: 100},
+		{name: "thin", data: encodePNG(1000, 2), wantWidth: 100, wantHeight: 1},
+		{name: "already small", data: encodePNG(40, 30), wantWidth: 40, wantHeight: 30},
+		{name: "not an image", data: []byte("<html></html>"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thumb, err := thumbnail(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("thumbnail succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("thumbnail: %v", err)
+			}
+
+			img, err := jpeg.Decode(bytes.NewReader(thumb))
+			if err != nil {
+				t.Fatalf("thumbnail is not a JPEG: %v", err)
+			}
+			if got := img.Bounds().Size(); got.X != tt.wantWidth || got.Y != tt.wantHeight {
+				t.Errorf("size = %dx%d, want %dx%d", got.X, got.Y, tt.wantWidth, tt.wantHeight)
+			}
+
+			r, g, b, _ := img.At(img.Bounds().Dx()/2, img.Bounds().Dy()/2).RGBA()
+			if r>>8 < 150 || g>>8 > 90 || b>>8 > 90 {
+				t.Errorf("center pixel = %d,%d,%d, want close to 200,40,40", r>>8, g>>8, b>>8)
+			}
+		})
+	}
+}
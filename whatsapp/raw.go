@@ -0,0 +1,34 @@
+package whatsapp
+
+import (
+	"encoding/base64"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxRawMessageBytes caps the size of a stored raw message proto
+const maxRawMessageBytes = 64 * 1024
+
+// rawMessage encodes the raw proto of a message, truncated to maxRawMessageBytes
+func rawMessage(msg *events.Message) (models.RawMessage, error) {
+	data, err := proto.Marshal(msg.RawMessage)
+	if err != nil {
+		return models.RawMessage{}, err
+	}
+
+	raw := models.RawMessage{
+		MessageID:  msg.Info.ID,
+		ChatJID:    msg.Info.Chat.String(),
+		Size:       len(data),
+		Truncated:  len(data) > maxRawMessageBytes,
+		ReceivedAt: msg.Info.Timestamp,
+	}
+	if raw.Truncated {
+		data = data[:maxRawMessageBytes]
+	}
+	raw.Raw = base64.StdEncoding.EncodeToString(data)
+
+	return raw, nil
+}
@@ -0,0 +1,64 @@
+package whatsapp
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestHandleEventStoreRaw(t *testing.T) {
+	chat := types.NewJID("15550002222", types.DefaultUserServer)
+
+	for _, storeRaw := range []bool{false, true} {
+		t.Run(fmt.Sprint(storeRaw), func(t *testing.T) {
+			w := newTestWhatsapp(t)
+			w.opts.StoreRaw = storeRaw
+			w.ChatChan = make(chan models.Chat, 1)
+			w.RawChan = make(chan models.RawMessage, 1)
+
+			message := &waProto.Message{Conversation: proto.String("hello")}
+			w.handleEvent(&events.Message{
+				Info: types.MessageInfo{
+					ID:            "3EB0A1",
+					Timestamp:     time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC),
+					MessageSource: types.MessageSource{Chat: chat, Sender: chat},
+				},
+				Message:    message,
+				RawMessage: message,
+			})
+
+			if got := (<-w.ChatChan).Messages[0].Content; got != "hello" {
+				t.Errorf("stored content = %q, want hello", got)
+			}
+
+			select {
+			case raw := <-w.RawChan:
+				if !storeRaw {
+					t.Fatalf("raw message %s stored with DEBUG_STORE_RAW disabled", raw.MessageID)
+				}
+				data, err := base64.StdEncoding.DecodeString(raw.Raw)
+				if err != nil {
+					t.Fatalf("decoding raw: %v", err)
+				}
+				var decoded waProto.Message
+				if err := proto.Unmarshal(data, &decoded); err != nil {
+					t.Fatalf("unmarshaling raw: %v", err)
+				}
+				if raw.MessageID != "3EB0A1" || raw.ChatJID != chat.String() || decoded.GetConversation() != "hello" {
+					t.Errorf("raw = %+v holding %q", raw, decoded.GetConversation())
+				}
+			default:
+				if storeRaw {
+					t.Fatal("raw message not stored with DEBUG_STORE_RAW enabled")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	// ErrNotMP4 is returned when a video to send is not an MP4 file
+	ErrNotMP4 = errors.New("video must be an MP4 file")
+	// ErrPathNotAllowed is returned when a file to send is outside the send media directory
+	ErrPathNotAllowed = errors.New("media path is outside the send media directory")
+)
+
+// SendVideo uploads an MP4 file from the send media directory and sends it to a recipient,
+// returning its ID with the devices it did not reach when it was only partially delivered.
+// With asGIF set the video is sent as a GIF, which WhatsApp plays muted and on a loop.
+func (w *Whatsapp) SendVideo(ctx context.Context, recipient string, path string, caption string, asGIF bool) (string, *models.DeliveryReport, error) {
+	recipientJID, err := parseRecipient(recipient)
+	if err != nil {
+		return "", nil, err
+	}
+
+	path, err = w.sendMediaPath(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read video: %w", err)
+	}
+
+	if http.DetectContentType(data) != "video/mp4" {
+		return "", nil, ErrNotMP4
+	}
+
+	uploaded, err := w.client.Upload(ctx, data, whatsmeow.MediaVideo)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to upload video: %w", err)
+	}
+
+	video := buildVideoMessage(uploaded, caption, asGIF)
+
+	extra := whatsmeow.SendRequestExtra{ID: w.client.GenerateMessageID()}
+	var resp whatsmeow.SendResponse
+	recipients := func() (map[string]bool, error) {
+		return w.sendRecipients(recipientJID)
+	}
+	delivery, err := w.delivery.track(extra.ID, recipientJID.String(), recipients, func() error {
+		var err error
+		resp, err = w.client.SendMessage(ctx, recipientJID, &waProto.Message{VideoMessage: video}, extra)
+		return err
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to send video: %w", groupSendError(recipientJID, err))
+	}
+
+	sent := models.Message{
+		ID:        resp.ID,
+		ChatJID:   recipientJID.String(),
+		Content:   caption,
+		Timestamp: resp.Timestamp,
+		Type:      "video",
+	}
+	setMedia(&sent, "video", video)
+
+	// Keep a copy like a downloaded attachment, so the video is not fetched back from WhatsApp
+	if mediaPath, err := w.mediaFilePath(sent); err != nil {
+		fmt.Println("Error keeping a copy of the sent video:", err)
+	} else if err := w.writeMedia(mediaPath, data); err != nil {
+		fmt.Println("Error keeping a copy of the sent video:", err)
+	} else {
+		sent.MediaPath = mediaPath
+	}
+
+	w.recordSent(sent)
+
+	return resp.ID, delivery, nil
+}
+
+// sendMediaPath resolves the path of a file to send, relative paths being relative to the
+// send media directory. Symbolic links are followed so they can not lead out of it.
+func (w *Whatsapp) sendMediaPath(path string) (string, error) {
+	dir, err := filepath.Abs(w.sendDir)
+	if err == nil {
+		dir, err = filepath.EvalSymlinks(dir)
+	}
+	if err != nil {
+		return "", fmt.Errorf("send media directory is not available: %w", err)
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read media: %w", err)
+	}
+	if !withinDir(dir, resolved) {
+		return "", ErrPathNotAllowed
+	}
+
+	return resolved, nil
+}
+
+// buildVideoMessage builds the message for an uploaded MP4 video
+func buildVideoMessage(uploaded whatsmeow.UploadResponse, caption string, asGIF bool) *waProto.VideoMessage {
+	video := &waProto.VideoMessage{
+		URL:           proto.String(uploaded.URL),
+		DirectPath:    proto.String(uploaded.DirectPath),
+		MediaKey:      uploaded.MediaKey,
+		Mimetype:      proto.String("video/mp4"),
+		FileEncSHA256: uploaded.FileEncSHA256,
+		FileSHA256:    uploaded.FileSHA256,
+		FileLength:    proto.Uint64(uploaded.FileLength),
+	}
+
+	if caption != "" {
+		video.Caption = proto.String(caption)
+	}
+
+	if asGIF {
+		video.GifPlayback = proto.Bool(true)
+	}
+
+	return video
+}
@@ -0,0 +1,109 @@
+package whatsapp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.mau.fi/whatsmeow"
+)
+
+func TestBuildVideoMessage(t *testing.T) {
+	uploaded := whatsmeow.UploadResponse{
+		URL:        "https://mmg.whatsapp.net/v/t62.7161-24/1",
+		DirectPath: "/v/t62.7161-24/1",
+		MediaKey:   []byte("key"),
+		FileLength: 1024,
+	}
+
+	tests := []struct {
+		name    string
+		caption string
+		asGIF   bool
+	}{
+		{name: "video"},
+		{name: "gif", asGIF: true},
+		{name: "gif with caption", caption: "lol", asGIF: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			video := buildVideoMessage(uploaded, tt.caption, tt.asGIF)
+
+			// Unset rather than false, like videos sent by WhatsApp clients
+			if tt.asGIF {
+				if !video.GetGifPlayback() {
+					t.Error("gifPlayback not set")
+				}
+			} else if video.GifPlayback != nil {
+				t.Errorf("gifPlayback = %v, want unset", video.GetGifPlayback())
+			}
+
+			if video.GetCaption() != tt.caption || (tt.caption == "" && video.Caption != nil) {
+				t.Errorf("caption = %v, want %q", video.Caption, tt.caption)
+			}
+			if video.GetMimetype() != "video/mp4" || video.GetDirectPath() != uploaded.DirectPath || video.GetFileLength() != uploaded.FileLength {
+				t.Errorf("upload metadata not carried: %+v", video)
+			}
+		})
+	}
+}
+
+func TestSendMediaPath(t *testing.T) {
+	root := t.TempDir()
+	sendDir := filepath.Join(root, "outbox")
+	for _, dir := range []string{sendDir, filepath.Join(sendDir, "gifs")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{filepath.Join(sendDir, "clip.mp4"), filepath.Join(sendDir, "gifs", "wave.mp4"), filepath.Join(root, "secret.db")} {
+		if err := os.WriteFile(file, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "secret.db"), filepath.Join(sendDir, "link.mp4")); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &Whatsapp{sendDir: sendDir}
+	resolvedDir, err := filepath.EvalSymlinks(sendDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr error
+	}{
+		{name: "relative", path: "clip.mp4", want: filepath.Join(resolvedDir, "clip.mp4")},
+		{name: "relative in a subdirectory", path: "gifs/wave.mp4", want: filepath.Join(resolvedDir, "gifs", "wave.mp4")},
+		{name: "absolute inside", path: filepath.Join(sendDir, "clip.mp4"), want: filepath.Join(resolvedDir, "clip.mp4")},
+		{name: "traversal", path: "../secret.db", wantErr: ErrPathNotAllowed},
+		{name: "absolute outside", path: filepath.Join(root, "secret.db"), wantErr: ErrPathNotAllowed},
+		{name: "symbolic link out", path: "link.mp4", wantErr: ErrPathNotAllowed},
+		{name: "the directory itself", path: sendDir, wantErr: ErrPathNotAllowed},
+		{name: "missing", path: "missing.mp4", wantErr: os.ErrNotExist},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := w.sendMediaPath(tt.path)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("sendMediaPath(%q) = %q, %v, want %v", tt.path, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("sendMediaPath(%q): %v", tt.path, err)
+			}
+			if got != tt.want {
+				t.Errorf("sendMediaPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -4,28 +4,73 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/mbenaiss/whatsapp-mcp/models"
 	"github.com/mdp/qrterminal"
 	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/appstate"
 	waProto "go.mau.fi/whatsmeow/binary/proto"
 	"go.mau.fi/whatsmeow/store/sqlstore"
 	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
 	waLog "go.mau.fi/whatsmeow/util/log"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
+// ErrChatNotFound is returned when WhatsApp does not know a chat JID
+var ErrChatNotFound = errors.New("chat not found")
+
+// ErrDuplicateMessageID is returned when a send reuses the ID of a message already sent
+var ErrDuplicateMessageID = errors.New("message ID was already used")
+
 // Whatsapp represents a WhatsApp client
 type Whatsapp struct {
-	client   *whatsmeow.Client
-	ChatChan chan models.Chat
+	client          *whatsmeow.Client
+	ChatChan        chan models.Chat
+	ReactionChan    chan models.Reaction
+	LabelChan       chan models.LabelUpdate
+	ReceiptChan     chan models.Receipt
+	HistorySyncChan chan models.HistorySyncChunk
+	GroupChan       chan models.GroupUpdate
+	RawChan         chan models.RawMessage
+	ConnectedChan   chan struct{}
+	MediaChan       chan models.MediaDownload
+	mediaDir        string
+	sendDir         string
+	mediaQueue      chan models.Message
+	opts            Options
+	sent            sentIDs
+	media           mediaBreaker
+	condition       conditionTracker
+	delivery        *deliveryTracker
+	// terminal is where login QR codes are rendered, stdout outside of tests
+	terminal io.Writer
+	// paired is set once this process pairs a device, as opposed to restoring a stored session
+	paired atomic.Bool
+}
+
+// Options configures optional behavior of the Whatsapp client
+type Options struct {
+	// StoreUnknownTypes keeps unsupported messages as placeholders instead of dropping them
+	StoreUnknownTypes bool
+	// StoreRaw keeps the raw proto of every received message for debugging
+	StoreRaw bool
+	// SendMediaDir is the only directory files sent as media are read from.
+	// Empty uses the outbox directory of the store.
+	SendMediaDir string
 }
 
 // NewWhatsapp creates a new Whatsapp client
-func NewWhatsapp(storeDir string) (*Whatsapp, error) {
+func NewWhatsapp(storeDir string, opts Options) (*Whatsapp, error) {
 	container, err := sqlstore.New("sqlite3", fmt.Sprintf("file:%s/whatsapp.db?_foreign_keys=on", storeDir), waLog.Stdout("Database", "INFO", true))
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to WhatsApp database: %w", err)
@@ -35,44 +80,149 @@ func NewWhatsapp(storeDir string) (*Whatsapp, error) {
 		return nil, fmt.Errorf("failed to get device: %w", err)
 	}
 
-	client := whatsmeow.NewClient(deviceStore, waLog.Stdout("Client", "INFO", true))
+	delivery := &deliveryTracker{}
+	client := whatsmeow.NewClient(deviceStore, deliveryLogger{
+		Logger:  waLog.Stdout("Client", "INFO", true),
+		tracker: delivery,
+	})
 
 	w := &Whatsapp{
-		client: client,
+		client:   client,
+		mediaDir: filepath.Join(storeDir, "media"),
+		opts:     opts,
+		delivery: delivery,
+		terminal: os.Stdout,
+	}
+
+	if err := checkWritable(w.mediaDir); err != nil {
+		w.media.trip(err)
+	}
+
+	w.sendDir = opts.SendMediaDir
+	if w.sendDir == "" {
+		w.sendDir = filepath.Join(storeDir, "outbox")
+	}
+	if err := os.MkdirAll(w.sendDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create send media directory: %w", err)
 	}
 
 	w.ChatChan = make(chan models.Chat)
+	w.ReactionChan = make(chan models.Reaction)
+	w.LabelChan = make(chan models.LabelUpdate)
+	w.ReceiptChan = make(chan models.Receipt)
+	w.HistorySyncChan = make(chan models.HistorySyncChunk)
+	w.GroupChan = make(chan models.GroupUpdate)
+	w.ConnectedChan = make(chan struct{})
+	w.RawChan = make(chan models.RawMessage)
+	w.MediaChan = make(chan models.MediaDownload)
+	w.mediaQueue = make(chan models.Message, mediaQueueSize)
+
+	for range mediaWorkers {
+		go w.downloadQueuedMedia()
+	}
 
 	// Set up event handler
-	client.AddEventHandler(func(evt any) {
-		switch v := evt.(type) {
-		case *events.Message:
-			msg, err := w.handleMessage(v)
+	client.AddEventHandler(w.handleEvent)
+
+	return w, nil
+}
+
+// handleEvent dispatches a whatsmeow event to the channel of its kind
+func (w *Whatsapp) handleEvent(evt any) {
+	w.condition.update(evt)
+
+	switch v := evt.(type) {
+	case *events.Message:
+		// Raw protos are kept before parsing so messages that fail to parse can be inspected
+		if w.opts.StoreRaw {
+			raw, err := rawMessage(v)
 			if err != nil {
-				fmt.Println("Error handling message:", err)
+				fmt.Println("Error encoding raw message:", err)
 			} else {
-				w.ChatChan <- models.Chat{
-					JID:             msg.ChatJID,
-					Name:            msg.Sender,
-					LastMessageTime: msg.Timestamp,
-					Messages:        []models.Message{msg},
-				}
+				w.RawChan <- raw
 			}
-		case *events.HistorySync:
-			chat, err := w.handleHistorySync(v)
-			if err != nil {
-				fmt.Println("Error handling history sync:", err)
-			} else {
-				w.ChatChan <- chat
+		}
+
+		if v.Message.GetReactionMessage() != nil {
+			w.ReactionChan <- w.handleReaction(v)
+			return
+		}
+
+		// Messages sent by the bridge are already stored
+		if w.isEcho(v) {
+			return
+		}
+
+		msg, err := w.handleMessage(v)
+		if err != nil {
+			fmt.Println("Error handling message:", err)
+		} else {
+			chat := models.Chat{
+				JID:             msg.ChatJID,
+				Name:            msg.Sender,
+				LastMessageTime: msg.Timestamp,
+				Messages:        []models.Message{msg},
+			}
+			// Our own messages must not rename the chat after us
+			if msg.IsFromMe {
+				chat.Name = ""
+			}
+			w.ChatChan <- chat
+
+			// Downloads run in the background so a slow one does not hold up other events.
+			// While the media directory is unwritable only the metadata is kept, for a later retry.
+			if msg.MediaType != "" && !w.media.open.Load() {
+				w.queueMedia(msg)
 			}
-		case *events.Connected:
-			fmt.Println("Connected to WhatsApp")
-		case *events.LoggedOut:
-			fmt.Println("Device logged out, please scan QR code to log in again")
 		}
-	})
+	case *events.HistorySync:
+		w.HistorySyncChan <- historySyncChunk(v)
 
-	return w, nil
+		chat, err := w.handleHistorySync(v)
+		if err != nil {
+			fmt.Println("Error handling history sync:", err)
+		} else {
+			w.ChatChan <- chat
+		}
+	case *events.Receipt:
+		for _, receipt := range handleReceipt(v) {
+			w.ReceiptChan <- receipt
+		}
+	case *events.LabelEdit:
+		w.LabelChan <- models.LabelUpdate{
+			Label: &models.Label{
+				ID:     v.LabelID,
+				Name:   v.Action.GetName(),
+				Color:  v.Action.GetColor(),
+				Synced: true,
+			},
+			Deleted: v.Action.GetDeleted(),
+		}
+	case *events.LabelAssociationChat:
+		w.LabelChan <- models.LabelUpdate{
+			ChatJID:  v.JID.String(),
+			LabelID:  v.LabelID,
+			Assigned: v.Action.GetLabeled(),
+		}
+	case *events.JoinedGroup:
+		w.GroupChan <- groupMembers(&v.GroupInfo)
+	case *events.GroupInfo:
+		if len(v.Join) > 0 || len(v.Leave) > 0 {
+			w.GroupChan <- models.GroupUpdate{
+				GroupJID: v.JID.String(),
+				Joined:   jidStrings(v.Join),
+				Left:     jidStrings(v.Leave),
+			}
+		}
+	case *events.PairSuccess:
+		w.paired.Store(true)
+	case *events.Connected:
+		fmt.Println("Connected to WhatsApp")
+		go w.syncGroups()
+		w.ConnectedChan <- struct{}{}
+	case *events.LoggedOut:
+		fmt.Println("Device logged out, please scan QR code to log in again")
+	}
 }
 
 // Connect connects the client
@@ -80,6 +230,12 @@ func (w *Whatsapp) Connect() error {
 	return w.client.Connect()
 }
 
+// FreshlyPaired reports whether the device was paired by this process rather than
+// restored from the session store
+func (w *Whatsapp) FreshlyPaired() bool {
+	return w.paired.Load()
+}
+
 // IsLoggedIn returns true if the client is logged in
 func (w *Whatsapp) IsLoggedIn() bool {
 	return w.client.IsLoggedIn()
@@ -90,13 +246,26 @@ func (w *Whatsapp) IsConnected() bool {
 	return w.client.IsConnected()
 }
 
+// Reconnect connects the client and waits up to timeout for the connection to be established
+func (w *Whatsapp) Reconnect(timeout time.Duration) error {
+	err := w.client.Connect()
+	if err != nil && !errors.Is(err, whatsmeow.ErrAlreadyConnected) {
+		return fmt.Errorf("failed to reconnect: %w", err)
+	}
+
+	if !w.client.WaitForConnection(timeout) {
+		return fmt.Errorf("timed out reconnecting after %s", timeout)
+	}
+	return nil
+}
+
 // Disconnect disconnects the client
 func (w *Whatsapp) Disconnect() {
 	w.client.Disconnect()
 }
 
-// GetQR returns the QR code for the client
-func (w *Whatsapp) GetQR(ctx context.Context) (string, error) {
+// GetQR returns the QR code for the client, also rendering it on the terminal when terminalOutput is set
+func (w *Whatsapp) GetQR(ctx context.Context, terminalOutput bool) (string, error) {
 	if w.client.Store.ID != nil {
 		err := w.client.Connect()
 		if err != nil {
@@ -119,12 +288,19 @@ func (w *Whatsapp) GetQR(ctx context.Context) (string, error) {
 		return "", fmt.Errorf("QR channel not available")
 	}
 
+	return w.readQR(qrChan, terminalOutput)
+}
+
+// readQR follows the pairing events of qrChan, rendering codes on the terminal when terminalOutput is set
+func (w *Whatsapp) readQR(qrChan <-chan whatsmeow.QRChannelItem, terminalOutput bool) (string, error) {
 	qr := ""
 	connected := make(chan bool, 1)
 	for evt := range qrChan {
 		if evt.Event == "code" {
 			qr = evt.Code
-			qrterminal.GenerateHalfBlock(qr, qrterminal.L, os.Stdout)
+			if terminalOutput {
+				qrterminal.GenerateHalfBlock(qr, qrterminal.L, w.terminal)
+			}
 		} else if evt.Event == "success" {
 			connected <- true
 			return "", nil
@@ -145,60 +321,695 @@ func (w *Whatsapp) GetQR(ctx context.Context) (string, error) {
 
 // GetStatus returns the status of the client
 func (w *Whatsapp) GetStatus() (models.Status, error) {
+	condition := w.condition.get()
 	return models.Status{
-		Connected: w.client.IsConnected(),
-		LoggedIn:  w.client.IsLoggedIn(),
-		PushName:  w.client.Store.PushName,
+		Connected:        w.client.IsConnected(),
+		LoggedIn:         w.client.IsLoggedIn(),
+		PushName:         w.client.Store.PushName,
+		Condition:        condition.Code,
+		ConditionMessage: condition.Message,
 	}, nil
 }
 
-// SendMessage sends a message to a recipient
-func (w *Whatsapp) SendMessage(ctx context.Context, recipient string, message string) error {
+// IsBusiness returns true if the logged in account is a WhatsApp Business account
+func (w *Whatsapp) IsBusiness() bool {
+	return w.client.Store.BusinessName != ""
+}
+
+// EditLabel creates or updates a label on WhatsApp
+func (w *Whatsapp) EditLabel(label models.Label) error {
+	err := w.client.SendAppState(appstate.BuildLabelEdit(label.ID, label.Name, label.Color, false))
+	if err != nil {
+		return fmt.Errorf("failed to edit label: %w", err)
+	}
+	return nil
+}
+
+// LabelChat assigns a label to a chat on WhatsApp or removes it
+func (w *Whatsapp) LabelChat(chatJID string, labelID string, labeled bool) error {
+	jid, err := types.ParseJID(chatJID)
+	if err != nil {
+		return fmt.Errorf("invalid chat JID: %w", err)
+	}
+
+	err = w.client.SendAppState(appstate.BuildLabelChat(jid, labelID, labeled))
+	if err != nil {
+		return fmt.Errorf("failed to label chat: %w", err)
+	}
+	return nil
+}
+
+// MarkRead sends read receipts for messages of a chat. All messages must come from the same sender.
+func (w *Whatsapp) MarkRead(chatJID string, sender string, ids []string) error {
+	chat, err := types.ParseJID(chatJID)
+	if err != nil {
+		return fmt.Errorf("invalid chat JID: %w", err)
+	}
+
+	// Stored senders may carry a device suffix, but read receipts are addressed to the
+	// sender's user JID
+	var senderJID types.JID
+	if sender != "" {
+		senderJID, err = types.ParseJID(sender)
+		if err != nil {
+			return fmt.Errorf("invalid sender JID: %w", err)
+		}
+		senderJID = senderJID.ToNonAD()
+	}
+
+	err = w.client.MarkRead(ids, time.Now(), chat, senderJID)
+	if err != nil {
+		return fmt.Errorf("failed to mark messages read: %w", err)
+	}
+	return nil
+}
+
+// GetContacts returns all contacts known to the WhatsApp session, sorted by name
+func (w *Whatsapp) GetContacts() ([]models.Contact, error) {
+	infos, err := w.client.Store.Contacts.GetAllContacts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contacts: %w", err)
+	}
+
+	contacts := make([]models.Contact, 0, len(infos))
+	for jid, info := range infos {
+		contacts = append(contacts, models.Contact{
+			PhoneNumber: jid.User,
+			Name:        contactName(info),
+			JID:         jid.String(),
+			IsBusiness:  info.BusinessName != "",
+		})
+	}
+
+	sort.Slice(contacts, func(i, j int) bool {
+		if contacts[i].Name != contacts[j].Name {
+			return contacts[i].Name < contacts[j].Name
+		}
+		return contacts[i].JID < contacts[j].JID
+	})
+
+	return contacts, nil
+}
+
+// CountContacts returns the number of contacts known to the WhatsApp session
+func (w *Whatsapp) CountContacts() (int, error) {
+	infos, err := w.client.Store.Contacts.GetAllContacts()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get contacts: %w", err)
+	}
+	return len(infos), nil
+}
+
+// contactName returns the best available display name for a contact
+func contactName(info types.ContactInfo) string {
+	if info.FullName != "" {
+		return info.FullName
+	}
+	if info.BusinessName != "" {
+		return info.BusinessName
+	}
+	return info.PushName
+}
+
+// ContactName looks up a user in the contacts stored by the WhatsApp session, without
+// asking WhatsApp. It reports false if the JID is not a known contact.
+func (w *Whatsapp) ContactName(chatJID string) (string, bool, error) {
+	jid, err := types.ParseJID(chatJID)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid chat JID: %w", err)
+	}
+
+	info, err := w.client.Store.Contacts.GetContact(jid.ToNonAD())
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get contact: %w", err)
+	}
+	if !info.Found {
+		return "", false, nil
+	}
+
+	return contactName(info), true, nil
+}
+
+// GetChatName fetches the current name of a chat from WhatsApp: the subject for groups
+// and the contact name for users. It returns ErrChatNotFound if WhatsApp does not know the JID.
+func (w *Whatsapp) GetChatName(chatJID string) (string, error) {
+	jid, err := types.ParseJID(chatJID)
+	if err != nil {
+		return "", fmt.Errorf("invalid chat JID: %w", err)
+	}
+
+	if jid.Server == types.GroupServer {
+		info, err := w.client.GetGroupInfo(jid)
+		if errors.Is(err, whatsmeow.ErrGroupNotFound) || errors.Is(err, whatsmeow.ErrNotInGroup) {
+			return "", ErrChatNotFound
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to get group info: %w", err)
+		}
+		return info.Name, nil
+	}
+
+	info, err := w.client.Store.Contacts.GetContact(jid.ToNonAD())
+	if err != nil {
+		return "", fmt.Errorf("failed to get contact: %w", err)
+	}
+	if info.Found {
+		return contactName(info), nil
+	}
+
+	resp, err := w.client.IsOnWhatsApp([]string{"+" + jid.User})
+	if err != nil {
+		return "", fmt.Errorf("failed to check contact: %w", err)
+	}
+	if len(resp) == 0 || !resp[0].IsIn {
+		return "", ErrChatNotFound
+	}
+	if resp[0].VerifiedName != nil && resp[0].VerifiedName.Details != nil {
+		return resp[0].VerifiedName.Details.GetVerifiedName(), nil
+	}
+
+	return "", nil
+}
+
+// SendMessage sends a message to a recipient and returns its ID, with the devices it did
+// not reach when it was only partially delivered
+func (w *Whatsapp) SendMessage(ctx context.Context, recipient string, message string, opts models.SendOptions) (string, *models.DeliveryReport, error) {
+	sent, err := w.sendText(ctx, recipient, message, opts)
+	if err != nil {
+		return "", nil, err
+	}
+
+	w.recordSent(models.Message{
+		ID:        sent.id,
+		ChatJID:   sent.jid.String(),
+		Content:   message,
+		Timestamp: sent.timestamp,
+		Type:      "text",
+	})
+
+	return sent.id, sent.delivery, nil
+}
+
+// SendBroadcast sends a message to each recipient individually, as a WhatsApp broadcast
+// list does, pausing between sends to stay under WhatsApp's rate limits. A failed send
+// does not stop the broadcast; the outcome for each recipient is reported in order.
+// The messages sent are recorded under a "<unix ms>@broadcast" pseudo-chat, whose JID is
+// returned, rather than in the chat with each recipient.
+func (w *Whatsapp) SendBroadcast(ctx context.Context, recipients []string, message string) (string, []models.RecipientResult) {
+	now := time.Now()
+	chat := models.Chat{
+		JID:             fmt.Sprintf("%d@%s", now.UnixMilli(), types.BroadcastServer),
+		Name:            fmt.Sprintf("Broadcast to %d recipients", len(recipients)),
+		LastMessageTime: now,
+	}
+	results := make([]models.RecipientResult, 0, len(recipients))
+
+	for i, recipient := range recipients {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(broadcastInterval):
+			}
+		}
+
+		result := models.RecipientResult{Recipient: recipient}
+		if err := ctx.Err(); err != nil {
+			result.Status = "failed"
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		resp, err := w.sendText(ctx, recipient, message, models.SendOptions{})
+		if err != nil {
+			result.Status = "failed"
+			result.Error = err.Error()
+		} else {
+			result.Status = "sent"
+			result.JID = resp.jid.String()
+			result.MessageID = resp.id
+			result.Timestamp = resp.timestamp
+
+			chat.LastMessageTime = resp.timestamp
+			chat.Messages = append(chat.Messages, models.Message{
+				ID:        resp.id,
+				ChatJID:   chat.JID,
+				Content:   message,
+				Timestamp: resp.timestamp,
+				Type:      "text",
+			})
+		}
+		results = append(results, result)
+	}
+
+	if len(chat.Messages) > 0 {
+		w.recordSentChat(chat)
+	}
+
+	return chat.JID, results
+}
+
+// broadcastInterval is the pause between sends to consecutive broadcast recipients
+const broadcastInterval = time.Second
+
+// sentMessage identifies a message that was sent
+type sentMessage struct {
+	jid       types.JID
+	id        string
+	timestamp time.Time
+	// delivery lists the devices the message did not reach, nil if it reached all of them
+	delivery *models.DeliveryReport
+}
+
+// parseRecipient converts a recipient phone number or JID to a JID
+func parseRecipient(recipient string) (types.JID, error) {
 	var recipientJID types.JID
 	var err error
 
-	if recipient[0] == '+' {
-		recipient = recipient[1:]
-	}
+	recipient = strings.TrimPrefix(recipient, "+")
 
-	if recipient[len(recipient)-5:] == "@s.whatsapp.net" {
+	if strings.Contains(recipient, "@") {
 		recipientJID, err = types.ParseJID(recipient)
 	} else {
 		recipientJID = types.NewJID(recipient, types.DefaultUserServer)
 	}
 
 	if err != nil {
-		return fmt.Errorf("invalid recipient: %w", err)
+		return types.JID{}, fmt.Errorf("invalid recipient: %w", err)
+	}
+	return recipientJID, nil
+}
+
+// RecipientChatJID returns the JID of the chat a message to the recipient is sent in
+func RecipientChatJID(recipient string) (string, error) {
+	jid, err := parseRecipient(recipient)
+	if err != nil {
+		return "", err
 	}
+	return jid.String(), nil
+}
+
+// messageIDPattern matches message IDs like those generated by WhatsApp clients
+var messageIDPattern = regexp.MustCompile(`^[0-9A-F]{16,64}$`)
+
+// ValidMessageID reports whether id can be used as the ID of a sent message:
+// 16 to 64 uppercase hexadecimal characters
+func ValidMessageID(id string) bool {
+	return messageIDPattern.MatchString(id)
+}
+
+// ValidExpiration reports whether seconds is a disappearing message duration WhatsApp
+// supports: 24 hours, 7 days or 90 days
+func ValidExpiration(seconds uint32) bool {
+	switch seconds {
+	case 24 * 60 * 60, 7 * 24 * 60 * 60, 90 * 24 * 60 * 60:
+		return true
+	}
+	return false
+}
+
+// quoteContext builds the context info replying to a stored message
+func (w *Whatsapp) quoteContext(quoted models.Message) *waProto.ContextInfo {
+	participant := quoted.Sender
+	if quoted.IsFromMe && w.client.Store.ID != nil {
+		participant = w.client.Store.ID.ToNonAD().String()
+	}
+
+	return &waProto.ContextInfo{
+		StanzaID:    proto.String(quoted.ID),
+		Participant: proto.String(participant),
+		QuotedMessage: &waProto.Message{
+			Conversation: proto.String(quoted.Content),
+		},
+	}
+}
 
+// buildTextMessage builds a text message with the link preview, quote and expiration of opts
+func (w *Whatsapp) buildTextMessage(ctx context.Context, message string, opts models.SendOptions) *waProto.Message {
 	msg := &waProto.Message{
 		Conversation: proto.String(message),
 	}
 
-	_, err = w.client.SendMessage(ctx, recipientJID, msg)
+	if opts.WithPreview {
+		if link := findURL(message); link != "" {
+			preview, err := fetchLinkPreview(ctx, link)
+			if err != nil {
+				fmt.Println("Error fetching link preview, sending without it:", err)
+			} else {
+				msg = buildPreviewMessage(message, preview)
+			}
+		}
+	}
+
+	if opts.Quoted != nil || opts.ExpireSeconds > 0 {
+		if msg.ExtendedTextMessage == nil {
+			msg = &waProto.Message{
+				ExtendedTextMessage: &waProto.ExtendedTextMessage{
+					Text: proto.String(message),
+				},
+			}
+		}
+
+		contextInfo := &waProto.ContextInfo{}
+		if opts.Quoted != nil {
+			contextInfo = w.quoteContext(*opts.Quoted)
+		}
+		if opts.ExpireSeconds > 0 {
+			contextInfo.Expiration = proto.Uint32(opts.ExpireSeconds)
+		}
+		msg.ExtendedTextMessage.ContextInfo = contextInfo
+	}
+
+	return msg
+}
+
+// sendID returns the ID a message is sent with: opts.MessageID when set, a generated one otherwise.
+// It returns ErrDuplicateMessageID if opts.MessageID was already used by this process.
+func (w *Whatsapp) sendID(opts models.SendOptions) (types.MessageID, error) {
+	if opts.MessageID == "" {
+		return w.client.GenerateMessageID(), nil
+	}
+	if w.sent.contains(opts.MessageID) {
+		return "", ErrDuplicateMessageID
+	}
+	return opts.MessageID, nil
+}
+
+// sendText sends a text message to a recipient phone number or JID, leaving it to the
+// caller to record the message sent
+func (w *Whatsapp) sendText(ctx context.Context, recipient string, message string, opts models.SendOptions) (sentMessage, error) {
+	recipientJID, err := parseRecipient(recipient)
+	if err != nil {
+		return sentMessage{}, err
+	}
+
+	msg := w.buildTextMessage(ctx, message, opts)
+
+	id, err := w.sendID(opts)
+	if err != nil {
+		return sentMessage{}, err
+	}
+	extra := whatsmeow.SendRequestExtra{ID: id}
+
+	var resp whatsmeow.SendResponse
+	recipients := func() (map[string]bool, error) {
+		return w.sendRecipients(recipientJID)
+	}
+	delivery, err := w.delivery.track(extra.ID, recipientJID.String(), recipients, func() error {
+		var err error
+		resp, err = w.client.SendMessage(ctx, recipientJID, msg, extra)
+		return err
+	})
+	if err != nil {
+		return sentMessage{}, fmt.Errorf("failed to send message: %w", groupSendError(recipientJID, err))
+	}
+
+	return sentMessage{jid: recipientJID, id: resp.ID, timestamp: resp.Timestamp, delivery: delivery}, nil
+}
+
+// SendReaction reacts to a message with the given emoji
+func (w *Whatsapp) SendReaction(ctx context.Context, chatJID string, sender string, messageID string, emoji string) error {
+	if !isSingleEmoji(emoji) {
+		return fmt.Errorf("reaction must be a single emoji, got %q", emoji)
+	}
+
+	chat, err := types.ParseJID(chatJID)
+	if err != nil {
+		return fmt.Errorf("invalid chat JID: %w", err)
+	}
+
+	senderJID, err := reactionSender(sender)
 	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+		return err
+	}
+
+	_, err = w.client.SendMessage(ctx, chat, w.client.BuildReaction(chat, senderJID, messageID, emoji))
+	if err != nil {
+		return fmt.Errorf("failed to send reaction: %w", err)
 	}
 
 	return nil
 }
 
-func (w *Whatsapp) handleMessage(msg *events.Message) (models.Message, error) {
-	content := msg.Message.Conversation
-	if content == nil {
-		return models.Message{}, fmt.Errorf("message content is empty")
+// reactionSender parses the sender of the message reacted to, dropping any device suffix a
+// stored sender may carry since the reaction key identifies users, not devices. An empty
+// sender, used for our own messages, gives an empty JID.
+func reactionSender(sender string) (types.JID, error) {
+	if sender == "" {
+		return types.JID{}, nil
 	}
 
-	return models.Message{
-		ID:        msg.Info.ID,
+	jid, err := types.ParseJID(sender)
+	if err != nil {
+		return types.JID{}, fmt.Errorf("invalid sender JID: %w", err)
+	}
+	return jid.ToNonAD(), nil
+}
+
+func (w *Whatsapp) handleReaction(msg *events.Message) models.Reaction {
+	reaction := msg.Message.GetReactionMessage()
+
+	return models.Reaction{
+		MessageID: reaction.GetKey().GetId(),
 		ChatJID:   msg.Info.Chat.String(),
-		Sender:    msg.Info.Sender.String(),
-		Content:   *content,
+		Sender:    msg.Info.Sender.ToNonAD().String(),
+		Emoji:     reaction.GetText(),
 		Timestamp: msg.Info.Timestamp,
-		IsFromMe:  msg.Info.IsFromMe,
+	}
+}
+
+func (w *Whatsapp) handleMessage(msg *events.Message) (models.Message, error) {
+	if protocol := msg.Message.GetProtocolMessage(); protocol.GetType() == waProto.ProtocolMessage_MESSAGE_EDIT {
+		return w.handleEdit(msg, protocol)
+	}
+
+	content := msg.Message.GetConversation()
+	if content == "" {
+		content = msg.Message.GetExtendedTextMessage().GetText()
+	}
+
+	mediaType, media, caption := extractMedia(msg.Message)
+	if content == "" {
+		content = caption
+	}
+
+	messageType := "text"
+	var rawType string
+
+	if content == "" && media == nil {
+		rawType = unknownMessageType(msg.Message)
+		if !w.opts.StoreUnknownTypes || rawType == "" {
+			return models.Message{}, fmt.Errorf("message content is empty")
+		}
+
+		messageType = "unsupported"
+		content = fmt.Sprintf("[unsupported: %s]", rawType)
+	}
+
+	contextInfo := msg.Message.GetExtendedTextMessage().GetContextInfo()
+	if media != nil {
+		contextInfo = media.GetContextInfo()
+	}
+
+	sender := msg.Info.Sender
+	if msg.Info.IsFromMe {
+		// Messages sent from any of our devices are attributed to the account, not the device
+		sender = sender.ToNonAD()
+	}
+
+	message := models.Message{
+		ID:         msg.Info.ID,
+		ChatJID:    msg.Info.Chat.String(),
+		Sender:     sender.String(),
+		Content:    content,
+		Timestamp:  msg.Info.Timestamp,
+		IsFromMe:   msg.Info.IsFromMe,
+		MentionsMe: w.mentionsMe(contextInfo),
+		Type:       messageType,
+		RawType:    rawType,
+
+		ForwardingScore: contextInfo.GetForwardingScore(),
+	}
+
+	if media != nil {
+		message.Type = mediaType
+		setMedia(&message, mediaType, media)
+	}
+
+	return message, nil
+}
+
+// handleEdit converts an edit into the edited message, identified by the ID of the original
+func (w *Whatsapp) handleEdit(msg *events.Message, protocol *waProto.ProtocolMessage) (models.Message, error) {
+	edited := protocol.GetEditedMessage()
+
+	content := edited.GetConversation()
+	if content == "" {
+		content = edited.GetExtendedTextMessage().GetText()
+	}
+
+	contextInfo := edited.GetExtendedTextMessage().GetContextInfo()
+	if _, media, caption := extractMedia(edited); media != nil {
+		contextInfo = media.GetContextInfo()
+		if content == "" {
+			content = caption
+		}
+	}
+	if content == "" {
+		return models.Message{}, fmt.Errorf("edited message content is empty")
+	}
+
+	sender := msg.Info.Sender
+	if msg.Info.IsFromMe {
+		sender = sender.ToNonAD()
+	}
+
+	editedAt := msg.Info.Timestamp
+	return models.Message{
+		ID:         protocol.GetKey().GetId(),
+		ChatJID:    msg.Info.Chat.String(),
+		Sender:     sender.String(),
+		Content:    content,
+		Timestamp:  msg.Info.Timestamp,
+		IsFromMe:   msg.Info.IsFromMe,
+		MentionsMe: w.mentionsMe(contextInfo),
+		Type:       "text",
+		EditedAt:   &editedAt,
 	}, nil
 }
 
+// unknownMessageType returns the name of the set content field with the lowest field number,
+// so the result does not depend on the undefined order of Range.
+// Fields carrying encryption or protocol metadata rather than content are skipped.
+func unknownMessageType(msg *waProto.Message) string {
+	m := msg.ProtoReflect()
+	fields := m.Descriptor().Fields()
+
+	var first protoreflect.FieldDescriptor
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		switch fd.Name() {
+		case "messageContextInfo", "senderKeyDistributionMessage", "protocolMessage":
+			continue
+		}
+		if m.Has(fd) && (first == nil || fd.Number() < first.Number()) {
+			first = fd
+		}
+	}
+
+	if first == nil {
+		return ""
+	}
+	return string(first.Name())
+}
+
+// handleReceipt converts delivery and read receipts from recipients of our messages.
+// Receipts from our own devices and other receipt types are ignored.
+func handleReceipt(v *events.Receipt) []models.Receipt {
+	if v.IsFromMe {
+		return nil
+	}
+
+	var read bool
+	switch v.Type {
+	case types.ReceiptTypeDelivered:
+	case types.ReceiptTypeRead, types.ReceiptTypePlayed:
+		read = true
+	default:
+		return nil
+	}
+
+	receipts := make([]models.Receipt, 0, len(v.MessageIDs))
+	for _, id := range v.MessageIDs {
+		receipts = append(receipts, models.Receipt{
+			MessageID:   id,
+			ChatJID:     v.Chat.String(),
+			Participant: v.Sender.ToNonAD().String(),
+			Read:        read,
+			Timestamp:   v.Timestamp,
+		})
+	}
+	return receipts
+}
+
+// mentionsMe reports whether the logged-in account is among the mentioned JIDs, by phone
+// number or by LID
+func (w *Whatsapp) mentionsMe(contextInfo *waProto.ContextInfo) bool {
+	var own []types.JID
+	if w.client.Store.ID != nil {
+		own = append(own, *w.client.Store.ID)
+	}
+	if !w.client.Store.LID.IsEmpty() {
+		own = append(own, w.client.Store.LID)
+	}
+	return mentions(contextInfo, own)
+}
+
+// mentions reports whether any of the own JIDs is among the mentioned JIDs, ignoring devices
+func mentions(contextInfo *waProto.ContextInfo, own []types.JID) bool {
+	for _, mentioned := range contextInfo.GetMentionedJID() {
+		jid, err := types.ParseJID(mentioned)
+		if err != nil {
+			continue
+		}
+		for _, ownJID := range own {
+			if jid.User == ownJID.User && jid.Server == ownJID.Server {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// syncGroups sends the full member list of every group the account belongs to
+func (w *Whatsapp) syncGroups() {
+	groups, err := w.client.GetJoinedGroups()
+	if err != nil {
+		fmt.Println("Error getting joined groups:", err)
+		return
+	}
+
+	for _, group := range groups {
+		w.GroupChan <- groupMembers(group)
+	}
+}
+
+// groupMembers lists the participants of a group as a full membership update
+func groupMembers(group *types.GroupInfo) models.GroupUpdate {
+	participants := make([]string, 0, len(group.Participants))
+	for _, participant := range group.Participants {
+		participants = append(participants, participant.JID.ToNonAD().String())
+	}
+
+	return models.GroupUpdate{
+		GroupJID:     group.JID.String(),
+		Participants: participants,
+	}
+}
+
+// jidStrings converts JIDs to their string form, dropping device suffixes
+func jidStrings(jids []types.JID) []string {
+	result := make([]string, 0, len(jids))
+	for _, jid := range jids {
+		result = append(result, jid.ToNonAD().String())
+	}
+	return result
+}
+
+// historySyncChunk describes the contents of a history sync event
+func historySyncChunk(historySync *events.HistorySync) models.HistorySyncChunk {
+	chunk := models.HistorySyncChunk{
+		SyncType:      strings.ToLower(historySync.Data.GetSyncType().String()),
+		Progress:      historySync.Data.GetProgress(),
+		Conversations: len(historySync.Data.GetConversations()),
+	}
+	for _, conv := range historySync.Data.GetConversations() {
+		chunk.Messages += len(conv.GetMessages())
+	}
+	return chunk
+}
+
 // HandleHistorySync processes message history sync events
 func (w *Whatsapp) handleHistorySync(historySync *events.HistorySync) (models.Chat, error) {
 	for _, conv := range historySync.Data.Conversations {
@@ -227,12 +1038,14 @@ func (w *Whatsapp) handleHistorySync(historySync *events.HistorySync) (models.Ch
 			}
 
 			message := models.Message{
-				ID:        msg.GetMessage().GetKey().GetId(),
-				ChatJID:   chatJID,
-				Sender:    msg.GetMessage().GetKey().GetParticipant(),
-				Content:   content,
-				Timestamp: timestamp,
-				IsFromMe:  msg.GetMessage().GetKey().GetFromMe(),
+				ID:         msg.GetMessage().GetKey().GetId(),
+				ChatJID:    chatJID,
+				Sender:     msg.GetMessage().GetKey().GetParticipant(),
+				Content:    content,
+				Timestamp:  timestamp,
+				IsFromMe:   msg.GetMessage().GetKey().GetFromMe(),
+				MentionsMe: w.mentionsMe(msg.GetMessage().GetMessage().GetExtendedTextMessage().GetContextInfo()),
+				Type:       "text",
 			}
 
 			chat.Messages = append(chat.Messages, message)
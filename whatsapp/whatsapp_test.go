@@ -0,0 +1,345 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mbenaiss/whatsapp-mcp/models"
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/proto/waHistorySync"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	testOwnJID = types.NewJID("15550001111", types.DefaultUserServer)
+	testOwnLID = types.NewJID("987654321", types.HiddenUserServer)
+)
+
+// newTestWhatsapp returns a client logged in as testOwnJID without a connection or media downloads
+func newTestWhatsapp(t *testing.T) *Whatsapp {
+	t.Helper()
+
+	device := &store.Device{ID: &testOwnJID, LID: testOwnLID}
+	w := &Whatsapp{client: whatsmeow.NewClient(device, nil)}
+	w.media.open.Store(true)
+	return w
+}
+
+func TestMentionsMe(t *testing.T) {
+	chat := types.NewJID("120363000000000000", types.GroupServer)
+	other := types.NewJID("15550002222", types.DefaultUserServer)
+	mention := func(jids ...string) *waProto.ContextInfo {
+		return &waProto.ContextInfo{MentionedJID: jids}
+	}
+
+	tests := []struct {
+		name    string
+		message *waProto.Message
+		want    bool
+	}{
+		{
+			name:    "plain text",
+			message: &waProto.Message{Conversation: proto.String("hello")},
+		},
+		{
+			name: "extended text mentioning me",
+			message: &waProto.Message{ExtendedTextMessage: &waProto.ExtendedTextMessage{
+				Text:        proto.String("hi @15550001111"),
+				ContextInfo: mention(testOwnJID.String()),
+			}},
+			want: true,
+		},
+		{
+			name: "extended text mentioning someone else",
+			message: &waProto.Message{ExtendedTextMessage: &waProto.ExtendedTextMessage{
+				Text:        proto.String("hi @15550002222"),
+				ContextInfo: mention(other.String()),
+			}},
+		},
+		{
+			name: "mention of one of my devices",
+			message: &waProto.Message{ExtendedTextMessage: &waProto.ExtendedTextMessage{
+				Text:        proto.String("hi"),
+				ContextInfo: mention("15550001111:3@s.whatsapp.net"),
+			}},
+			want: true,
+		},
+		{
+			name: "mention by LID",
+			message: &waProto.Message{ExtendedTextMessage: &waProto.ExtendedTextMessage{
+				Text:        proto.String("hi @987654321"),
+				ContextInfo: mention(testOwnLID.String()),
+			}},
+			want: true,
+		},
+		{
+			name: "image caption mentioning me",
+			message: &waProto.Message{ImageMessage: &waProto.ImageMessage{
+				Caption:     proto.String("look @15550001111"),
+				ContextInfo: mention(other.String(), testOwnJID.String()),
+			}},
+			want: true,
+		},
+		{
+			name: "edited caption mentioning me",
+			message: &waProto.Message{ProtocolMessage: &waProto.ProtocolMessage{
+				Type: waProto.ProtocolMessage_MESSAGE_EDIT.Enum(),
+				Key:  &waProto.MessageKey{ID: proto.String("ORIGINAL")},
+				EditedMessage: &waProto.Message{ImageMessage: &waProto.ImageMessage{
+					Caption:     proto.String("look @987654321"),
+					ContextInfo: mention(testOwnLID.String()),
+				}},
+			}},
+			want: true,
+		},
+	}
+
+	w := newTestWhatsapp(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := w.handleMessage(&events.Message{
+				Info: types.MessageInfo{
+					MessageSource: types.MessageSource{Chat: chat, Sender: other},
+					ID:            "ID",
+					Timestamp:     time.Unix(1700000000, 0),
+				},
+				Message: tt.message,
+			})
+			if err != nil {
+				t.Fatalf("handleMessage() error = %v", err)
+			}
+			if msg.MentionsMe != tt.want {
+				t.Errorf("MentionsMe = %v, want %v", msg.MentionsMe, tt.want)
+			}
+		})
+	}
+}
+
+func TestMentionsMeLoggedOut(t *testing.T) {
+	w := &Whatsapp{client: whatsmeow.NewClient(&store.Device{}, nil)}
+	if w.mentionsMe(&waProto.ContextInfo{MentionedJID: []string{testOwnJID.String()}}) {
+		t.Error("mentionsMe() = true without a logged in account")
+	}
+}
+
+func TestUnknownMessageType(t *testing.T) {
+	tests := []struct {
+		name    string
+		message *waProto.Message
+		want    string
+	}{
+		{
+			name:    "empty",
+			message: &waProto.Message{},
+		},
+		{
+			name: "metadata only",
+			message: &waProto.Message{
+				MessageContextInfo:           &waProto.MessageContextInfo{},
+				SenderKeyDistributionMessage: &waProto.SenderKeyDistributionMessage{},
+			},
+		},
+		{
+			name: "single content field",
+			message: &waProto.Message{
+				MessageContextInfo: &waProto.MessageContextInfo{},
+				PollUpdateMessage:  &waProto.PollUpdateMessage{},
+			},
+			want: "pollUpdateMessage",
+		},
+		{
+			name: "lowest field number wins",
+			message: &waProto.Message{
+				PollUpdateMessage: &waProto.PollUpdateMessage{},
+				LocationMessage:   &waProto.LocationMessage{},
+				ContactMessage:    &waProto.ContactMessage{},
+			},
+			want: "contactMessage",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Range visits fields in an undefined order, so repeat to catch order dependence
+			for range 20 {
+				if got := unknownMessageType(tt.message); got != tt.want {
+					t.Fatalf("unknownMessageType = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleMessageEdit(t *testing.T) {
+	w := newTestWhatsapp(t)
+	chat := types.NewJID("15550002222", types.DefaultUserServer)
+	sentAt := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	editedAt := sentAt.Add(time.Minute)
+
+	tests := []struct {
+		name        string
+		edited      *waProto.Message
+		wantContent string
+		wantErr     bool
+	}{
+		{
+			name:        "text",
+			edited:      &waProto.Message{Conversation: proto.String("see you at 6")},
+			wantContent: "see you at 6",
+		},
+		{
+			name: "extended text",
+			edited: &waProto.Message{ExtendedTextMessage: &waProto.ExtendedTextMessage{
+				Text: proto.String("see you at 6, @15550001111"),
+			}},
+			wantContent: "see you at 6, @15550001111",
+		},
+		{
+			name: "caption",
+			edited: &waProto.Message{ImageMessage: &waProto.ImageMessage{
+				Caption: proto.String("the venue"),
+			}},
+			wantContent: "the venue",
+		},
+		{
+			name:    "empty",
+			edited:  &waProto.Message{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The edit arrives as a new message whose protocol message references the original
+			event := &events.Message{
+				Info: types.MessageInfo{
+					ID:            "3EB0E1",
+					Timestamp:     editedAt,
+					MessageSource: types.MessageSource{Chat: chat, Sender: chat},
+				},
+				Message: &waProto.Message{ProtocolMessage: &waProto.ProtocolMessage{
+					Type:          waProto.ProtocolMessage_MESSAGE_EDIT.Enum(),
+					Key:           &waProto.MessageKey{ID: proto.String("3EB0A1")},
+					EditedMessage: tt.edited,
+				}},
+			}
+
+			msg, err := w.handleMessage(event)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("handleMessage = %+v, want an error", msg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("handleMessage: %v", err)
+			}
+
+			if msg.ID != "3EB0A1" || msg.ChatJID != chat.String() {
+				t.Errorf("edit applies to %s in %s, want 3EB0A1 in %s", msg.ID, msg.ChatJID, chat)
+			}
+			if msg.Content != tt.wantContent {
+				t.Errorf("content = %q, want %q", msg.Content, tt.wantContent)
+			}
+			if msg.EditedAt == nil || !msg.EditedAt.Equal(editedAt) {
+				t.Errorf("edited at = %v, want %v", msg.EditedAt, editedAt)
+			}
+		})
+	}
+}
+
+func TestReadQRTerminalOutput(t *testing.T) {
+	for _, terminalOutput := range []bool{false, true} {
+		t.Run(fmt.Sprint(terminalOutput), func(t *testing.T) {
+			var terminal bytes.Buffer
+			w := newTestWhatsapp(t)
+			w.terminal = &terminal
+
+			qrChan := make(chan whatsmeow.QRChannelItem, 2)
+			qrChan <- whatsmeow.QRChannelItem{Event: "code", Code: "2@test-pairing-code"}
+			qrChan <- whatsmeow.QRChannelItem{Event: "success"}
+			close(qrChan)
+
+			if _, err := w.readQR(qrChan, terminalOutput); err != nil {
+				t.Fatalf("readQR: %v", err)
+			}
+			if wrote := terminal.Len() > 0; wrote != terminalOutput {
+				t.Errorf("wrote %d bytes to the terminal with QR_TERMINAL_OUTPUT=%v", terminal.Len(), terminalOutput)
+			}
+		})
+	}
+}
+
+func TestBuildTextMessageExpiration(t *testing.T) {
+	w := newTestWhatsapp(t)
+	week := uint32(7 * 24 * 60 * 60)
+	quoted := &models.Message{ID: "3EB0A1", Sender: "15550002222@s.whatsapp.net", Content: "lunch?"}
+
+	tests := []struct {
+		name           string
+		opts           models.SendOptions
+		wantExpiration uint32
+		wantQuote      bool
+	}{
+		{name: "plain"},
+		{name: "expiring", opts: models.SendOptions{ExpireSeconds: week}, wantExpiration: week},
+		{name: "quoted", opts: models.SendOptions{Quoted: quoted}, wantQuote: true},
+		{name: "quoted and expiring", opts: models.SendOptions{Quoted: quoted, ExpireSeconds: week}, wantExpiration: week, wantQuote: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := w.buildTextMessage(context.Background(), "see you there", tt.opts)
+
+			if tt.wantExpiration == 0 && !tt.wantQuote {
+				if msg.GetConversation() != "see you there" || msg.ExtendedTextMessage != nil {
+					t.Errorf("message = %v, want a plain conversation", msg)
+				}
+				return
+			}
+
+			text := msg.GetExtendedTextMessage()
+			if text.GetText() != "see you there" {
+				t.Errorf("text = %q, want see you there", text.GetText())
+			}
+			contextInfo := text.GetContextInfo()
+			if contextInfo.GetExpiration() != tt.wantExpiration {
+				t.Errorf("expiration = %d, want %d", contextInfo.GetExpiration(), tt.wantExpiration)
+			}
+			if quotes := contextInfo.GetStanzaID() == quoted.ID; quotes != tt.wantQuote {
+				t.Errorf("quoted message ID = %q, want quote %v", contextInfo.GetStanzaID(), tt.wantQuote)
+			}
+		})
+	}
+}
+
+func TestHistorySyncChunk(t *testing.T) {
+	conversation := func(id string, messages int) *waHistorySync.Conversation {
+		conv := &waHistorySync.Conversation{ID: proto.String(id)}
+		for range messages {
+			conv.Messages = append(conv.Messages, &waHistorySync.HistorySyncMsg{})
+		}
+		return conv
+	}
+
+	chunk := historySyncChunk(&events.HistorySync{Data: &waHistorySync.HistorySync{
+		SyncType: waHistorySync.HistorySync_INITIAL_BOOTSTRAP.Enum(),
+		Progress: proto.Uint32(42),
+		Conversations: []*waHistorySync.Conversation{
+			conversation("15550002222@s.whatsapp.net", 3),
+			conversation("120363000000000000@g.us", 5),
+		},
+	}})
+
+	want := models.HistorySyncChunk{SyncType: "initial_bootstrap", Progress: 42, Conversations: 2, Messages: 8}
+	if chunk != want {
+		t.Errorf("chunk = %+v, want %+v", chunk, want)
+	}
+}